@@ -0,0 +1,164 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+)
+
+// changedFiles records the directories of files that changed since a git
+// revision, so Gazelle can update the packages that own them.
+type changedFiles struct {
+	// dirs maps the directory of each changed file, relative to the
+	// repository root, to whether it still exists. If it doesn't, the file
+	// is recorded in its closest existing ancestor instead, mapped to false.
+	dirs map[string]bool
+}
+
+// findChangedFiles returns the files that changed since the git revision
+// rev. Files changed in the working tree and untracked files that aren't
+// ignored are included.
+func findChangedFiles(c *config.Config, rev string) (*changedFiles, error) {
+	// Old versions of git don't support "--end-of-options", so validate
+	// the revision here instead.
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("-changed_since: revision must not start with '-': %q", rev)
+	}
+	diff, err := runGit(c.RepoRoot, "diff", "--name-only", "-z", "--no-renames", "--relative", rev, "--")
+	if err != nil {
+		return nil, fmt.Errorf("-changed_since: %w", err)
+	}
+	untracked, err := runGit(c.RepoRoot, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("-changed_since: %w", err)
+	}
+
+	cf := &changedFiles{dirs: make(map[string]bool)}
+	for _, file := range append(diff, untracked...) {
+		rel := parentRel(file)
+		exists := isDir(filepath.Join(c.RepoRoot, filepath.FromSlash(rel)))
+		for rel != "" && !isDir(filepath.Join(c.RepoRoot, filepath.FromSlash(rel))) {
+			rel = parentRel(rel)
+		}
+		cf.dirs[rel] = cf.dirs[rel] || exists
+	}
+	return cf, nil
+}
+
+// walkDirs returns the directories Gazelle should visit: the directory of
+// each changed file and all of its parents, any of which may own the file.
+// The returned directories are absolute and sorted.
+func (cf *changedFiles) walkDirs(repoRoot string) []string {
+	rels := make(map[string]bool)
+	for rel := range cf.dirs {
+		pathtools.Prefixes(rel)(func(prefix string) bool {
+			rels[prefix] = true
+			return true
+		})
+	}
+	dirs := make([]string, 0, len(rels))
+	for rel := range rels {
+		dirs = append(dirs, filepath.Join(repoRoot, filepath.FromSlash(rel)))
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// shouldUpdate returns whether Gazelle should update the directory rel. A
+// directory is updated if it owns a changed file: it's the closest directory
+// containing the file that has a build file, or the repository root if no
+// directory does. A directory containing a changed file is also updated if
+// it exists, since Gazelle may create a build file there. Build files are
+// found the way the walk finds them, so shouldUpdate must be called during
+// a walk.
+func (cf *changedFiles) shouldUpdate(rel string) bool {
+	for dir, exists := range cf.dirs {
+		if dir == rel && exists {
+			return true
+		}
+		if pathtools.HasPrefix(dir, rel) && owningPackage(dir) == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// owningPackage returns the closest directory containing rel, including rel
+// itself, that has a build file. It returns "" if there is none.
+func owningPackage(rel string) string {
+	owner := ""
+	pathtools.Prefixes(rel)(func(prefix string) bool {
+		di, err := walk.GetDirInfo(prefix)
+		if err != nil {
+			// The directory is excluded.
+			return false
+		}
+		if di.File != nil {
+			owner = prefix
+		}
+		return true
+	})
+	return owner
+}
+
+// parentRel returns the parent directory of the slash-separated relative
+// path rel, or "" for the repository root.
+func parentRel(rel string) string {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// runGit runs git in dir and returns the file names it prints. git must
+// be run with -z, so names are separated with NUL and aren't quoted.
+func runGit(dir string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
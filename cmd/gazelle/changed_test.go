@@ -0,0 +1,246 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+)
+
+func TestChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	for _, index := range []string{"all", "lazy"} {
+		t.Run(index, func(t *testing.T) {
+			dir, cleanup := createChangedRepo(t)
+			defer cleanup()
+
+			if err := runGazelle(dir, []string{"-changed_since=HEAD", "-index=" + index}); err != nil {
+				t.Fatal(err)
+			}
+			testtools.CheckFiles(t, dir, []testtools.FileSpec{
+				{
+					Path: "a/BUILD.bazel",
+					Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = [
+        "a.go",
+        "a2.go",
+    ],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+`,
+				},
+				{
+					Path: "b/BUILD.bazel",
+					Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/repo/b",
+    visibility = ["//visibility:public"],
+)
+`,
+				},
+				{
+					Path: "d/BUILD.bazel",
+					Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# empty
+
+go_library(
+    name = "d",
+    srcs = ["d.go"],
+    importpath = "example.com/repo/d",
+    visibility = ["//visibility:public"],
+)
+`,
+				},
+				{
+					Path:    "e/BUILD.bazel",
+					Content: "# empty",
+				},
+				{
+					Path:    "BUILD.bazel",
+					Content: "# gazelle:prefix example.com/repo",
+				},
+			})
+		})
+	}
+}
+
+// createChangedRepo creates a git repository with a commit, then changes it:
+// it adds an untracked file in a, modifies a file in b, and deletes d/sub.
+// e has no changes.
+func createChangedRepo(t *testing.T) (dir string, cleanup func()) {
+	t.Helper()
+	dir, cleanup = testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{
+			Path:    "a/a.go",
+			Content: "package a",
+		},
+		{
+			Path:    "b/b.go",
+			Content: "package b",
+		},
+		{
+			Path:    "d/BUILD.bazel",
+			Content: "# empty",
+		},
+		{
+			Path:    "d/d.go",
+			Content: "package d",
+		},
+		{
+			Path:    "d/sub/BUILD.bazel",
+			Content: "# empty",
+		},
+		{
+			Path:    "d/sub/sub.go",
+			Content: "package sub",
+		},
+		{
+			Path:    "e/BUILD.bazel",
+			Content: "# empty",
+		},
+		{
+			Path:    "e/e.go",
+			Content: "package e",
+		},
+	})
+
+	if err := commitAll(dir); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a/a2.go"), []byte("package a"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b/b.go"), []byte("package b // modified"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "d/sub")); err != nil {
+		t.Fatal(err)
+	}
+	return dir, cleanup
+}
+
+// commitAll creates a git repository in dir and commits all of its files.
+func commitAll(dir string) error {
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return nil
+}
+
+func TestChangedSinceOwningPackage(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	// Build files are named with a directive, so they're only found after
+	// configuration is loaded.
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo\n# gazelle:build_file_name BUILD.custom",
+		},
+		{
+			Path:    "f/BUILD.custom",
+			Content: "# empty",
+		},
+		{
+			Path:    "f/f.go",
+			Content: "package f",
+		},
+		{
+			Path:    "f/testdata/data.txt",
+			Content: "data",
+		},
+		{
+			Path:    "g/g.go",
+			Content: "package g",
+		},
+	})
+	defer cleanup()
+	if err := commitAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "f/testdata/data.txt"), []byte("changed"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runGazelle(dir, []string{"-changed_since=HEAD"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "f/BUILD.custom",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# empty
+
+go_library(
+    name = "f",
+    srcs = ["f.go"],
+    importpath = "example.com/repo/f",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{Path: "f/testdata/BUILD.custom", NotExist: true},
+		{Path: "g/BUILD.custom", NotExist: true},
+	})
+}
+
+func TestChangedSinceDirArgs(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{{Path: "WORKSPACE"}})
+	defer cleanup()
+
+	want := "-changed_since may not be used with directory arguments"
+	if err := runGazelle(dir, []string{"-changed_since=HEAD", "."}); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v; want %q", err, want)
+	}
+}
@@ -0,0 +1,218 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+)
+
+// runConfig prints the effective configuration of one directory to out:
+// each setting reported by a config.Describer, and the directive or flag
+// that set it.
+func runConfig(wd string, args []string, out io.Writer) (err error) {
+	cexts := make([]config.Configurer, 0, len(languages)+4)
+	cexts = append(cexts,
+		&config.CommonConfigurer{},
+		&updateConfigurer{},
+		&walk.Configurer{},
+		&resolve.Configurer{})
+	for _, lang := range languages {
+		cexts = append(cexts, lang)
+	}
+
+	c := config.New()
+	c.WorkDir = wd
+	fs := flag.NewFlagSet("gazelle", flag.ContinueOnError)
+	fs.Usage = func() {}
+	// Accept the same flags as update, since they affect configuration.
+	for _, cext := range cexts {
+		cext.RegisterFlags(fs, updateCmd.String(), c)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			configUsage(fs)
+			return err
+		}
+		// flag already prints the error; don't print it again.
+		log.Fatal("Try -help for more information.")
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("config: expected at most one directory argument; got %d", fs.NArg())
+	}
+	for _, cext := range cexts {
+		if err := cext.CheckFlags(fs, c); err != nil {
+			return err
+		}
+	}
+	uc := getUpdateConfig(c)
+	defer func() {
+		if werr := uc.diagnostics.write(os.Stderr); err == nil && werr != nil {
+			err = werr
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, lang := range languages {
+		if life, ok := lang.(language.LifecycleManager); ok {
+			life.Before(ctx)
+			defer life.AfterResolvingDeps(ctx)
+		}
+	}
+
+	if len(uc.dirs) != 1 {
+		return fmt.Errorf("config: expected one directory; got %d", len(uc.dirs))
+	}
+	dir := uc.dirs[0]
+	rel, err := filepath.Rel(c.RepoRoot, dir)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	var dirConfig *config.Config
+	var directives []directiveSource
+	walkErr := walk.Walk2(c, cexts, []string{dir}, walk.UpdateDirsMode, func(args walk.Walk2FuncArgs) walk.Walk2FuncResult {
+		if args.Rel != rel {
+			return walk.Walk2FuncResult{}
+		}
+		dirConfig = args.Config
+		pathtools.Prefixes(rel)(func(prefix string) bool {
+			di, err := walk.GetDirInfo(prefix)
+			if err != nil || di.File == nil {
+				return true
+			}
+			for _, d := range di.File.Directives {
+				file, line := args.Config.DirectivePosition(di.File, d)
+				directives = append(directives, directiveSource{
+					rel:   prefix,
+					key:   d.Key,
+					value: d.Value,
+					file:  file,
+					line:  line,
+				})
+			}
+			return true
+		})
+		return walk.Walk2FuncResult{}
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	if dirConfig == nil {
+		return fmt.Errorf("%s: directory is excluded or ignored", dir)
+	}
+
+	fmt.Fprintf(out, "Configuration for //%s:\n", rel)
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, cext := range cexts {
+		d, ok := cext.(config.Describer)
+		if !ok {
+			continue
+		}
+		settings := d.DescribeConfig(dirConfig)
+		if len(settings) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s:\n", describerName(cext))
+		for _, s := range settings {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", s.Directive, s.Value, settingSource(s, directives, setFlags))
+		}
+	}
+	return tw.Flush()
+}
+
+// directiveSource is a directive in a directory or one of its parents.
+type directiveSource struct {
+	rel, key, value, file string
+	line                  int
+}
+
+// settingSource returns a description of where s was set: a file and line,
+// a flag, or "default" if it wasn't set by either.
+func settingSource(s config.Setting, directives []directiveSource, setFlags map[string]bool) string {
+	if s.File != "" && s.Line > 0 {
+		return fmt.Sprintf("%s:%d", s.File, s.Line)
+	} else if s.File != "" {
+		return s.File
+	}
+	want := strings.Join(strings.Fields(s.Value), " ")
+	for i := len(directives) - 1; i >= 0; i-- {
+		d := directives[i]
+		if d.key != s.Directive {
+			continue
+		}
+		// Some settings, like exclude, store values relative to the
+		// repository root instead of the directory with the directive.
+		if strings.Join(strings.Fields(d.value), " ") == want || path.Join(d.rel, d.value) == s.Value {
+			return fmt.Sprintf("%s:%d", d.file, d.line)
+		}
+	}
+	if s.Flag != "" && setFlags[s.Flag] {
+		return "-" + s.Flag + " flag"
+	}
+	return "default"
+}
+
+// describerName returns the name of a Configurer for use as a heading. This
+// is the language name for languages, otherwise the name of the package that
+// defines the Configurer.
+func describerName(cext config.Configurer) string {
+	if lang, ok := cext.(language.Language); ok {
+		return lang.Name()
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", cext), "*")
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func configUsage(fs *flag.FlagSet) {
+	fmt.Fprint(os.Stderr, `usage: gazelle config [flags...] [package-dir]
+
+The config command prints the effective configuration of a directory (the
+working directory if none is given): the value of each setting after
+directives in the directory and its parents and command line flags have been
+applied, and the directive or flag that set it. Settings that weren't set
+either way show "default". The command accepts the same flags as update.
+
+FLAGS:
+
+`)
+	fs.PrintDefaults()
+}
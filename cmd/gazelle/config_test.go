@@ -0,0 +1,96 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfig(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `# gazelle:prefix example.com/repo
+# gazelle:exclude a/skip
+# gazelle:resolve go example.com/ext //third_party:ext
+`,
+		},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `# gazelle:go_naming_convention import
+# gazelle:map_kind go_library my_library //tools:go.bzl
+# gazelle:resolve go example.com/ext //a:ext
+`,
+		},
+		{Path: "a/a.go", Content: "package a"},
+	})
+	defer cleanup()
+
+	var buf bytes.Buffer
+	if err := runConfig(dir, []string{"-build_tags=foo", "-go_naming_convention_external=import", "a"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `Configuration for //a:
+
+config:
+  lang                                            default
+  map_kind  go_library my_library //tools:go.bzl  a/BUILD.bazel:2
+
+walk:
+  build_file_name  BUILD.bazel,BUILD  default
+  generation_mode  create_and_update  default
+  gitignore        false              default
+  exclude          a/skip             BUILD.bazel:2
+
+resolve:
+  resolve  go example.com/ext //a:ext  a/BUILD.bazel:3
+
+proto:
+  proto  default  default
+
+go:
+  prefix                         example.com/repo                      BUILD.bazel:1
+  build_tags                     foo                                   -build_tags flag
+  go_generate_proto              true                                  default
+  go_naming_convention           import                                a/BUILD.bazel:1
+  go_naming_convention_external  import                                -go_naming_convention_external flag
+  go_test                        default                               default
+  go_proto_compilers             @io_bazel_rules_go//proto:go_proto    default
+  go_grpc_compilers              @io_bazel_rules_go//proto:go_grpc_v2  default
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want,+got):\n%s", diff)
+	}
+}
+
+func TestConfigExcluded(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:exclude skip"},
+		{Path: "skip/skip.go", Content: "package skip"},
+	})
+	defer cleanup()
+
+	var buf bytes.Buffer
+	if err := runConfig(dir, []string{"skip"}, &buf); err == nil {
+		t.Fatal("got success; want error for excluded directory")
+	}
+}
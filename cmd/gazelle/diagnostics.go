@@ -0,0 +1,230 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+)
+
+var diagnosticsFormats = []string{"text", "json", "sarif"}
+
+// diagnosticsSink collects diagnostics reported while running the fix and
+// update commands. In text mode, diagnostics are logged as soon as they're
+// reported. In other modes, they're buffered and written all at once by
+// write, since the output is a single document.
+type diagnosticsSink struct {
+	format string
+
+	mu    sync.Mutex
+	diags []config.Diagnostic
+}
+
+var _ config.DiagnosticSink = (*diagnosticsSink)(nil)
+
+func (s *diagnosticsSink) Report(d config.Diagnostic) {
+	if s.format == "text" {
+		log.Print(d)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.diags = append(s.diags, d)
+}
+
+// write renders buffered diagnostics to w. Nothing is written in text mode.
+func (s *diagnosticsSink) write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var doc interface{}
+	switch s.format {
+	case "text":
+		return nil
+	case "json":
+		doc = newDiagnosticsReport(s.diags)
+	case "sarif":
+		doc = newSARIFLog(s.diags)
+	default:
+		return fmt.Errorf("unknown diagnostics format: %q", s.format)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// fileDiagnosticsSink sets File on diagnostics that don't have one. This lets
+// resolvers, which don't know which build file a rule came from, report
+// diagnostics that point to the right place.
+type fileDiagnosticsSink struct {
+	config.DiagnosticSink
+	file string
+}
+
+func (s fileDiagnosticsSink) Report(d config.Diagnostic) {
+	if d.File == "" {
+		d.File = s.file
+	}
+	s.DiagnosticSink.Report(d)
+}
+
+// diagnosticsReport is the document written by -diagnostics_format=json.
+type diagnosticsReport struct {
+	Diagnostics []diagnosticReport `json:"diagnostics"`
+}
+
+type diagnosticReport struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Lang     string `json:"lang,omitempty"`
+	Message  string `json:"message"`
+}
+
+func newDiagnosticsReport(diags []config.Diagnostic) diagnosticsReport {
+	r := diagnosticsReport{Diagnostics: make([]diagnosticReport, 0, len(diags))}
+	for _, d := range diags {
+		r.Diagnostics = append(r.Diagnostics, diagnosticReport{
+			Severity: d.Severity.String(),
+			Code:     d.Code,
+			File:     d.File,
+			Line:     d.Line,
+			Lang:     d.Lang,
+			Message:  d.Message,
+		})
+	}
+	return r
+}
+
+// The types below describe the subset of SARIF 2.1.0 written by
+// -diagnostics_format=sarif. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func newSARIFLog(diags []config.Diagnostic) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "gazelle",
+			InformationURI: "https://github.com/bazelbuild/bazel-gazelle",
+			Rules:          []sarifRule{},
+		}},
+		Results: make([]sarifResult, 0, len(diags)),
+	}
+	codes := make(map[string]bool)
+	for _, d := range diags {
+		codes[d.Code] = true
+		res := sarifResult{
+			RuleID:  d.Code,
+			Level:   sarifLevel(d.Severity),
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.File != "" {
+			loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: d.File, URIBaseID: "%SRCROOT%"},
+			}}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line}
+			}
+			res.Locations = []sarifLocation{loc}
+		}
+		if d.Lang != "" {
+			res.Properties = map[string]string{"lang": d.Lang}
+		}
+		run.Results = append(run.Results, res)
+	}
+	for code := range codes {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: code})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
+
+func sarifLevel(s config.Severity) string {
+	switch s {
+	case config.SeverityError:
+		return "error"
+	case config.SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
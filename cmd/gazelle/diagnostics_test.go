@@ -0,0 +1,235 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testDiagnostics = []config.Diagnostic{
+	{
+		Severity: config.SeverityWarning,
+		Code:     "map-kind-args",
+		File:     "BUILD.bazel",
+		Line:     3,
+		Message:  "expected three arguments",
+	},
+	{
+		Severity: config.SeverityError,
+		Code:     "ambiguous-import",
+		File:     "a/BUILD.bazel",
+		Lang:     "go",
+		Message:  "matches multiple rules",
+	},
+	{
+		Severity: config.SeverityInfo,
+		Code:     "note",
+		Message:  "no file",
+	},
+}
+
+func TestDiagnosticsJSON(t *testing.T) {
+	s := &diagnosticsSink{format: "json"}
+	for _, d := range testDiagnostics {
+		s.Report(d)
+	}
+	var buf bytes.Buffer
+	if err := s.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "diagnostics": [
+    {
+      "severity": "warning",
+      "code": "map-kind-args",
+      "file": "BUILD.bazel",
+      "line": 3,
+      "message": "expected three arguments"
+    },
+    {
+      "severity": "error",
+      "code": "ambiguous-import",
+      "file": "a/BUILD.bazel",
+      "lang": "go",
+      "message": "matches multiple rules"
+    },
+    {
+      "severity": "info",
+      "code": "note",
+      "message": "no file"
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want,+got):\n%s", diff)
+	}
+}
+
+func TestDiagnosticsSARIF(t *testing.T) {
+	s := &diagnosticsSink{format: "sarif"}
+	for _, d := range testDiagnostics {
+		s.Report(d)
+	}
+	var buf bytes.Buffer
+	if err := s.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "gazelle",
+          "informationUri": "https://github.com/bazelbuild/bazel-gazelle",
+          "rules": [
+            {
+              "id": "ambiguous-import"
+            },
+            {
+              "id": "map-kind-args"
+            },
+            {
+              "id": "note"
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "map-kind-args",
+          "level": "warning",
+          "message": {
+            "text": "expected three arguments"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "BUILD.bazel",
+                  "uriBaseId": "%SRCROOT%"
+                },
+                "region": {
+                  "startLine": 3
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "ambiguous-import",
+          "level": "error",
+          "message": {
+            "text": "matches multiple rules"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "a/BUILD.bazel",
+                  "uriBaseId": "%SRCROOT%"
+                }
+              }
+            }
+          ],
+          "properties": {
+            "lang": "go"
+          }
+        },
+        {
+          "ruleId": "note",
+          "level": "note",
+          "message": {
+            "text": "no file"
+          }
+        }
+      ]
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want,+got):\n%s", diff)
+	}
+}
+
+func TestDiagnosticsResolveFile(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    importpath = "example.com/dup",
+)
+`,
+		},
+		{
+			Path: "b/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "b",
+    importpath = "example.com/dup",
+)
+`,
+		},
+		{
+			Path:    "c/c.go",
+			Content: `package c; import _ "example.com/dup"`,
+		},
+	})
+	defer cleanup()
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if err := runGazelle(dir, []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `c/BUILD.bazel: rule //c imports "example.com/dup" which matches multiple rules`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log does not contain %q\n--begin--\n%s--end--\n", want, buf.String())
+	}
+}
+
+func TestDiagnosticsFormatInvalid(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{{Path: "WORKSPACE"}})
+	defer cleanup()
+
+	want := `unrecognized diagnostics format: "xml"`
+	if err := runGazelle(dir, []string{"-diagnostics_format=xml"}); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v; want %q", err, want)
+	}
+}
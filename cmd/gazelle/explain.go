@@ -0,0 +1,101 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+// explainer collects the steps taken to resolve one import string in each
+// rule, for -explain.
+type explainer struct {
+	imp string
+
+	mu    sync.Mutex
+	rules []ruleExplanation
+}
+
+type ruleExplanation struct {
+	from  label.Label
+	steps []resolve.ExplainStep
+}
+
+// forRule returns a resolve.Explainer that records steps for the rule from.
+// It should be installed with resolve.SetExplainer in the rule's
+// configuration before the rule is resolved, then passed to finishRule.
+func (e *explainer) forRule(from label.Label) *ruleExplainer {
+	return &ruleExplainer{imp: e.imp, from: from}
+}
+
+// finishRule records the steps collected by re, if there were any.
+func (e *explainer) finishRule(re *ruleExplainer) {
+	if len(re.steps) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, ruleExplanation{from: re.from, steps: re.steps})
+}
+
+// write prints collected explanations to w, sorted by rule label.
+func (e *explainer) write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sort.Slice(e.rules, func(i, j int) bool {
+		return e.rules[i].from.String() < e.rules[j].from.String()
+	})
+
+	b := &strings.Builder{}
+	if len(e.rules) == 0 {
+		fmt.Fprintf(b, "no rules resolved import %q\n", e.imp)
+	}
+	for i, r := range e.rules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "%s resolving %q:\n", r.from, e.imp)
+		for _, s := range r.steps {
+			fmt.Fprintf(b, "  %s\n", s)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ruleExplainer records steps for a single rule. A rule is resolved on one
+// goroutine, so it doesn't need to synchronize.
+type ruleExplainer struct {
+	imp   string
+	from  label.Label
+	steps []resolve.ExplainStep
+}
+
+var _ resolve.Explainer = (*ruleExplainer)(nil)
+
+func (re *ruleExplainer) Explaining(imp string) bool {
+	return imp == re.imp
+}
+
+func (re *ruleExplainer) Explain(step resolve.ExplainStep) {
+	re.steps = append(re.steps, step)
+}
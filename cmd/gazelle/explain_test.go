@@ -0,0 +1,137 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExplain(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/dup",  # keep
+)
+`,
+		},
+		{
+			Path:    "a/a.go",
+			Content: "package a",
+		},
+		{
+			Path:    "vendor/example.com/dup/dup.go",
+			Content: "package dup",
+		},
+		{
+			Path:    "c/c.go",
+			Content: `package c; import _ "example.com/dup"`,
+		},
+		{
+			Path: "d/BUILD.bazel",
+			Content: `# gazelle:resolve go example.com/other //x
+
+# gazelle:resolve go example.com/dup //d:override
+`,
+		},
+		{
+			Path:    "d/d.go",
+			Content: `package d; import _ "example.com/dup"`,
+		},
+		{
+			Path:    "e/e.go",
+			Content: `package e; import _ "example.com/unrelated"`,
+		},
+	})
+	defer cleanup()
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if err := runGazelle(dir, []string{"-explain=example.com/dup", "-external=static", "-resolve_jobs=4"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `//c resolving "example.com/dup":
+  override: no # gazelle:resolve or # gazelle:resolve_regexp directive matched
+  index: found //a, //vendor/example.com/dup
+  go: //vendor/example.com/dup won over //a: vendored libraries are preferred
+  go: resolved to //vendor/example.com/dup
+
+//d resolving "example.com/dup":
+  override: # gazelle:resolve matched; resolved to //d:override (d/BUILD.bazel:3)
+  go: resolved to //d:override
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want,+got):\n%s", diff)
+	}
+}
+
+func TestExplainExternal(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "WORKSPACE",
+			Content: `
+go_repository(
+    name = "com_example_ext",
+    importpath = "example.com/ext",
+)
+`,
+		},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo\n# gazelle:go_naming_convention import",
+		},
+		{
+			Path:    "c/c.go",
+			Content: `package c; import _ "example.com/ext/sub"`,
+		},
+	})
+	defer cleanup()
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if err := runGazelle(dir, []string{"-explain=example.com/ext/sub", "-external=static"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `//c resolving "example.com/ext/sub":
+  override: no # gazelle:resolve or # gazelle:resolve_regexp directive matched
+  index: no go rules found
+  cross-resolver: proto returned nothing
+  external: RemoteCache.RootStatic found repository com_example_ext with prefix example.com/ext
+  external: using the import naming convention (repository uses import_alias; same as the current directory) (BUILD.bazel:2)
+  go: resolved to @com_example_ext//sub
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want,+got):\n%s", diff)
+	}
+}
@@ -21,17 +21,22 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/bazelbuild/buildtools/build"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
 	gzflag "github.com/bazelbuild/bazel-gazelle/flag"
+	"github.com/bazelbuild/bazel-gazelle/internal/module"
 	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
 	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/language"
@@ -47,23 +52,40 @@ import (
 // includes some additional fields that aren't relevant to other packages.
 type updateConfig struct {
 	dirs                   []string
+	changed                *changedFiles
+	mode                   string
 	emit                   emitFunc
 	repos                  []repo.Repo
 	workspaceFiles         []*rule.File
 	walkMode               walk.Mode
 	patchPath              string
 	patchBuffer            bytes.Buffer
+	jsonReport             *jsonReport
+	diagnostics            *diagnosticsSink
+	explainer              *explainer
 	print0                 bool
 	profile                profiler
 	removeNoopKeepComments bool
+	generateJobs           int
+	resolveJobs            int
+	indexCache             *resolve.IndexCache
+	indexCachePath         string
+	fileCachePath          string
+	snapshot               *merger.Snapshot
+	snapshotPath           string
+	reportOverwrites       string
+	overwrites             atomic.Int64
 }
 
 type emitFunc func(c *config.Config, f *rule.File) error
 
+var reportOverwritesModes = []string{"", "warn", "error"}
+
 var modeFromName = map[string]emitFunc{
 	"print": printFile,
 	"fix":   fixFile,
 	"diff":  diffFile,
+	"json":  jsonFile,
 }
 
 const updateName = "_update"
@@ -75,12 +97,17 @@ func getUpdateConfig(c *config.Config) *updateConfig {
 var _ config.Configurer = (*updateConfigurer)(nil)
 
 type updateConfigurer struct {
-	mode           string
-	recursive      bool
-	knownImports   []string
-	repoConfigPath string
-	cpuProfile     string
-	memProfile     string
+	mode              string
+	recursive         bool
+	knownImports      []string
+	repoConfigPath    string
+	cpuProfile        string
+	memProfile        string
+	diagnosticsFormat string
+	explainImport     string
+	cacheDir          string
+	changedSince      string
+	mergeSnapshot     string
 }
 
 func (ucr *updateConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
@@ -89,55 +116,98 @@ func (ucr *updateConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *conf
 
 	c.ShouldFix = cmd == "fix"
 
-	fs.StringVar(&ucr.mode, "mode", "fix", "print: prints all of the updated BUILD files\n\tfix: rewrites all of the BUILD files in place\n\tdiff: computes the rewrite but then just does a diff")
+	fs.StringVar(&ucr.mode, "mode", "fix", "print: prints all of the updated BUILD files\n\tfix: rewrites all of the BUILD files in place\n\tdiff: computes the rewrite but then just does a diff\n\tjson: computes the rewrite but then just reports changed rules as JSON")
 	fs.BoolVar(&ucr.recursive, "r", true, "when true, gazelle will update subdirectories recursively")
-	fs.StringVar(&uc.patchPath, "patch", "", "when set with -mode=diff, gazelle will write to a file instead of stdout")
+	fs.StringVar(&uc.patchPath, "patch", "", "when set with -mode=diff or -mode=json, gazelle will write to a file instead of stdout")
 	fs.BoolVar(&uc.print0, "print0", false, "when set with -mode=fix, gazelle will print the names of rewritten files separated with \\0 (NULL)")
 	fs.StringVar(&ucr.cpuProfile, "cpuprofile", "", "write cpu profile to `file`")
 	fs.StringVar(&ucr.memProfile, "memprofile", "", "write memory profile to `file`")
 	fs.Var(&gzflag.MultiFlag{Values: &ucr.knownImports}, "known_import", "import path for which external resolution is skipped (can specify multiple times)")
 	fs.StringVar(&ucr.repoConfigPath, "repo_config", "", "file where Gazelle should load repository configuration. Defaults to WORKSPACE.")
 	fs.BoolVar(&uc.removeNoopKeepComments, "remove_noop_keep_comments", false, "when set, gazelle will remove noop keep comments from BUILD files")
+	fs.IntVar(&uc.generateJobs, "generate_jobs", 1, "maximum number of directories for which rules may be generated concurrently. Only languages that support concurrent generation are run concurrently.")
+	fs.StringVar(&ucr.diagnosticsFormat, "diagnostics_format", "text", "format of errors and warnings written to stderr: text, json, or sarif. json and sarif output is written as a single document when gazelle finishes.")
+	fs.StringVar(&ucr.explainImport, "explain", "", "import string to explain. gazelle prints each step taken to resolve this import to stderr, for every rule that imports it.")
+	fs.IntVar(&uc.resolveJobs, "resolve_jobs", 1, "maximum number of packages for which dependencies may be resolved concurrently. Only resolvers that support concurrent resolution are run concurrently.")
+	fs.StringVar(&ucr.changedSince, "changed_since", "", "git revision. When set, gazelle only updates the packages and directories containing files that changed since this revision. Directory arguments are not allowed, and -r is ignored.")
+	fs.StringVar(&ucr.mergeSnapshot, "merge_snapshot", "", "file where gazelle records the attribute values it generated. When set, gazelle merges generated rules with a three-way merge, keeping changes made by hand since the last run. Relative paths are relative to the repository root.")
+	fs.StringVar(&uc.reportOverwrites, "report_overwrites", "", "warn or error. When set, gazelle reports mergeable attributes of existing rules that were edited by hand, where merging would replace or drop values not marked with # keep. With error, gazelle exits with a non-zero status after writing its output if any are found.")
+	fs.StringVar(&ucr.cacheDir, "cache_dir", "", "directory where gazelle may cache information between runs. When set, rules in build files that haven't changed since the last run are not indexed again, and directory listings and information extracted from source files are reused while they're unchanged.")
 }
 
 func (ucr *updateConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
 	uc := getUpdateConfig(c)
 
 	var ok bool
+	uc.mode = ucr.mode
 	uc.emit, ok = modeFromName[ucr.mode]
 	if !ok {
 		return fmt.Errorf("unrecognized emit mode: %q", ucr.mode)
 	}
-	if uc.patchPath != "" && ucr.mode != "diff" {
-		return fmt.Errorf("-patch set but -mode is %s, not diff", ucr.mode)
+	if uc.patchPath != "" && ucr.mode != "diff" && ucr.mode != "json" {
+		return fmt.Errorf("-patch set but -mode is %s, not diff or json", ucr.mode)
+	}
+	if ucr.mode == "json" {
+		uc.jsonReport = &jsonReport{}
 	}
 	if uc.patchPath != "" && !filepath.IsAbs(uc.patchPath) {
 		uc.patchPath = filepath.Join(c.WorkDir, uc.patchPath)
 	}
+	if !slices.Contains(diagnosticsFormats, ucr.diagnosticsFormat) {
+		return fmt.Errorf("unrecognized diagnostics format: %q", ucr.diagnosticsFormat)
+	}
+	uc.diagnostics = &diagnosticsSink{format: ucr.diagnosticsFormat}
+	if !slices.Contains(reportOverwritesModes, uc.reportOverwrites) {
+		return fmt.Errorf("-report_overwrites must be warn or error, got %q", uc.reportOverwrites)
+	}
+	c.Diagnostics = uc.diagnostics
+	if ucr.explainImport != "" {
+		uc.explainer = &explainer{imp: ucr.explainImport}
+	}
+	if uc.generateJobs < 1 {
+		return fmt.Errorf("-generate_jobs must be at least 1, got %d", uc.generateJobs)
+	}
+	if uc.resolveJobs < 1 {
+		return fmt.Errorf("-resolve_jobs must be at least 1, got %d", uc.resolveJobs)
+	}
 	p, err := newProfiler(ucr.cpuProfile, ucr.memProfile)
 	if err != nil {
 		return err
 	}
 	uc.profile = p
 
-	dirs := fs.Args()
-	if len(dirs) == 0 {
-		dirs = []string{"."}
-	}
-	uc.dirs = make([]string, len(dirs))
-	for i, arg := range dirs {
-		dir := arg
-		if !filepath.IsAbs(dir) {
-			dir = filepath.Join(c.WorkDir, dir)
+	if ucr.changedSince != "" {
+		// Only update directories with changes. Subdirectories of changed
+		// directories are not updated unless they changed, too.
+		if fs.NArg() > 0 {
+			return fmt.Errorf("-changed_since may not be used with directory arguments")
 		}
-		dir, err = filepath.EvalSymlinks(dir)
+		uc.changed, err = findChangedFiles(c, ucr.changedSince)
 		if err != nil {
-			return fmt.Errorf("%s: failed to resolve symlinks: %v", arg, err)
+			return err
 		}
-		if !isDescendingDir(dir, c.RepoRoot) {
-			return fmt.Errorf("%s: not a subdirectory of repo root %s", arg, c.RepoRoot)
+		uc.dirs = uc.changed.walkDirs(c.RepoRoot)
+		ucr.recursive = false
+	} else {
+		dirs := fs.Args()
+		if len(dirs) == 0 {
+			dirs = []string{"."}
+		}
+		uc.dirs = make([]string, len(dirs))
+		for i, arg := range dirs {
+			dir := arg
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(c.WorkDir, dir)
+			}
+			dir, err = filepath.EvalSymlinks(dir)
+			if err != nil {
+				return fmt.Errorf("%s: failed to resolve symlinks: %v", arg, err)
+			}
+			if !isDescendingDir(dir, c.RepoRoot) {
+				return fmt.Errorf("%s: not a subdirectory of repo root %s", arg, c.RepoRoot)
+			}
+			uc.dirs[i] = dir
 		}
-		uc.dirs[i] = dir
 	}
 
 	indexAll := c.IndexLibraries && !c.IndexLazy
@@ -190,6 +260,34 @@ func (ucr *updateConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) erro
 		}
 	}
 
+	if ucr.cacheDir != "" {
+		cacheDir := ucr.cacheDir
+		if !filepath.IsAbs(cacheDir) {
+			cacheDir = filepath.Join(c.WorkDir, cacheDir)
+		}
+		uc.indexCachePath = indexCachePath(cacheDir, c)
+		uc.indexCache, err = resolve.LoadIndexCache(uc.indexCachePath, indexCacheKey(fs, c, ucr.repoConfigPath))
+		if err != nil {
+			log.Printf("loading index cache: %v", err)
+		}
+		uc.fileCachePath = fileCachePath(cacheDir, c)
+		c.FileCache, err = config.LoadFileCache(uc.fileCachePath)
+		if err != nil {
+			log.Printf("loading file cache: %v", err)
+		}
+	}
+
+	if ucr.mergeSnapshot != "" {
+		uc.snapshotPath = ucr.mergeSnapshot
+		if !filepath.IsAbs(uc.snapshotPath) {
+			uc.snapshotPath = filepath.Join(c.RepoRoot, uc.snapshotPath)
+		}
+		uc.snapshot, err = merger.LoadSnapshot(uc.snapshotPath)
+		if err != nil {
+			return fmt.Errorf("loading merge snapshot: %v", err)
+		}
+	}
+
 	// If the repo configuration file is not WORKSPACE, also load WORKSPACE
 	// and any declared macro files so we can apply fixes.
 	workspacePath := wspace.FindWORKSPACEFile(c.RepoRoot)
@@ -276,28 +374,108 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 	if err != nil {
 		return err
 	}
+	uc := getUpdateConfig(c)
+	defer func() {
+		if werr := uc.diagnostics.write(os.Stderr); err == nil && werr != nil {
+			err = werr
+		}
+	}()
 
-	mrslv := newMetaResolver()
-	kinds := make(map[string]rule.KindInfo)
-	loads := genericLoads
+	defer func() {
+		if err := uc.profile.stop(); err != nil {
+			log.Printf("stopping profiler: %v", err)
+		}
+	}()
+
+	u := newUpdater(c, cexts)
+	if uc.changed != nil {
+		// Directories containing changed files and their parents are walked,
+		// but only packages that own changed files are updated.
+		u.indexOnly = func(rel string) bool { return !uc.changed.shouldUpdate(rel) }
+	}
+	if err = fixRepoFiles(c, u.loads); err != nil {
+		return err
+	}
+	return u.run(uc.dirs, uc.walkMode)
+}
+
+// updater generates, indexes, resolves, and emits build files in a set of
+// directories. The watch command reuses an updater, including its rule
+// index, each time files change.
+type updater struct {
+	c         *config.Config
+	cexts     []config.Configurer
+	mrslv     *metaResolver
+	kinds     map[string]rule.KindInfo
+	loads     []rule.LoadInfo
+	ruleIndex *resolve.RuleIndex
+
+	// onVisit, if set, is called in each directory the walk visits.
+	onVisit func(args walk.Walk2FuncArgs)
+
+	// indexOnly, if set, reports whether rules in a directory that would
+	// otherwise be updated should only be indexed.
+	indexOnly func(rel string) bool
+
+	// indexed, if set, reports whether rules in a directory are already in
+	// ruleIndex from an earlier run. Those rules aren't added again when
+	// the directory is visited but not updated.
+	indexed func(rel string) bool
+
+	// indexCache holds index records from earlier runs. It's nil if there's
+	// no cache or the updater is for a nested repository.
+	indexCache *resolve.IndexCache
+
+	// snapshot holds attribute values generated by earlier runs, used for
+	// three-way merges. It's nil if -merge_snapshot isn't set or the updater
+	// is for a nested repository.
+	snapshot *merger.Snapshot
+
+	// linkedRepos are the names of other repositories' indexes linked to
+	// ruleIndex by the last run.
+	linkedRepos []string
+}
+
+func newUpdater(c *config.Config, cexts []config.Configurer) *updater {
+	u := &updater{
+		c:     c,
+		cexts: cexts,
+		mrslv: newMetaResolver(),
+		kinds: make(map[string]rule.KindInfo),
+		loads: genericLoads,
+	}
 	exts := make([]interface{}, 0, len(languages))
 	for _, lang := range languages {
 		for kind, info := range lang.Kinds() {
-			mrslv.AddBuiltin(kind, lang)
-			kinds[kind] = info
+			u.mrslv.AddBuiltin(kind, lang)
+			u.kinds[kind] = info
 		}
 		if moduleAwareLang, ok := lang.(language.ModuleAwareLanguage); ok {
-			loads = append(loads, moduleAwareLang.ApparentLoads(c.ModuleToApparentName)...)
+			u.loads = append(u.loads, moduleAwareLang.ApparentLoads(c.ModuleToApparentName)...)
 		} else {
-			loads = append(loads, lang.Loads()...)
+			u.loads = append(u.loads, lang.Loads()...)
 		}
 		exts = append(exts, lang)
 	}
-	ruleIndex := resolve.NewRuleIndex(mrslv.Resolver, exts...)
-
-	if err = fixRepoFiles(c, loads); err != nil {
-		return err
+	u.ruleIndex = resolve.NewRuleIndex(u.mrslv.Resolver, exts...)
+	uc := getUpdateConfig(c)
+	if uc.indexCache != nil {
+		u.indexCache = uc.indexCache
+		u.ruleIndex.SetCache(uc.indexCache)
 	}
+	u.snapshot = uc.snapshot
+	return u
+}
+
+// run visits dirs with the given walk mode. It generates rules in
+// directories that should be updated, indexes library rules, resolves
+// dependencies, and emits build files.
+//
+// With -nested_repos=update, repositories nested in the repository are
+// updated, too. Each gets its own updater and rule index, and the indexes
+// are linked so rules can depend on rules across repository boundaries.
+func (u *updater) run(dirs []string, mode walk.Mode) (err error) {
+	c, uc := u.c, getUpdateConfig(u.c)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -307,255 +485,580 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 		}
 	}
 
-	// Visit all directories in the repository.
-	var visits []visitRecord
-	uc := getUpdateConfig(c)
+	rule.RemoveNoopKeepComments = uc.removeNoopKeepComments || c.ShouldFix
+
+	// Visit all directories in the repository, then in nested repositories
+	// found along the way.
+	repos := []*repoUpdate{{u: u, dirs: dirs}}
+	var walkErr error
+	for i := 0; i < len(repos); i++ {
+		ru := repos[i]
+		// The walk applies directives in the root build file to the root
+		// configuration, so copy it first. Nested repositories don't
+		// inherit those directives.
+		base := ru.u.c.Clone()
+		visits, nestedRepos, err := ru.u.generate(ru.dirs, mode)
+		ru.visits = visits
+		walkErr = errors.Join(walkErr, err)
+		for _, rel := range nestedRepos {
+			nu, err := ru.u.newNestedUpdater(base, rel)
+			if err != nil {
+				walkErr = errors.Join(walkErr, err)
+				continue
+			}
+			if nestedDirs, ok := nestedRepoDirs(nu.c.RepoRoot, ru.dirs, mode); ok {
+				repos = append(repos, &repoUpdate{u: nu, dirs: nestedDirs})
+			}
+		}
+	}
+
+	for _, lang := range languages {
+		if finishable, ok := lang.(language.FinishableLanguage); ok {
+			finishable.DoneGeneratingRules()
+		}
+	}
+
+	if walkErr != nil {
+		return walkErr
+	}
+
+	if uc.indexCache != nil {
+		if err := uc.indexCache.Save(uc.indexCachePath); err != nil {
+			log.Printf("saving index cache: %v", err)
+		}
+	}
+	if c.FileCache != nil {
+		fsys := c.FS
+		if fsys == nil {
+			fsys = os.DirFS(c.RepoRoot)
+		}
+		if err := c.FileCache.Save(uc.fileCachePath, fsys); err != nil {
+			log.Printf("saving file cache: %v", err)
+		}
+	}
+
+	// Finish building the indexes for dependency resolution.
+	linkRepoIndexes(repos)
+	for _, ru := range repos {
+		ru.u.ruleIndex.Finish()
+	}
+
+	// Resolve dependencies.
+	rc, cleanupRc := repo.NewRemoteCache(uc.repos)
 	defer func() {
-		if err := uc.profile.stop(); err != nil {
-			log.Printf("stopping profiler: %v", err)
+		if cerr := cleanupRc(); err == nil && cerr != nil {
+			err = cerr
 		}
 	}()
+	if err = maybePopulateRemoteCacheFromGoMod(c, rc); err != nil {
+		log.Print(err)
+	}
+	resolveVisit := func(u *updater, v visitRecord) {
+		// Resolvers don't know which file a rule came from, so attribute their
+		// diagnostics to the package's build file. v.c belongs to this
+		// directory alone, so it's safe to modify here.
+		v.c.Diagnostics = fileDiagnosticsSink{DiagnosticSink: v.c.Diagnostics, file: v.c.RelFile(v.file)}
+		for i, r := range v.rules {
+			from := label.New(u.c.RepoName, v.pkgRel, r.Name())
+			if rslv := u.mrslv.Resolver(r, v.pkgRel); rslv != nil {
+				if uc.explainer == nil {
+					rslv.Resolve(v.c, u.ruleIndex, rc, r, v.imports[i], from)
+				} else {
+					re := uc.explainer.forRule(from)
+					resolve.SetExplainer(v.c, re)
+					rslv.Resolve(v.c, u.ruleIndex, rc, r, v.imports[i], from)
+					uc.explainer.finishRule(re)
+				}
+			}
+		}
+		kinds := unionKindInfoMaps(u.kinds, v.mappedKindInfo, v.c.ManagedAttrs)
+		conflicts := uc.mergeFile(v.file, v.empty, v.rules, merger.PostResolve,
+			kinds,
+			v.c.AliasMap,
+			u.snapshot.Base(v.pkgRel),
+		)
+		reportMergeConflicts(v.c, v.file, conflicts)
+		u.snapshot.Record(v.pkgRel, v.rules, kinds)
+	}
+	if uc.resolveJobs <= 1 {
+		for _, ru := range repos {
+			for _, v := range ru.visits {
+				resolveVisit(ru.u, v)
+			}
+		}
+	} else {
+		// Each package's rules are resolved and merged into its own file, so
+		// packages may be processed in any order without changing the output.
+		// Packages with resolvers that aren't safe for concurrent use are
+		// resolved here, in order, while other packages are resolved
+		// in the background.
+		sem := make(chan struct{}, uc.resolveJobs)
+		var wg sync.WaitGroup
+		for _, ru := range repos {
+			for _, v := range ru.visits {
+				if !canResolveConcurrently(ru.u.mrslv, v) {
+					resolveVisit(ru.u, v)
+					continue
+				}
+				sem <- struct{}{}
+				wg.Add(1)
+				go func(u *updater, v visitRecord) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					resolveVisit(u, v)
+				}(ru.u, v)
+			}
+		}
+		wg.Wait()
+	}
+	if uc.explainer != nil {
+		if err := uc.explainer.write(log.Writer()); err != nil {
+			return err
+		}
+	}
+	for _, lang := range languages {
+		if life, ok := lang.(language.LifecycleManager); ok {
+			life.AfterResolvingDeps(ctx)
+		}
+	}
 
-	rule.RemoveNoopKeepComments = uc.removeNoopKeepComments || c.ShouldFix
+	// Emit merged files.
+	var exit error
+	for _, ru := range repos {
+		for _, v := range ru.visits {
+			merger.FixLoads(v.file, applyKindMappings(v.mappedKinds, ru.u.loads))
+			if err := uc.emit(v.c, v.file); err != nil {
+				if err == errExit {
+					exit = err
+				} else {
+					log.Print(err)
+				}
+			}
+		}
+	}
+	// Only record generated values if build files were written.
+	if uc.mode == "fix" {
+		if err := uc.snapshot.Save(uc.snapshotPath); err != nil {
+			log.Printf("saving merge snapshot: %v", err)
+		}
+	}
+	if uc.jsonReport != nil {
+		if err := writeJSONReport(uc); err != nil {
+			return err
+		}
+	}
+	if uc.patchPath != "" {
+		if err := os.WriteFile(uc.patchPath, uc.patchBuffer.Bytes(), 0o666); err != nil {
+			return err
+		}
+	}
+	if n := uc.overwrites.Load(); n > 0 && uc.reportOverwrites == "error" {
+		return fmt.Errorf("found %d attributes edited by hand that would be overwritten; mark values to preserve with # keep or configure them with directives", n)
+	}
+
+	return exit
+}
+
+// generate walks dirs with the given walk mode, generating and merging rules
+// in directories that should be updated and indexing rules. It returns
+// records for updated directories and, with -nested_repos=update, the roots
+// of nested repositories found, relative to u's repository root.
+func (u *updater) generate(dirs []string, mode walk.Mode) ([]visitRecord, []string, error) {
+	c, cexts, uc := u.c, u.cexts, getUpdateConfig(u.c)
+	mrslv, kinds, ruleIndex := u.mrslv, u.kinds, u.ruleIndex
+
+	var visits []visitRecord
+	var nestedRepos []string
+
+	// Rules may be generated concurrently, but RelsToVisit must be returned
+	// from the walk callback before the walk finishes, so lazy indexing
+	// requires generation in walk order.
+	generateJobs := uc.generateJobs
+	if c.IndexLazy {
+		generateJobs = 1
+	}
+	genQueue := newGenerateQueue(generateJobs)
+	cacheKeyer := &indexCacheKeyer{repoRoot: c.RepoRoot}
 
-	walkErr := walk.Walk2(c, cexts, uc.dirs, uc.walkMode, func(args walk.Walk2FuncArgs) walk.Walk2FuncResult {
+	walkErr := walk.Walk2(c, cexts, dirs, mode, func(args walk.Walk2FuncArgs) walk.Walk2FuncResult {
 		dir := args.Dir
 		rel := args.Rel
 		c := args.Config
-		update := args.Update
+		update := args.Update && (u.indexOnly == nil || !u.indexOnly(rel))
 		f := args.File
-		subdirs := args.Subdirs
-		regularFiles := args.RegularFiles
-		genFiles := args.GenFiles
+
+		if u.onVisit != nil {
+			u.onVisit(args)
+		}
+		if walk.UpdateNestedRepos(c) {
+			nestedRepos = append(nestedRepos, args.NestedRepos...)
+		}
 
 		mrslv.AliasedKinds(rel, c.AliasMap)
 		// If this file is ignored or if Gazelle was not asked to update this
 		// directory, just index the build file and move on.
 		if !update {
-			for _, repl := range c.KindMap {
-				mrslv.MappedKind(rel, repl)
+			// Keys must be computed during the walk, since generation may
+			// finish after the walk does.
+			var cacheKey string
+			if u.indexCache != nil && c.IndexLibraries && f != nil {
+				cacheKey = cacheKeyer.key(rel)
 			}
-			if c.IndexLibraries && f != nil {
-				for _, r := range f.Rules {
-					ruleIndex.AddRule(c, r, f)
+			return genQueue.add(args, nil, func(generatedDir) walk.Walk2FuncResult {
+				if u.indexed != nil && u.indexed(rel) {
+					return walk.Walk2FuncResult{}
 				}
-			}
-			return walk.Walk2FuncResult{}
+				for _, repl := range c.KindMap {
+					mrslv.MappedKind(rel, repl)
+				}
+				for _, repls := range c.ConditionalKindMap {
+					for _, repl := range repls {
+						mrslv.MappedKind(rel, repl)
+					}
+				}
+				if c.IndexLibraries && f != nil {
+					ruleIndex.AddFileRules(c, f, cacheKey)
+				}
+				return walk.Walk2FuncResult{}
+			})
 		}
 
 		// Fix any problems in the file.
+		langs := filterLanguages(c, languages)
 		if f != nil {
-			for _, l := range filterLanguages(c, languages) {
+			for _, l := range langs {
 				l.Fix(c, f)
 			}
+		} else {
+			// Languages in update_only mode don't create new build files.
+			var creating []language.Language
+			for _, l := range langs {
+				if !args.IsUpdateOnly(l.Name()) {
+					creating = append(creating, l)
+				}
+			}
+			langs = creating
 		}
 
-		// Generate rules.
-		var empty, gen []*rule.Rule
-		var imports []interface{}
-		var relsToVisit []string
-		for _, l := range filterLanguages(c, languages) {
-			res := l.GenerateRules(language.GenerateArgs{
-				Config:       c,
-				Dir:          dir,
-				Rel:          rel,
-				File:         f,
-				Subdirs:      subdirs,
-				RegularFiles: regularFiles,
-				GenFiles:     genFiles,
-				OtherEmpty:   empty,
-				OtherGen:     gen,
-			})
-			if len(res.Gen) != len(res.Imports) {
-				log.Panicf("%s: language %s generated %d rules but returned %d imports", rel, l.Name(), len(res.Gen), len(res.Imports))
-			}
-			empty = append(empty, res.Empty...)
-			gen = append(gen, res.Gen...)
-			imports = append(imports, res.Imports...)
-			if c.IndexLibraries {
-				relsToVisit = append(relsToVisit, res.RelsToIndex...)
+		// Generate rules, then merge and index them once generation is done.
+		return genQueue.add(args, langs, func(res generatedDir) walk.Walk2FuncResult {
+			empty, gen, imports := res.empty, res.gen, res.imports
+			relsToVisit := res.relsToVisit
+			f := f
+			if f == nil && len(gen) == 0 {
+				return walk.Walk2FuncResult{RelsToVisit: relsToVisit}
 			}
-		}
-		if f == nil && len(gen) == 0 {
-			return walk.Walk2FuncResult{RelsToVisit: relsToVisit}
-		}
 
-		// Apply and record relevant kind mappings.
-		var (
-			mappedKinds    []config.MappedKind
-			mappedKindInfo = make(map[string]rule.KindInfo)
-		)
-		// We apply map_kind to all rules, including pre-existing ones.
-		var allRules []*rule.Rule
-		allRules = append(allRules, gen...)
-		if f != nil {
-			allRules = append(allRules, f.Rules...)
-		}
+			// Apply and record relevant kind mappings.
+			var (
+				mappedKinds    []config.MappedKind
+				mappedKindInfo = make(map[string]rule.KindInfo)
+			)
+			// We apply map_kind to all rules, including pre-existing ones.
+			var allRules []*rule.Rule
+			allRules = append(allRules, gen...)
+			if f != nil {
+				allRules = append(allRules, f.Rules...)
+			}
 
-		maybeRecordReplacement := func(ruleKind string) (*string, error) {
-			var repl *config.MappedKind
-			repl, err = lookupMapKindReplacement(c.KindMap, ruleKind)
-			if err != nil {
-				return nil, err
+			// Conditional replacements check the tags of rules. Generated
+			// rules don't have tags, so the tags of the existing rule with the
+			// same name are used instead.
+			existingTags := make(map[string][]string)
+			if f != nil {
+				for _, r := range f.Rules {
+					if tags := r.AttrStrings("tags"); tags != nil {
+						existingTags[r.Name()] = tags
+					}
+				}
 			}
-			if repl != nil {
-				mappedKindInfo[repl.KindName] = kinds[ruleKind]
-				mappedKinds = append(mappedKinds, *repl)
-				mrslv.MappedKind(rel, *repl)
-				return &repl.KindName, nil
+			ruleTags := func(r *rule.Rule) []string {
+				if tags := r.AttrStrings("tags"); tags != nil {
+					return tags
+				}
+				return existingTags[r.Name()]
 			}
-			return nil, nil
-		}
 
-		var errs []error
-		for _, r := range allRules {
-			if replacementName, err := maybeRecordReplacement(r.Kind()); err != nil {
-				errs = append(errs, fmt.Errorf("looking up mapped kind: %w", err))
-			} else if replacementName != nil {
-				r.SetKind(*replacementName)
+			maybeRecordReplacement := func(ruleKind string, tags []string) (*config.MappedKind, error) {
+				repl, err := lookupMapKindReplacement(c, ruleKind, rel, tags)
+				if err != nil {
+					return nil, err
+				}
+				if repl != nil {
+					mappedKindInfo[repl.KindName] = repl.MapKindInfo(kinds[ruleKind])
+					mappedKinds = append(mappedKinds, *repl)
+					mrslv.MappedKind(rel, *repl)
+				}
+				return repl, nil
 			}
 
-			for i, arg := range r.Args() {
-				// Only check the first arg - this supports the maybe(java_library, ...) pattern,
-				// but avoids potential false positives from other uses of symbols.
-				if i != 0 {
-					break
+			var errs []error
+			for _, r := range allRules {
+				if repl, err := maybeRecordReplacement(r.Kind(), ruleTags(r)); err != nil {
+					errs = append(errs, fmt.Errorf("looking up mapped kind: %w", err))
+				} else if repl != nil {
+					r.SetKind(repl.KindName)
+					repl.MapAttrs(r)
 				}
-				if ident, ok := arg.(*build.Ident); ok {
-					// Don't allow re-mapping symbols that aren't known loads of a plugin.
-					if _, knownKind := kinds[ident.Name]; !knownKind {
-						continue
+
+				for i, arg := range r.Args() {
+					// Only check the first arg - this supports the maybe(java_library, ...) pattern,
+					// but avoids potential false positives from other uses of symbols.
+					if i != 0 {
+						break
 					}
-					if replacementName, err := maybeRecordReplacement(ident.Name); err != nil {
-						errs = append(errs, fmt.Errorf("looking up mapped kind: %w", err))
-					} else if replacementName != nil {
-						if err := r.UpdateArg(i, &build.Ident{Name: *replacementName}); err != nil {
-							log.Panicf("%s: %v", rel, err)
+					if ident, ok := arg.(*build.Ident); ok {
+						// Don't allow re-mapping symbols that aren't known loads of a plugin.
+						if _, knownKind := kinds[ident.Name]; !knownKind {
+							continue
+						}
+						if repl, err := maybeRecordReplacement(ident.Name, ruleTags(r)); err != nil {
+							errs = append(errs, fmt.Errorf("looking up mapped kind: %w", err))
+						} else if repl != nil {
+							repl.MapAttrs(r)
+							if err := r.UpdateArg(i, &build.Ident{Name: repl.KindName}); err != nil {
+								log.Panicf("%s: %v", rel, err)
+							}
 						}
 					}
 				}
 			}
-		}
-		for _, r := range empty {
-			if repl, ok := c.KindMap[r.Kind()]; ok {
-				mappedKindInfo[repl.KindName] = kinds[r.Kind()]
-				mappedKinds = append(mappedKinds, repl)
-				mrslv.MappedKind(rel, repl)
-				r.SetKind(repl.KindName)
+			for _, r := range empty {
+				if repl, ok := c.LookupMappedKind(r.Kind(), rel, ruleTags(r)); ok {
+					mappedKindInfo[repl.KindName] = repl.MapKindInfo(kinds[r.Kind()])
+					mappedKinds = append(mappedKinds, repl)
+					mrslv.MappedKind(rel, repl)
+					r.SetKind(repl.KindName)
+				}
 			}
-		}
 
-		// Insert or merge rules into the build file.
-		if f == nil {
-			f = rule.EmptyFile(filepath.Join(dir, c.DefaultBuildFileName()), rel)
-			for _, r := range gen {
-				r.Insert(f)
+			// Insert or merge rules into the build file.
+			if f == nil {
+				f = rule.EmptyFile(filepath.Join(dir, c.DefaultBuildFileName()), rel)
+				for _, r := range gen {
+					r.Insert(f)
+				}
+			} else {
+				conflicts := uc.mergeFile(f, empty, gen, merger.PreResolve,
+					unionKindInfoMaps(kinds, mappedKindInfo, c.ManagedAttrs),
+					c.AliasMap,
+					u.snapshot.Base(rel),
+				)
+				reportMergeConflicts(c, f, conflicts)
 			}
-		} else {
-			merger.MergeFile(f, empty, gen, merger.PreResolve,
-				unionKindInfoMaps(kinds, mappedKindInfo),
-				c.AliasMap,
-			)
-		}
-		visits = append(visits, visitRecord{
-			pkgRel:         rel,
-			c:              c,
-			rules:          gen,
-			imports:        imports,
-			empty:          empty,
-			file:           f,
-			mappedKinds:    mappedKinds,
-			mappedKindInfo: mappedKindInfo,
-		})
+			visits = append(visits, visitRecord{
+				pkgRel:         rel,
+				c:              c,
+				rules:          gen,
+				imports:        imports,
+				empty:          empty,
+				file:           f,
+				mappedKinds:    mappedKinds,
+				mappedKindInfo: mappedKindInfo,
+			})
 
-		// Add library rules to the dependency resolution table.
-		if c.IndexLibraries {
-			for _, r := range f.Rules {
-				ruleIndex.AddRule(c, r, f)
+			// Add library rules to the dependency resolution table.
+			if c.IndexLibraries {
+				for _, r := range f.Rules {
+					ruleIndex.AddRule(c, r, f)
+				}
 			}
-		}
 
-		return walk.Walk2FuncResult{
-			RelsToVisit: relsToVisit,
-			Err:         errors.Join(errs...),
-		}
+			return walk.Walk2FuncResult{
+				RelsToVisit: relsToVisit,
+				Err:         errors.Join(errs...),
+			}
+		})
 	})
 
-	for _, lang := range languages {
-		if finishable, ok := lang.(language.FinishableLanguage); ok {
-			finishable.DoneGeneratingRules()
-		}
-	}
-
-	if walkErr != nil {
-		return walkErr
+	// Finish any directories still waiting for generation. This only happens
+	// if the walk didn't call back in the repository root directory.
+	if res := genQueue.finish(true); res.Err != nil {
+		walkErr = errors.Join(walkErr, res.Err)
 	}
+	return visits, nestedRepos, walkErr
+}
 
-	// Finish building the index for dependency resolution.
-	ruleIndex.Finish()
+// repoUpdate holds the directories to update in one repository during a run
+// and the records of directories visited there.
+type repoUpdate struct {
+	u      *updater
+	dirs   []string
+	visits []visitRecord
+}
 
-	// Resolve dependencies.
-	rc, cleanupRc := repo.NewRemoteCache(uc.repos)
-	defer func() {
-		if cerr := cleanupRc(); err == nil && cerr != nil {
-			err = cerr
+// newNestedUpdater returns an updater for the repository nested in u's
+// repository at rel, a slash-separated path relative to u's repository root.
+//
+// The nested repository's configuration is a copy of base, u's root
+// configuration before directives were applied, with the repository root,
+// name, and module mapping of the nested repository. Walk flags are checked
+// again so ignore files are read there and -exclude patterns are re-rooted.
+// Other flags aren't, so they apply to nested repositories unchanged.
+// Caches and the merge snapshot are keyed by paths relative to the outer
+// repository root, so they're not used in nested repositories.
+func (u *updater) newNestedUpdater(base *config.Config, rel string) (*updater, error) {
+	c := base.Clone()
+	c.RepoRoot = filepath.Join(base.RepoRoot, filepath.FromSlash(rel))
+	if base.FS != nil {
+		fsys, err := fs.Sub(base.FS, rel)
+		if err != nil {
+			return nil, err
 		}
-	}()
-	if err = maybePopulateRemoteCacheFromGoMod(c, rc); err != nil {
-		log.Print(err)
+		c.FS = fsys
 	}
-	for _, v := range visits {
-		for i, r := range v.rules {
-			from := label.New(c.RepoName, v.pkgRel, r.Name())
-			if rslv := mrslv.Resolver(r, v.pkgRel); rslv != nil {
-				rslv.Resolve(v.c, ruleIndex, rc, r, v.imports[i], from)
+	c.FileCache = nil
+	walk.SetCache(c, nil)
+	for _, cext := range u.cexts {
+		if wc, ok := cext.(*walk.Configurer); ok {
+			if err := wc.CheckFlags(nil, c); err != nil {
+				return nil, err
 			}
 		}
-		merger.MergeFile(v.file, v.empty, v.rules, merger.PostResolve,
-			unionKindInfoMaps(kinds, v.mappedKindInfo),
-			v.c.AliasMap,
-		)
 	}
-	for _, lang := range languages {
-		if life, ok := lang.(language.LifecycleManager); ok {
-			life.AfterResolvingDeps(ctx)
+	if base.ReadBuildFilesDir != "" {
+		c.ReadBuildFilesDir = filepath.Join(base.ReadBuildFilesDir, filepath.FromSlash(rel))
+	}
+	if base.WriteBuildFilesDir != "" {
+		c.WriteBuildFilesDir = filepath.Join(base.WriteBuildFilesDir, filepath.FromSlash(rel))
+	}
+
+	var err error
+	if c.RepoName, err = module.ExtractModuleName(c.RepoRoot); err != nil {
+		return nil, fmt.Errorf("%s: failed to extract repository name: %v", rel, err)
+	}
+	if c.ModuleToApparentName, err = module.ExtractModuleToApparentNameMapping(c.RepoRoot); err != nil {
+		return nil, fmt.Errorf("%s: failed to parse MODULE.bazel: %v", rel, err)
+	}
+
+	nu := newUpdater(c, u.cexts)
+	nu.indexCache = nil
+	nu.ruleIndex.SetCache(nil)
+	nu.snapshot = nil
+	return nu, nil
+}
+
+// nestedRepoDirs returns the directories to update in the nested repository
+// rooted at root, given the directories updated in the repository that
+// contains it. It returns false if the nested repository doesn't need to be
+// walked: nothing in it is updated, and the mode doesn't index the whole tree.
+func nestedRepoDirs(root string, dirs []string, mode walk.Mode) ([]string, bool) {
+	recursive := mode == walk.VisitAllUpdateSubdirsMode || mode == walk.UpdateSubdirsMode
+	var nestedDirs []string
+	for _, dir := range dirs {
+		if isDescendingDir(dir, root) {
+			nestedDirs = append(nestedDirs, dir)
+		} else if recursive && isDescendingDir(root, dir) && !slices.Contains(nestedDirs, root) {
+			nestedDirs = append(nestedDirs, root)
 		}
 	}
+	visitAll := mode == walk.VisitAllUpdateSubdirsMode || mode == walk.VisitAllUpdateDirsMode
+	return nestedDirs, len(nestedDirs) > 0 || visitAll
+}
 
-	// Emit merged files.
-	var exit error
-	for _, v := range visits {
-		merger.FixLoads(v.file, applyKindMappings(v.mappedKinds, loads))
-		if err := uc.emit(v.c, v.file); err != nil {
-			if err == errExit {
-				exit = err
-			} else {
-				log.Print(err)
+// linkRepoIndexes makes the rule index of each repository updated in a run
+// search the indexes of the others, so imports may be resolved across
+// repository boundaries. The first repository is the main repository.
+// Labels of rules in another repository use its apparent name: the name
+// given by a bazel_dep's repo_name, or the module name. The main repository
+// is "@" unless there's a bazel_dep on it.
+func linkRepoIndexes(repos []*repoUpdate) {
+	for _, from := range repos {
+		for _, name := range from.u.linkedRepos {
+			from.u.ruleIndex.SetRepoIndex(name, nil)
+		}
+		from.u.linkedRepos = nil
+		for i, to := range repos {
+			if to == from {
+				continue
+			}
+			var name string
+			if to.u.c.RepoName != "" && from.u.c.ModuleToApparentName != nil {
+				name = from.u.c.ModuleToApparentName(to.u.c.RepoName)
+			}
+			if name == "" && i == 0 {
+				name = "@"
+			} else if name == "" {
+				name = to.u.c.RepoName
 			}
+			if name == "" {
+				// A nested repository without a module name can't be named.
+				continue
+			}
+			from.u.ruleIndex.SetRepoIndex(name, to.u.ruleIndex)
+			from.u.linkedRepos = append(from.u.linkedRepos, name)
 		}
 	}
-	if uc.patchPath != "" {
-		if err := os.WriteFile(uc.patchPath, uc.patchBuffer.Bytes(), 0o666); err != nil {
-			return err
+}
+
+// canResolveConcurrently returns true if every rule in v has a resolver that
+// may be called concurrently.
+func canResolveConcurrently(mrslv *metaResolver, v visitRecord) bool {
+	for _, r := range v.rules {
+		rslv := mrslv.Resolver(r, v.pkgRel)
+		if rslv == nil {
+			continue
 		}
+		if cr, ok := rslv.(resolve.ConcurrentResolver); !ok || !cr.ConcurrentResolve() {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeFile merges generated rules into f with merger.MergeFileWithBase,
+// or with merger.MergeFileReportingOverwrites when -report_overwrites is set.
+func (uc *updateConfig) mergeFile(f *rule.File, empty, gen []*rule.Rule, phase merger.Phase, kinds map[string]rule.KindInfo, aliasedKinds map[string]string, base map[string]*rule.Rule) []merger.Conflict {
+	if uc.reportOverwrites == "" {
+		return merger.MergeFileWithBase(f, empty, gen, phase, kinds, aliasedKinds, base)
 	}
+	return merger.MergeFileReportingOverwrites(f, empty, gen, phase, kinds, aliasedKinds, base)
+}
 
-	return exit
+// reportMergeConflicts reports attributes that were changed both by hand and
+// by Gazelle. Conflicts found by a three-way merge keep the value in the
+// build file. Overwrites replace it and are reported as errors with
+// -report_overwrites=error.
+func reportMergeConflicts(c *config.Config, f *rule.File, conflicts []merger.Conflict) {
+	uc := getUpdateConfig(c)
+	for _, mc := range conflicts {
+		d := config.Diagnostic{
+			Severity: config.SeverityWarning,
+			Code:     "merge-conflict",
+			File:     c.RelFile(f),
+			Line:     mc.Line,
+			Message:  fmt.Sprintf("%s(%s): attribute %q was changed by hand and by Gazelle; keeping the value in the build file", mc.Rule.Kind(), mc.Rule.Name(), mc.Attr),
+		}
+		if mc.Overwritten {
+			uc.overwrites.Add(1)
+			if uc.reportOverwrites == "error" {
+				d.Severity = config.SeverityError
+			}
+			d.Code = "overwrite"
+			d.Message = fmt.Sprintf("%s(%s): attribute %q was edited by hand; replacing it with the generated value", mc.Rule.Kind(), mc.Rule.Name(), mc.Attr)
+		}
+		c.Report(d)
+	}
 }
 
 // lookupMapKindReplacement finds a mapped replacement for rule kind `kind`, resolving transitively.
 // i.e. if go_library is mapped to custom_go_library, and custom_go_library is mapped to other_go_library,
 // looking up go_library will return other_go_library.
+// Conditional replacements are checked against the directory rel and the rule's tags.
+// The attribute mappings of each replacement in the chain are combined in the result.
 // It returns an error on a loop, and may return nil if no remapping should be performed.
-func lookupMapKindReplacement(kindMap map[string]config.MappedKind, kind string) (*config.MappedKind, error) {
+func lookupMapKindReplacement(c *config.Config, kind, rel string, tags []string) (*config.MappedKind, error) {
 	var mapped *config.MappedKind
+	var attrs []config.AttrMapping
 	seenKinds := make(map[string]struct{})
 	seenKindPath := []string{kind}
 	for {
-		replacement, ok := kindMap[kind]
+		replacement, ok := c.LookupMappedKind(kind, rel, tags)
 		if !ok {
 			break
 		}
+		attrs = append(attrs, replacement.Attrs...)
+		replacement.Attrs = attrs
 
 		seenKindPath = append(seenKindPath, replacement.KindName)
 		if _, alreadySeen := seenKinds[replacement.KindName]; alreadySeen {
@@ -621,6 +1124,8 @@ output mode determines what Gazelle does with updated BUILD files.
   fix (default) - write updated BUILD files back to disk.
   print - print updated BUILD files to stdout.
   diff - diff updated BUILD files against existing files in unified format.
+  json - report added, removed, and modified rules in updated BUILD files
+      as JSON.
 
 Gazelle accepts a list of paths to Go package directories to process (defaults
 to the working directory if none are given). It recursively traverses
@@ -734,12 +1239,17 @@ func maybePopulateRemoteCacheFromGoMod(c *config.Config, rc *repo.RemoteCache) e
 	return rc.PopulateFromGoMod(goModPath)
 }
 
-func unionKindInfoMaps(a, b map[string]rule.KindInfo) map[string]rule.KindInfo {
-	if len(a) == 0 {
-		return b
-	}
-	if len(b) == 0 {
-		return a
+// unionKindInfoMaps combines kind information from languages in a with
+// information for mapped kinds in b, which takes precedence. Changes made
+// with # gazelle:managed_attrs directives, in managed, are applied on top.
+func unionKindInfoMaps(a, b map[string]rule.KindInfo, managed map[string]map[string]bool) map[string]rule.KindInfo {
+	if len(managed) == 0 {
+		if len(a) == 0 {
+			return b
+		}
+		if len(b) == 0 {
+			return a
+		}
 	}
 	result := make(map[string]rule.KindInfo, len(a)+len(b))
 	for k, v := range a {
@@ -748,9 +1258,39 @@ func unionKindInfoMaps(a, b map[string]rule.KindInfo) map[string]rule.KindInfo {
 	for k, v := range b {
 		result[k] = v
 	}
+	for kind, attrs := range managed {
+		if info, ok := result[kind]; ok {
+			result[kind] = managedKindInfo(info, attrs)
+		}
+	}
 	return result
 }
 
+// managedKindInfo returns a copy of info with attributes mapped to true in
+// attrs added to MergeableAttrs, unless they're already in ResolveAttrs, and
+// attributes mapped to false removed from both.
+func managedKindInfo(info rule.KindInfo, attrs map[string]bool) rule.KindInfo {
+	mergeable := make(map[string]bool, len(info.MergeableAttrs)+len(attrs))
+	for k, v := range info.MergeableAttrs {
+		mergeable[k] = v
+	}
+	resolve := make(map[string]bool, len(info.ResolveAttrs))
+	for k, v := range info.ResolveAttrs {
+		resolve[k] = v
+	}
+	for attr, managed := range attrs {
+		if !managed {
+			delete(mergeable, attr)
+			delete(resolve, attr)
+		} else if !resolve[attr] {
+			mergeable[attr] = true
+		}
+	}
+	info.MergeableAttrs = mergeable
+	info.ResolveAttrs = resolve
+	return info
+}
+
 // applyKindMappings returns a copy of LoadInfo that includes c.KindMap.
 func applyKindMappings(mappedKinds []config.MappedKind, loads []rule.LoadInfo) []rule.LoadInfo {
 	if len(mappedKinds) == 0 {
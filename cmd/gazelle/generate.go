@@ -0,0 +1,214 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+)
+
+// generatedDir holds the rules generated by all languages in one directory.
+type generatedDir struct {
+	empty, gen  []*rule.Rule
+	imports     []interface{}
+	relsToVisit []string
+}
+
+// generateDir calls GenerateRules for each language in a directory. Languages
+// are called in order, and each one sees the rules generated by the
+// languages before it.
+func generateDir(args walk.Walk2FuncArgs, langs []language.Language) generatedDir {
+	var res generatedDir
+	for _, l := range langs {
+		lres := l.GenerateRules(language.GenerateArgs{
+			Config:       args.Config,
+			Dir:          args.Dir,
+			Rel:          args.Rel,
+			File:         args.File,
+			Subdirs:      args.Subdirs,
+			RegularFiles: args.RegularFiles,
+			GenFiles:     args.GenFiles,
+			OtherEmpty:   res.empty,
+			OtherGen:     res.gen,
+		})
+		if len(lres.Gen) != len(lres.Imports) {
+			log.Panicf("%s: language %s generated %d rules but returned %d imports", args.Rel, l.Name(), len(lres.Gen), len(lres.Imports))
+		}
+		res.empty = append(res.empty, lres.Empty...)
+		res.gen = append(res.gen, lres.Gen...)
+		res.imports = append(res.imports, lres.Imports...)
+		if args.Config.IndexLibraries {
+			res.relsToVisit = append(res.relsToVisit, lres.RelsToIndex...)
+		}
+	}
+	return res
+}
+
+// canGenerateConcurrently returns true if every language in langs has
+// declared that its GenerateRules method is safe to call concurrently.
+func canGenerateConcurrently(langs []language.Language) bool {
+	for _, l := range langs {
+		cl, ok := l.(language.ConcurrentLanguage)
+		if !ok || !cl.ConcurrentGenerateRules() {
+			return false
+		}
+	}
+	return true
+}
+
+// generateQueue schedules rule generation for directories visited by
+// walk.Walk2 and finishes them in the order they were visited.
+//
+// When concurrency is enabled, GenerateRules may run for several sibling
+// directories at once on a pool of worker goroutines. A directory's rules are
+// never generated until generation has finished in all of its
+// subdirectories, since languages may depend on that (for example, Go checks
+// whether testdata contains a package). Work that touches shared state,
+// like merging, indexing, and recording visits, is done by finish functions
+// on the walk's goroutine, in walk order, so the output is deterministic.
+//
+// When concurrency is disabled, everything runs inline, and the queue never
+// holds more than one entry.
+type generateQueue struct {
+	// sem is a semaphore limiting the number of concurrent calls to
+	// generateDir. nil if concurrency is disabled.
+	sem chan struct{}
+
+	// outstanding is a list of jobs not yet waited on by a job in an
+	// ancestor directory.
+	outstanding []*generateJob
+
+	// pending is a list of visited directories in walk order, waiting to
+	// be finished.
+	pending []pendingVisit
+}
+
+type generateJob struct {
+	rel  string
+	done chan struct{}
+	res  generatedDir
+}
+
+type pendingVisit struct {
+	// job generates rules for the directory. nil if the directory is only
+	// being indexed.
+	job *generateJob
+
+	// finish is called with the generated rules after job is done.
+	finish func(generatedDir) walk.Walk2FuncResult
+}
+
+func newGenerateQueue(jobs int) *generateQueue {
+	q := &generateQueue{}
+	if jobs > 1 {
+		q.sem = make(chan struct{}, jobs)
+	}
+	return q
+}
+
+// add schedules rule generation for the directory described by args with the
+// given languages, then calls finish with the result. If langs is empty,
+// no rules are generated, but finish is still called in order. add returns
+// the combined results of all finish functions called so far.
+func (q *generateQueue) add(args walk.Walk2FuncArgs, langs []language.Language, finish func(generatedDir) walk.Walk2FuncResult) walk.Walk2FuncResult {
+	var job *generateJob
+	if len(langs) > 0 {
+		job = &generateJob{rel: args.Rel, done: make(chan struct{})}
+
+		// Collect jobs in subdirectories. Jobs further down were already
+		// collected by those jobs, so there's no need to wait on them directly.
+		var deps []*generateJob
+		outstanding := q.outstanding[:0]
+		for _, o := range q.outstanding {
+			if isRelDescendant(o.rel, args.Rel) {
+				deps = append(deps, o)
+			} else {
+				outstanding = append(outstanding, o)
+			}
+		}
+		q.outstanding = append(outstanding, job)
+
+		run := func() {
+			for _, d := range deps {
+				<-d.done
+			}
+			if q.sem != nil {
+				q.sem <- struct{}{}
+				defer func() { <-q.sem }()
+			}
+			defer close(job.done)
+			job.res = generateDir(args, langs)
+		}
+		if q.sem != nil && canGenerateConcurrently(langs) {
+			go run()
+		} else {
+			run()
+		}
+	}
+	q.pending = append(q.pending, pendingVisit{job: job, finish: finish})
+
+	// The root directory is the last directory visited in the main walk, so
+	// wait for everything there. That way, no generation is in progress when
+	// the walk returns.
+	return q.finish(args.Rel == "")
+}
+
+// finish calls finish functions for pending visits in order. If wait is true,
+// finish waits for all pending jobs. Otherwise, it stops at the first
+// pending job that isn't done.
+func (q *generateQueue) finish(wait bool) walk.Walk2FuncResult {
+	var result walk.Walk2FuncResult
+	var errs []error
+	for len(q.pending) > 0 {
+		v := q.pending[0]
+		var res generatedDir
+		if v.job != nil {
+			if wait {
+				<-v.job.done
+			} else {
+				select {
+				case <-v.job.done:
+				default:
+					result.Err = errors.Join(errs...)
+					return result
+				}
+			}
+			res = v.job.res
+		}
+		q.pending = q.pending[1:]
+		vres := v.finish(res)
+		if vres.Err != nil {
+			errs = append(errs, vres.Err)
+		}
+		result.RelsToVisit = append(result.RelsToVisit, vres.RelsToVisit...)
+	}
+	result.Err = errors.Join(errs...)
+	return result
+}
+
+// isRelDescendant returns whether the slash-separated, repo-root-relative
+// path rel is strictly inside the directory parentRel.
+func isRelDescendant(rel, parentRel string) bool {
+	if parentRel == "" {
+		return rel != ""
+	}
+	return strings.HasPrefix(rel, parentRel+"/")
+}
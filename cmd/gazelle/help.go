@@ -0,0 +1,103 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+)
+
+// helpDirectives prints documentation for the directives known to Gazelle
+// and its languages. Directives are documented by Configurers that
+// implement config.DirectiveSchemaProvider. Directives of other Configurers
+// are listed without documentation.
+func helpDirectives(w io.Writer) error {
+	cexts := []config.Configurer{
+		&config.CommonConfigurer{},
+		&walk.Configurer{},
+		&resolve.Configurer{},
+	}
+	for _, lang := range languages {
+		cexts = append(cexts, lang)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(`Directives are comments in build files of the form "# gazelle:key value".
+They apply to the directory containing the build file and its subdirectories.
+Invalid directives are reported and ignored. With -strict, they're errors.
+`)
+	for _, cext := range cexts {
+		schemas := make(map[string]config.DirectiveSchema)
+		if sp, ok := cext.(config.DirectiveSchemaProvider); ok {
+			for _, s := range sp.DirectiveSchemas() {
+				schemas[s.Key] = s
+			}
+		}
+		keys := cext.KnownDirectives()
+		if len(keys) == 0 {
+			continue
+		}
+		keys = append([]string(nil), keys...)
+		sort.Strings(keys)
+
+		fmt.Fprintf(b, "\n%s:\n", describerName(cext))
+		for _, key := range keys {
+			s, ok := schemas[key]
+			if !ok {
+				fmt.Fprintf(b, "  # gazelle:%s\n      (undocumented)\n", key)
+				continue
+			}
+			fmt.Fprintf(b, "  # gazelle:%s", key)
+			if s.Usage != "" {
+				fmt.Fprintf(b, " %s", s.Usage)
+			}
+			b.WriteString("\n")
+			writeWrapped(b, "      ", s.Doc, 80)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeWrapped writes text to b, breaking lines between words so they
+// don't exceed width columns where possible. Each line starts with indent.
+func writeWrapped(b *strings.Builder, indent, text string, width int) {
+	n := 0
+	for _, word := range strings.Fields(text) {
+		if n > 0 && n+1+len(word) > width {
+			b.WriteString("\n")
+			n = 0
+		}
+		if n == 0 {
+			b.WriteString(indent)
+			n = len(indent)
+		} else {
+			b.WriteString(" ")
+			n++
+		}
+		b.WriteString(word)
+		n += len(word)
+	}
+	if n > 0 {
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,61 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+)
+
+func TestHelpDirectives(t *testing.T) {
+	var buf bytes.Buffer
+	if err := helpDirectives(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\nconfig:\n  # gazelle:alias_kind alias_kind underlying_kind\n",
+		"  # gazelle:go_test default|file\n      Whether to generate one go_test per package or one per test file.\n",
+		"  # gazelle:resolve source-lang [import-lang] import-string label\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestInvalidDirectiveStrict(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `# gazelle:prefix example.com/repo
+# gazelle:go_test everything
+`,
+		},
+	})
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"-diagnostics_format=json"}); err != nil {
+		t.Fatalf("got error %v; want success without -strict", err)
+	}
+	err := runGazelle(dir, []string{"-strict", "-diagnostics_format=json"})
+	if err == nil || !strings.Contains(err.Error(), "BUILD.bazel: 1 invalid directives") {
+		t.Errorf("got error %v; want invalid directives error with -strict", err)
+	}
+}
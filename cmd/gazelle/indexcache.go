@@ -0,0 +1,163 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+)
+
+// indexCacheIgnoredFlags lists flags that don't affect how rules are indexed.
+// Changing them doesn't invalidate the index cache.
+var indexCacheIgnoredFlags = []string{
+	"cache_dir",
+	"changed_since",
+	"cpuprofile",
+	"diagnostics_format",
+	"explain",
+	"generate_jobs",
+	"memprofile",
+	"mode",
+	"patch",
+	"print0",
+	"r",
+	"resolve_jobs",
+}
+
+// indexCachePath returns the path of the index cache file for the
+// repository in cacheDir. Each repository has its own file, so one cache
+// directory may be shared by several repositories.
+func indexCachePath(cacheDir string, c *config.Config) string {
+	sum := sha256.Sum256([]byte(c.RepoRoot))
+	return filepath.Join(cacheDir, "index-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// fileCachePath returns the path of the file cache for the repository in
+// cacheDir. Like the index cache, each repository has its own file.
+func fileCachePath(cacheDir string, c *config.Config) string {
+	sum := sha256.Sum256([]byte(c.RepoRoot))
+	return filepath.Join(cacheDir, "files-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// indexCacheKey returns a key for the whole run. Records from a cache saved
+// with a different key are discarded. The key covers the Gazelle binary,
+// the enabled languages and their versions, the flags that were set, and
+// the repository configuration files.
+func indexCacheKey(fs *flag.FlagSet, c *config.Config, repoConfigPath string) string {
+	h := sha256.New()
+	hashGazelleVersion(h)
+	fmt.Fprintf(h, "repo %q\n", c.RepoName)
+	for _, lang := range languages {
+		fmt.Fprintf(h, "lang %q\n", lang.Name())
+		if v, ok := lang.(resolve.IndexCacheVersioner); ok {
+			fmt.Fprintf(h, "version %q\n", v.IndexCacheVersion())
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if !slices.Contains(indexCacheIgnoredFlags, f.Name) {
+			fmt.Fprintf(h, "flag %q %q\n", f.Name, f.Value.String())
+		}
+	})
+	for _, p := range []string{repoConfigPath, filepath.Join(c.RepoRoot, "MODULE.bazel"), filepath.Join(c.RepoRoot, "gazelle.json")} {
+		hashFile(h, p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// indexCacheKeyer computes keys for build files indexed with the cache.
+// A build file's key covers its own content and the content of build files
+// and go.mod files in its directory and ancestor directories, since
+// directives and prefixes set there may change how its rules are indexed.
+//
+// indexCacheKeyer must be used during the walk, since it loads ancestor
+// directories with walk.GetDirInfo. It is not safe for concurrent use.
+type indexCacheKeyer struct {
+	repoRoot string
+	dirKeys  map[string]string
+}
+
+// key returns the key for the build file in the directory rel. The key
+// covers the build and go.mod files in rel and its ancestors.
+func (k *indexCacheKeyer) key(rel string) string {
+	if key, ok := k.dirKeys[rel]; ok {
+		return key
+	}
+	h := sha256.New()
+	if rel != "" {
+		parent := path.Dir(rel)
+		if parent == "." {
+			parent = ""
+		}
+		h.Write([]byte(k.key(parent)))
+	}
+	if di, err := walk.GetDirInfo(rel); err == nil {
+		if di.File != nil {
+			fmt.Fprintf(h, "build %d\n", len(di.File.Content))
+			h.Write(di.File.Content)
+		}
+		if slices.Contains(di.RegularFiles, "go.mod") {
+			hashFile(h, filepath.Join(k.repoRoot, filepath.FromSlash(rel), "go.mod"))
+		}
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+	if k.dirKeys == nil {
+		k.dirKeys = make(map[string]string)
+	}
+	k.dirKeys[rel] = key
+	return key
+}
+
+// hashGazelleVersion writes the version of the running Gazelle binary to h.
+// Language extensions built into the binary are covered by this too. Build
+// info includes the module versions and VCS revision when the binary was
+// built with the go command, but not when it was built with Bazel, so the
+// size and modification time of the executable are also written.
+func hashGazelleVersion(h hash.Hash) {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(h, "build %q\n", bi.String())
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	if fi, err := os.Stat(exe); err == nil {
+		fmt.Fprintf(h, "exe %d %d\n", fi.Size(), fi.ModTime().UnixNano())
+	}
+}
+
+// hashFile writes the content of the file at p to h, preceded by its length.
+// Missing files are hashed differently from empty files.
+func hashFile(h hash.Hash, p string) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		fmt.Fprintf(h, "missing %q\n", filepath.Base(p))
+		return
+	}
+	fmt.Fprintf(h, "file %q %d\n", filepath.Base(p), len(data))
+	h.Write(data)
+}
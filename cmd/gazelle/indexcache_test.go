@@ -0,0 +1,240 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+)
+
+func TestIndexCache(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    importpath = "example.com/a",
+)
+`,
+		},
+		{
+			Path:    "c/c.go",
+			Content: `package c; import _ "example.com/a"`,
+		},
+	})
+	defer cleanup()
+	cacheDir := filepath.Join(dir, "cache")
+	args := []string{"-cache_dir=" + cacheDir, "-external=static", "c"}
+
+	wantC := func(dep string) testtools.FileSpec {
+		return testtools.FileSpec{
+			Path: "c/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "c",
+    srcs = ["c.go"],
+    importpath = "example.com/repo/c",
+    visibility = ["//visibility:public"],
+    deps = ["` + dep + `"],
+)
+`,
+		}
+	}
+
+	// The first run indexes //a and saves it to the cache.
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//a")})
+	cacheFiles, err := filepath.Glob(filepath.Join(cacheDir, "index-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cacheFiles) != 1 {
+		t.Fatalf("got cache files %q; want one file", cacheFiles)
+	}
+
+	// Tamper with the cached record for //a, so we can tell whether the second
+	// run used it.
+	data, err := os.ReadFile(cacheFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"Name":"a"`), []byte(`"Name":"cached"`), 1)
+	if err := os.WriteFile(cacheFiles[0], data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "c/BUILD.bazel")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//a:cached")})
+
+	// Changing a/BUILD.bazel invalidates the cached record.
+	if err := os.WriteFile(filepath.Join(dir, "a/BUILD.bazel"), []byte(`
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a2",
+    importpath = "example.com/a",
+)
+`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//a:a2")})
+
+	// Changing a flag that affects indexing invalidates the whole cache.
+	data, err = os.ReadFile(cacheFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"Name":"a2"`), []byte(`"Name":"cached"`), 1)
+	if err := os.WriteFile(cacheFiles[0], data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, append([]string{"-go_prefix=example.com/repo"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//a:a2")})
+}
+
+// versionedLang is a language whose index cache version can be changed.
+type versionedLang struct {
+	language.Language
+	version string
+}
+
+func (l *versionedLang) IndexCacheVersion() string { return l.version }
+
+func TestIndexCacheKeyVersion(t *testing.T) {
+	lang := &versionedLang{Language: languages[len(languages)-1], version: "1"}
+	saved := languages
+	languages = append(languages[:len(languages):len(languages)], lang)
+	defer func() { languages = saved }()
+
+	fs := flag.NewFlagSet("gazelle", flag.ContinueOnError)
+	c := &config.Config{RepoRoot: t.TempDir()}
+	key1 := indexCacheKey(fs, c, "")
+	if key := indexCacheKey(fs, c, ""); key != key1 {
+		t.Errorf("key changed without a version change: %s, %s", key1, key)
+	}
+	lang.version = "2"
+	if key := indexCacheKey(fs, c, ""); key == key1 {
+		t.Errorf("key didn't change when the language version changed: %s", key)
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{Path: "a/a.go", Content: "package a"},
+		{Path: "b/b.go", Content: "package b"},
+		{
+			Path:    "c/c.go",
+			Content: `package c; import _ "example.com/repo/a"`,
+		},
+	})
+	defer cleanup()
+	cacheDir := filepath.Join(dir, "cache")
+	args := []string{"-cache_dir=" + cacheDir, "-external=static"}
+
+	// Files modified very recently aren't trusted by modification time alone.
+	cPath := filepath.Join(dir, "c/c.go")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(cPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	wantC := func(dep string) testtools.FileSpec {
+		return testtools.FileSpec{
+			Path: "c/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "c",
+    srcs = ["c.go"],
+    importpath = "example.com/repo/c",
+    visibility = ["//visibility:public"],
+    deps = ["` + dep + `"],
+)
+`,
+		}
+	}
+
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//a")})
+	cacheFiles, err := filepath.Glob(filepath.Join(cacheDir, "files-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cacheFiles) != 1 {
+		t.Fatalf("got cache files %q; want one file", cacheFiles)
+	}
+
+	// Tamper with the cached imports of c.go, so we can tell whether the
+	// second run used them.
+	data, err := os.ReadFile(cacheFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"example.com/repo/a"`), []byte(`"example.com/repo/b"`), 1)
+	if err := os.WriteFile(cacheFiles[0], data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//b")})
+
+	// Changing c.go invalidates the cached information.
+	if err := os.WriteFile(cPath, []byte(`package c; import _ "example.com/repo/a" // changed`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{wantC("//a")})
+}
@@ -5114,3 +5114,618 @@ go_library(
 		},
 	})
 }
+
+// TestGenerateJobs checks that generating rules concurrently produces the
+// same build files as generating them serially.
+func TestGenerateJobs(t *testing.T) {
+	checkSameOutput(t, concurrencyTestFiles(), []string{"-generate_jobs=1"}, []string{"-generate_jobs=8"})
+}
+
+// TestResolveJobs checks that resolving dependencies concurrently produces the
+// same build files as resolving them serially.
+func TestResolveJobs(t *testing.T) {
+	checkSameOutput(t, concurrencyTestFiles(), []string{"-resolve_jobs=1"}, []string{"-resolve_jobs=8", "-generate_jobs=8"})
+}
+
+func concurrencyTestFiles() []testtools.FileSpec {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/repo"},
+		{Path: "root.go", Content: "package root\n\nimport _ \"example.com/repo/a\"\n"},
+		{Path: "a/testdata/data.txt", Content: "data"},
+		{Path: "proto/foo.proto", Content: "syntax = \"proto3\";\n\npackage foo;\n"},
+	}
+	for _, p := range []string{"a", "b", "c", "d", "e", "f"} {
+		files = append(files,
+			testtools.FileSpec{
+				Path:    p + "/" + p + ".go",
+				Content: "package " + p + "\n\nimport _ \"example.com/repo/" + p + "/sub\"\n",
+			},
+			testtools.FileSpec{
+				Path:    p + "/" + p + "_test.go",
+				Content: "package " + p + "\n\nimport _ \"example.com/repo/proto\"\n",
+			},
+			testtools.FileSpec{
+				Path:    p + "/sub/sub.go",
+				Content: "package sub\n",
+			})
+	}
+	return files
+}
+
+// checkSameOutput runs Gazelle on two copies of the same files with different
+// arguments, then checks that the build files written are identical.
+func checkSameOutput(t *testing.T, files []testtools.FileSpec, wantArgs, gotArgs []string) {
+	t.Helper()
+	wantDir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+	if err := runGazelle(wantDir, wantArgs); err != nil {
+		t.Fatal(err)
+	}
+	gotDir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+	if err := runGazelle(gotDir, gotArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []testtools.FileSpec
+	err := filepath.Walk(wantDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.Name() != "BUILD.bazel" {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(wantDir, p)
+		want = append(want, testtools.FileSpec{Path: filepath.ToSlash(rel), Content: string(content)})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) < len(files)/3 {
+		t.Fatalf("got %d build files from %v; want at least %d", len(want), wantArgs, len(files)/3)
+	}
+	testtools.CheckFiles(t, gotDir, want)
+}
+
+func TestGenerationModePerLanguage(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:generation_mode proto update_only",
+		},
+		{
+			Path:    "a/a.go",
+			Content: "package a",
+		},
+		{
+			Path:    "a/a.proto",
+			Content: `syntax = "proto3";`,
+		},
+		{
+			Path:    "b/b.proto",
+			Content: `syntax = "proto3";`,
+		},
+		{
+			Path: "c/BUILD.bazel",
+		},
+		{
+			Path:    "c/c.proto",
+			Content: `syntax = "proto3";`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"-go_prefix", "example.com/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path:     "b/BUILD.bazel",
+			NotExist: true,
+		},
+		{
+			Path: "c/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+load("@io_bazel_rules_go//proto:def.bzl", "go_proto_library")
+load("@rules_proto//proto:defs.bzl", "proto_library")
+
+proto_library(
+    name = "c_proto",
+    srcs = ["c.proto"],
+    visibility = ["//visibility:public"],
+)
+
+go_proto_library(
+    name = "c_go_proto",
+    importpath = "example.com/repo/c",
+    proto = ":c_proto",
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "c",
+    embed = [":c_go_proto"],
+    importpath = "example.com/repo/c",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+	})
+}
+
+func TestNestedRepos(t *testing.T) {
+	files := []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `module(name = "main")
+
+bazel_dep(name = "nested_mod", repo_name = "nested")
+
+local_path_override(
+    module_name = "nested_mod",
+    path = "nested",
+)
+`,
+		},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/main",
+		},
+		{
+			Path: "a/a.go",
+			Content: `package a
+
+import _ "example.com/nested/b"
+`,
+		},
+		{
+			Path:    "c/c.go",
+			Content: "package c",
+		},
+		{
+			Path:    "nested/MODULE.bazel",
+			Content: `module(name = "nested_mod")`,
+		},
+		{
+			Path:    "nested/BUILD.bazel",
+			Content: "# gazelle:prefix example.com/nested",
+		},
+		{
+			Path: "nested/b/b.go",
+			Content: `package b
+
+import _ "example.com/main/c"
+`,
+		},
+	}
+
+	t.Run("update", func(t *testing.T) {
+		dir, cleanup := testtools.CreateFiles(t, files)
+		defer cleanup()
+
+		if err := runGazelle(dir, []string{"-nested_repos=update"}); err != nil {
+			t.Fatal(err)
+		}
+
+		testtools.CheckFiles(t, dir, []testtools.FileSpec{
+			{
+				Path: "a/BUILD.bazel",
+				Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/main/a",
+    visibility = ["//visibility:public"],
+    deps = ["@nested//b"],
+)
+`,
+			},
+			{
+				Path: "nested/b/BUILD.bazel",
+				Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/nested/b",
+    visibility = ["//visibility:public"],
+    deps = ["@//c"],
+)
+`,
+			},
+		})
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		dir, cleanup := testtools.CreateFiles(t, append(files, testtools.FileSpec{
+			Path:    "nested/gen/gen.go",
+			Content: "package gen",
+		}))
+		defer cleanup()
+
+		// -exclude patterns are relative to the main repository root.
+		if err := runGazelle(dir, []string{"-nested_repos=update", "-exclude=nested/gen"}); err != nil {
+			t.Fatal(err)
+		}
+
+		testtools.CheckFiles(t, dir, []testtools.FileSpec{
+			{
+				Path:     "nested/gen/BUILD.bazel",
+				NotExist: true,
+			},
+		})
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		dir, cleanup := testtools.CreateFiles(t, files)
+		defer cleanup()
+
+		if err := runGazelle(dir, []string{"-nested_repos=skip"}); err != nil {
+			t.Fatal(err)
+		}
+
+		testtools.CheckFiles(t, dir, []testtools.FileSpec{
+			{
+				Path:     "nested/b/BUILD.bazel",
+				NotExist: true,
+			},
+		})
+	})
+}
+
+func TestRepoConfigFile(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "gazelle.json",
+			Content: `{
+  "sections": [
+    {"path": "", "directives": ["prefix example.com/repo"]},
+    {"path": "legacy/**", "directives": ["go_naming_convention go_default_library"]}
+  ]
+}
+`,
+		},
+		{
+			Path:    "a/a.go",
+			Content: "package a",
+		},
+		{
+			Path:    "legacy/b/b.go",
+			Content: "package b",
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path: "legacy/b/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "go_default_library",
+    srcs = ["b.go"],
+    importpath = "example.com/repo/legacy/b",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+	})
+}
+
+func TestMapKindConditional(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `# gazelle:prefix example.com/repo
+# gazelle:map_kind go_test go_integration_test //tools:defs.bzl tag=integration attr=embed:library
+# gazelle:map_kind go_library internal_library //tools:defs.bzl path=internal/**
+`,
+		},
+		{
+			Path:    "a/a.go",
+			Content: "package a",
+		},
+		{
+			Path: "a/a_test.go",
+			Content: `package a
+
+import _ "example.com/repo/internal/c"
+`,
+		},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "a_test",
+    srcs = ["a_test.go"],
+    embed = [":a"],
+    tags = ["integration"],
+)
+`,
+		},
+		{
+			Path:    "b/b.go",
+			Content: "package b",
+		},
+		{
+			Path:    "b/b_test.go",
+			Content: "package b",
+		},
+		{
+			Path:    "internal/c/c.go",
+			Content: "package c",
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	want := []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+load("//tools:defs.bzl", "go_integration_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+go_integration_test(
+    name = "a_test",
+    srcs = ["a_test.go"],
+    library = [":a"],
+    tags = ["integration"],
+    deps = ["//internal/c"],
+)
+`,
+		},
+		{
+			Path: "b/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/repo/b",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "b_test",
+    srcs = ["b_test.go"],
+    embed = [":b"],
+)
+`,
+		},
+		{
+			Path: "internal/c/BUILD.bazel",
+			Content: `load("//tools:defs.bzl", "internal_library")
+
+internal_library(
+    name = "c",
+    srcs = ["c.go"],
+    importpath = "example.com/repo/internal/c",
+    visibility = ["//:__subpackages__"],
+)
+`,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err := runGazelle(dir, nil); err != nil {
+			t.Fatal(err)
+		}
+		testtools.CheckFiles(t, dir, want)
+	}
+}
+
+func TestReportOverwrites(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:prefix example.com/repo
+
+go_library(
+    name = "repo",
+    srcs = [
+        "a.go",
+        "hand.go",
+    ],
+    importpath = "example.com/repo",
+    visibility = ["//visibility:public"],
+    deps = ["//extra"],  # keep
+)
+`,
+		},
+		{
+			Path:    "a.go",
+			Content: "package repo",
+		},
+		{
+			Path:    "b.go",
+			Content: "package repo",
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if err := runGazelle(dir, []string{"-report_overwrites=error", "-mode=diff"}); err == nil {
+		t.Fatal("got success; want error")
+	}
+	want := `BUILD.bazel:8: go_library(repo): attribute "srcs" was edited by hand; replacing it with the generated value`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log does not contain %q\n--begin--\n%s--end--\n", want, buf.String())
+	}
+	if strings.Contains(buf.String(), `"deps"`) {
+		t.Errorf("kept attribute was reported:\n%s", buf.String())
+	}
+	testtools.CheckFiles(t, dir, files)
+
+	buf.Reset()
+	if err := runGazelle(dir, []string{"-report_overwrites=warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log does not contain %q\n--begin--\n%s--end--\n", want, buf.String())
+	}
+	buf.Reset()
+	if err := runGazelle(dir, []string{"-report_overwrites=error"}); err != nil {
+		t.Fatalf("after fixing: %v", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("unexpected log output:\n%s", buf.String())
+	}
+
+	// Deleting a source file isn't an overwrite.
+	if err := os.Remove(filepath.Join(dir, "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := runGazelle(dir, []string{"-report_overwrites=error"}); err != nil {
+		t.Fatalf("after deleting a file: %v", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("unexpected log output:\n%s", buf.String())
+	}
+}
+
+func TestManagedAttrs(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `# gazelle:prefix example.com/repo
+# gazelle:managed_attrs go_library -srcs,+tags
+`,
+		},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    tags = ["manual"],
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{Path: "a/a.go", Content: "package a"},
+		{Path: "a/new.go", Content: "package a"},
+		{
+			Path: "b/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:managed_attrs go_library
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/repo/b",
+    tags = ["manual"],
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{Path: "b/b.go", Content: "package b"},
+		{Path: "b/new.go", Content: "package b"},
+	})
+	defer cleanup()
+
+	if err := runGazelle(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path: "b/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:managed_attrs go_library
+
+go_library(
+    name = "b",
+    srcs = [
+        "b.go",
+        "new.go",
+    ],
+    importpath = "example.com/repo/b",
+    tags = ["manual"],
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+	})
+}
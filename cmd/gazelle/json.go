@@ -0,0 +1,224 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	bzl "github.com/bazelbuild/buildtools/build"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// jsonReport is the document written by -mode=json. It describes each build
+// file that Gazelle would change.
+type jsonReport struct {
+	Files []fileReport `json:"files"`
+}
+
+// fileReport describes the changes Gazelle would make to one build file.
+type fileReport struct {
+	// Path is the slash-separated path to the file, relative to the
+	// repository root.
+	Path string `json:"path"`
+
+	// Created is true if the file doesn't exist yet.
+	Created bool `json:"created"`
+
+	Added    []ruleReport `json:"added,omitempty"`
+	Removed  []ruleReport `json:"removed,omitempty"`
+	Modified []ruleReport `json:"modified,omitempty"`
+}
+
+// ruleReport identifies a rule that was added, removed, or modified. Attrs is
+// only set for modified rules.
+type ruleReport struct {
+	Kind  string       `json:"kind"`
+	Name  string       `json:"name"`
+	Attrs []attrChange `json:"attrs,omitempty"`
+}
+
+// attrChange describes an attribute of a modified rule. Old is omitted if the
+// attribute was added, and New is omitted if it was removed. Strings, string
+// lists, and booleans are reported as JSON values. Other expressions are
+// reported as formatted Starlark strings.
+type attrChange struct {
+	Name string      `json:"name"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+func jsonFile(c *config.Config, f *rule.File) error {
+	newContent := f.Format()
+	if bytes.Equal(newContent, f.Content) {
+		// No change.
+		return nil
+	}
+
+	rel, err := filepath.Rel(c.RepoRoot, f.Path)
+	if err != nil {
+		return fmt.Errorf("error getting old path for file %q: %v", f.Path, err)
+	}
+	fr := fileReport{Path: filepath.ToSlash(rel)}
+	if _, err := os.Stat(f.Path); os.IsNotExist(err) {
+		fr.Created = true
+	} else if err != nil {
+		return fmt.Errorf("error reading original file: %v", err)
+	}
+
+	var oldRules []*rule.Rule
+	if len(f.Content) > 0 {
+		oldFile, err := reloadFile(f, f.Content)
+		if err != nil {
+			return fmt.Errorf("error parsing original file %s: %v", f.Path, err)
+		}
+		oldRules = oldFile.Rules
+	}
+	newFile, err := reloadFile(f, newContent)
+	if err != nil {
+		return fmt.Errorf("error parsing updated file %s: %v", f.Path, err)
+	}
+	fr.Added, fr.Removed, fr.Modified = compareRules(oldRules, newFile.Rules)
+
+	report := getUpdateConfig(c).jsonReport
+	report.Files = append(report.Files, fr)
+	return errExit
+}
+
+// reloadFile parses data the same way f was originally parsed.
+func reloadFile(f *rule.File, data []byte) (*rule.File, error) {
+	switch {
+	case f.DefName != "":
+		return rule.LoadMacroData(f.Path, f.Pkg, f.DefName, data)
+	case f.File != nil && f.File.Type == bzl.TypeWorkspace:
+		return rule.LoadWorkspaceData(f.Path, f.Pkg, data)
+	default:
+		return rule.LoadData(f.Path, f.Pkg, data)
+	}
+}
+
+// compareRules matches old and new rules by kind and name, then reports
+// which rules were added, removed, or modified. Added and modified rules are
+// listed in the order they appear in newRules; removed rules are listed in
+// the order they appear in oldRules.
+func compareRules(oldRules, newRules []*rule.Rule) (added, removed, modified []ruleReport) {
+	type ruleKey struct{ kind, name string }
+	oldByKey := make(map[ruleKey]*rule.Rule)
+	for _, r := range oldRules {
+		oldByKey[ruleKey{r.Kind(), r.Name()}] = r
+	}
+	seen := make(map[ruleKey]bool)
+	for _, r := range newRules {
+		key := ruleKey{r.Kind(), r.Name()}
+		seen[key] = true
+		oldRule, ok := oldByKey[key]
+		if !ok {
+			added = append(added, ruleReport{Kind: r.Kind(), Name: r.Name()})
+			continue
+		}
+		if attrs := compareAttrs(oldRule, r); len(attrs) > 0 {
+			modified = append(modified, ruleReport{Kind: r.Kind(), Name: r.Name(), Attrs: attrs})
+		}
+	}
+	for _, r := range oldRules {
+		if !seen[ruleKey{r.Kind(), r.Name()}] {
+			removed = append(removed, ruleReport{Kind: r.Kind(), Name: r.Name()})
+		}
+	}
+	return added, removed, modified
+}
+
+// compareAttrs returns a sorted list of attributes whose values differ
+// between oldRule and newRule.
+func compareAttrs(oldRule, newRule *rule.Rule) []attrChange {
+	keySet := make(map[string]bool)
+	for _, k := range oldRule.AttrKeys() {
+		keySet[k] = true
+	}
+	for _, k := range newRule.AttrKeys() {
+		keySet[k] = true
+	}
+	keys := make([]string, 0, len(keySet))
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var changes []attrChange
+	for _, k := range keys {
+		oldExpr, newExpr := oldRule.Attr(k), newRule.Attr(k)
+		if oldExpr != nil && newExpr != nil && bzl.FormatString(oldExpr) == bzl.FormatString(newExpr) {
+			continue
+		}
+		changes = append(changes, attrChange{
+			Name: k,
+			Old:  exprValue(oldExpr),
+			New:  exprValue(newExpr),
+		})
+	}
+	return changes
+}
+
+// exprValue converts an attribute value to a value that can be encoded as
+// JSON. nil is returned for a nil expression.
+func exprValue(e bzl.Expr) interface{} {
+	switch e := e.(type) {
+	case nil:
+		return nil
+	case *bzl.StringExpr:
+		return e.Value
+	case *bzl.Ident:
+		switch e.Name {
+		case "True":
+			return true
+		case "False":
+			return false
+		}
+	case *bzl.ListExpr:
+		strs := make([]string, 0, len(e.List))
+		for _, elem := range e.List {
+			s, ok := elem.(*bzl.StringExpr)
+			if !ok {
+				return bzl.FormatString(e)
+			}
+			strs = append(strs, s.Value)
+		}
+		return strs
+	}
+	return bzl.FormatString(e)
+}
+
+// writeJSONReport writes the report collected by -mode=json to the -patch
+// file, if set, or to stdout.
+func writeJSONReport(uc *updateConfig) error {
+	var out io.Writer = os.Stdout
+	if uc.patchPath != "" {
+		out = &uc.patchBuffer
+	}
+	if uc.jsonReport.Files == nil {
+		uc.jsonReport.Files = []fileReport{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(uc.jsonReport)
+}
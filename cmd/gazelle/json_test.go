@@ -0,0 +1,176 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+)
+
+func TestJSONExisting(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+# gazelle:prefix example.com/hello
+
+go_library(
+    name = "hello",
+    srcs = [
+        "gone.go",
+        "hello.go",
+    ],
+    importpath = "example.com/hello",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "hello_test",
+    srcs = ["gone_test.go"],
+    embed = [":hello"],
+)
+`,
+		},
+		{
+			Path:    "hello.go",
+			Content: `package hello`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	wantError := "encountered changes while running diff"
+	if err := runGazelle(dir, []string{"-mode=json", "-patch=p"}); err == nil || err.Error() != wantError {
+		t.Fatalf("got %v; want %q", err, wantError)
+	}
+
+	want := append(files, testtools.FileSpec{
+		Path: "p",
+		Content: `{
+  "files": [
+    {
+      "path": "BUILD.bazel",
+      "created": false,
+      "removed": [
+        {
+          "kind": "go_test",
+          "name": "hello_test"
+        }
+      ],
+      "modified": [
+        {
+          "kind": "go_library",
+          "name": "hello",
+          "attrs": [
+            {
+              "name": "srcs",
+              "old": [
+                "gone.go",
+                "hello.go"
+              ],
+              "new": [
+                "hello.go"
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`,
+	})
+	testtools.CheckFiles(t, dir, want)
+}
+
+func TestJSONNew(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "hello.go",
+			Content: `package hello`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	wantError := "encountered changes while running diff"
+	if err := runGazelle(dir, []string{"-go_prefix=example.com/hello", "-mode=json", "-patch=p"}); err == nil || err.Error() != wantError {
+		t.Fatalf("got %v; want %q", err, wantError)
+	}
+
+	want := append(files, testtools.FileSpec{
+		Path: "p",
+		Content: `{
+  "files": [
+    {
+      "path": "BUILD.bazel",
+      "created": true,
+      "added": [
+        {
+          "kind": "go_library",
+          "name": "hello"
+        }
+      ]
+    }
+  ]
+}
+`,
+	})
+	testtools.CheckFiles(t, dir, want)
+}
+
+func TestJSONNoChange(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:prefix example.com/hello
+
+go_library(
+    name = "hello",
+    srcs = ["hello.go"],
+    importpath = "example.com/hello",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path:    "hello.go",
+			Content: `package hello`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"-mode=json", "-patch=p"}); err != nil {
+		t.Fatalf("got %v; want success", err)
+	}
+
+	want := append(files, testtools.FileSpec{
+		Path: "p",
+		Content: `{
+  "files": []
+}
+`,
+	})
+	testtools.CheckFiles(t, dir, want)
+}
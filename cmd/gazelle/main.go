@@ -18,10 +18,12 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/language"
@@ -34,13 +36,17 @@ const (
 	fixCmd
 	updateReposCmd
 	helpCmd
+	configCmd
+	watchCmd
 )
 
 var commandFromName = map[string]command{
+	"config":       configCmd,
 	"fix":          fixCmd,
 	"help":         helpCmd,
 	"update":       updateCmd,
 	"update-repos": updateReposCmd,
+	"watch":        watchCmd,
 }
 
 var nameFromCommand = []string{
@@ -49,6 +55,7 @@ var nameFromCommand = []string{
 	"fix",
 	"update-repos",
 	"help",
+	"config",
 }
 
 func (cmd command) String() string {
@@ -94,9 +101,18 @@ func run(wd string, args []string) error {
 	case fixCmd, updateCmd:
 		return runFixUpdate(wd, cmd, args)
 	case helpCmd:
+		if len(args) == 1 && args[0] == "directives" {
+			return helpDirectives(os.Stdout)
+		}
 		return help()
 	case updateReposCmd:
 		return updateRepos(wd, args)
+	case configCmd:
+		return runConfig(wd, args, os.Stdout)
+	case watchCmd:
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return runWatch(ctx, wd, args, nil)
 	default:
 		log.Panicf("unknown command: %v", cmd)
 	}
@@ -122,7 +138,12 @@ Gazelle defaults to "update".
       existing rules.
   update-repos - updates repository rules in the WORKSPACE file. Run with
       -h for details.
-  help - show this message.
+  config - prints the effective configuration of a directory and where each
+      setting came from. Run with -h for details.
+  watch - updates build files like update, then keeps running and updates
+      packages as files in them change. Stop with Ctrl-C.
+  help - show this message. Run "gazelle help directives" to list the
+      directives Gazelle understands.
 
 For usage information for a specific command, run the command with the -h flag.
 For example:
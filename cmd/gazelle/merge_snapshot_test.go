@@ -0,0 +1,119 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+)
+
+func TestMergeSnapshot(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{
+			Path: "a/a.go",
+			Content: `package a
+
+import _ "example.com/repo/b"
+`,
+		},
+		{
+			Path:    "a/x.go",
+			Content: "package a",
+		},
+		{
+			Path:    "b/b.go",
+			Content: "package b",
+		},
+	})
+	defer cleanup()
+
+	args := []string{"-merge_snapshot=.gazelle/snapshot.json"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gazelle", "snapshot.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Edit the build file by hand, and change the sources and directives
+	// Gazelle generates rules from.
+	if err := os.WriteFile(filepath.Join(dir, "a/BUILD.bazel"), []byte(`load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:prefix example.com/other/a
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/custom/a",
+    visibility = ["//visibility:public"],
+    deps = [
+        "//b",
+        "//extra",
+    ],
+)
+`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a/y.go"), []byte("package a"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:prefix example.com/other/a
+
+go_library(
+    name = "a",
+    srcs = [
+        "a.go",
+        "y.go",
+    ],
+    importpath = "example.com/custom/a",
+    visibility = ["//visibility:public"],
+    deps = [
+        "//b",
+        "//extra",
+    ],
+)
+`,
+		},
+	})
+	want := `a/BUILD.bazel:8: go_library(a): attribute "importpath" was changed by hand and by Gazelle; keeping the value in the build file`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log does not contain %q\n--begin--\n%s--end--\n", want, buf.String())
+	}
+}
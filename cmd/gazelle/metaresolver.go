@@ -54,6 +54,13 @@ func (mr *metaResolver) MappedKind(pkgRel string, kind config.MappedKind) {
 	mr.mappedKinds[pkgRel] = append(mr.mappedKinds[pkgRel], kind)
 }
 
+// ResetPackage forgets mappings recorded for the given package, so it can be
+// processed again.
+func (mr *metaResolver) ResetPackage(pkgRel string) {
+	delete(mr.mappedKinds, pkgRel)
+	delete(mr.aliasedKinds, pkgRel)
+}
+
 // AliasedKinds records the configured wrapper macros for a package
 func (mr *metaResolver) AliasedKinds(pkgRel string, aliasedKinds map[string]string) {
 	// Note: it is somewhat of a hack to store the aliased kinds in the metaResolver
@@ -81,23 +88,26 @@ func (mr *metaResolver) Resolver(r *rule.Rule, pkgRel string) resolve.Resolver {
 	// e.g other_macro should use the go_library resolver here:
 	//   # gazelle:map_kind my_go_library go_library //:foo.bzl
 	//   # gazelle:alias_kind other_macro my_go_library
+	var attrs []config.AttrMapping
 	for _, mappedKind := range mr.mappedKinds[pkgRel] {
 		if mappedKind.KindName == ruleKind {
 			ruleKind = mappedKind.FromKind
+			attrs = mappedKind.Attrs
 			break
 		}
 	}
 
 	// If the underlying kind is different, we need to apply the inverse map_kind operation so that
 	// we get the Resolver for the underlying kind, not the mapped or aliased one that we see in the
-	// existing BUILD file.
-	if ruleKind != r.Kind() {
+	// existing BUILD file. The same applies if the mapping renamed attributes.
+	if ruleKind != r.Kind() || len(attrs) > 0 {
 		fromKindResolver := mr.builtins[ruleKind]
 		if fromKindResolver == nil {
 			return nil
 		}
 		return inverseMapKindResolver{
 			fromKind: ruleKind,
+			mapping:  config.MappedKind{Attrs: attrs},
 			delegate: fromKindResolver,
 		}
 	}
@@ -110,30 +120,55 @@ func (mr *metaResolver) Resolver(r *rule.Rule, pkgRel string) resolve.Resolver {
 // modules to remain ignorant of mapped kinds.
 type inverseMapKindResolver struct {
 	fromKind string
+	// mapping holds the attributes renamed by map_kind. They're renamed
+	// back while the delegate is called.
+	mapping  config.MappedKind
 	delegate resolve.Resolver
 }
 
-var _ resolve.Resolver = (*inverseMapKindResolver)(nil)
+var (
+	_ resolve.Resolver           = (*inverseMapKindResolver)(nil)
+	_ resolve.ConcurrentResolver = (*inverseMapKindResolver)(nil)
+)
 
 func (imkr inverseMapKindResolver) Name() string {
 	return imkr.delegate.Name()
 }
 
 func (imkr inverseMapKindResolver) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
+	defer imkr.unmapAttrs(r)()
 	r = imkr.inverseMapKind(r)
 	return imkr.delegate.Imports(c, r, f)
 }
 
 func (imkr inverseMapKindResolver) Embeds(r *rule.Rule, from label.Label) []label.Label {
+	defer imkr.unmapAttrs(r)()
 	r = imkr.inverseMapKind(r)
 	return imkr.delegate.Embeds(r, from)
 }
 
 func (imkr inverseMapKindResolver) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, r *rule.Rule, imports interface{}, from label.Label) {
+	defer imkr.unmapAttrs(r)()
 	r = imkr.inverseMapKind(r)
 	imkr.delegate.Resolve(c, ix, rc, r, imports, from)
 }
 
+func (imkr inverseMapKindResolver) ConcurrentResolve() bool {
+	cr, ok := imkr.delegate.(resolve.ConcurrentResolver)
+	return ok && cr.ConcurrentResolve()
+}
+
+// unmapAttrs gives attributes renamed by map_kind their original names and
+// returns a function that renames them again. Attributes are shared with
+// the copy made by inverseMapKind, so they're renamed in r itself.
+func (imkr inverseMapKindResolver) unmapAttrs(r *rule.Rule) func() {
+	if len(imkr.mapping.Attrs) == 0 {
+		return func() {}
+	}
+	imkr.mapping.UnmapAttrs(r)
+	return func() { imkr.mapping.MapAttrs(r) }
+}
+
 func (imkr inverseMapKindResolver) inverseMapKind(r *rule.Rule) *rule.Rule {
 	rCopy := *r
 	rCopy.SetKind(imkr.fromKind)
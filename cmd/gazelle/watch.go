@@ -0,0 +1,379 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the watch command waits after a file system
+// event for more events before updating build files. Editors and version
+// control tools often change several files at once.
+var watchDebounce = 100 * time.Millisecond
+
+// repoConfigFiles are files in the repository root directory that affect
+// configuration of the whole repository. When one changes, the watch command
+// starts over.
+var repoConfigFiles = map[string]bool{
+	".bazelignore":    true,
+	".gazelleignore":  true,
+	"MODULE.bazel":    true,
+	"REPO.bazel":      true,
+	"WORKSPACE":       true,
+	"WORKSPACE.bazel": true,
+	"gazelle.json":    true,
+}
+
+// runWatch updates build files like the update command, then watches the
+// repository for changes and updates the affected packages until ctx is
+// canceled. onRun, if not nil, is called after each update with its error.
+// Otherwise, errors are logged.
+func runWatch(ctx context.Context, wd string, args []string, onRun func(error)) error {
+	for {
+		restart, err := watch(ctx, wd, args, onRun)
+		if err != nil || !restart {
+			return err
+		}
+		log.Print("repository configuration changed; starting over")
+	}
+}
+
+// watch performs an initial update, then updates packages as files change.
+// It returns true if a repository configuration file changed and the caller
+// should start over.
+func watch(ctx context.Context, wd string, args []string, onRun func(error)) (restart bool, err error) {
+	cexts := make([]config.Configurer, 0, len(languages)+4)
+	cexts = append(cexts,
+		&config.CommonConfigurer{},
+		&updateConfigurer{},
+		&walk.Configurer{},
+		&resolve.Configurer{})
+	for _, lang := range languages {
+		cexts = append(cexts, lang)
+	}
+
+	c, err := newFixUpdateConfiguration(wd, updateCmd, args, cexts)
+	if err != nil {
+		return false, err
+	}
+	uc := getUpdateConfig(c)
+	defer func() {
+		if err := uc.profile.stop(); err != nil {
+			log.Printf("stopping profiler: %v", err)
+		}
+	}()
+	if uc.mode != "fix" {
+		return false, fmt.Errorf("watch: -mode=%s is not supported; build files are always written", uc.mode)
+	}
+	if !c.IndexLibraries || c.IndexLazy {
+		return false, errors.New("watch: only -index=all is supported")
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return false, err
+	}
+	defer fsw.Close()
+
+	w := &watcher{
+		root:    c,
+		cache:   walk.NewCache(),
+		fsw:     fsw,
+		onRun:   onRun,
+		configs: make(map[string]*config.Config),
+		written: make(map[string][]byte),
+		dirty:   make(map[string]bool),
+		removed: make(map[string]bool),
+	}
+	walk.SetCache(c, w.cache)
+	for _, dir := range uc.dirs {
+		rel, err := filepath.Rel(c.RepoRoot, dir)
+		if err != nil {
+			return false, err
+		}
+		if rel = filepath.ToSlash(rel); rel == "." {
+			rel = ""
+		}
+		w.scope = append(w.scope, rel)
+	}
+	w.recursive = uc.walkMode == walk.VisitAllUpdateSubdirsMode
+	uc.emit = w.emit
+
+	w.u = newUpdater(c.Clone(), cexts)
+	w.u.onVisit = w.visit
+	w.u.indexOnly = func(rel string) bool { return !w.inScope(rel) }
+	if err := fixRepoFiles(c, w.u.loads); err != nil {
+		return false, err
+	}
+	w.run(uc.dirs, uc.walkMode)
+	w.u.indexed = func(rel string) bool { return !w.running[rel] }
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return false, nil
+			}
+			log.Print(err)
+
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				return false, nil
+			}
+			w.handle(ev)
+			timer = time.After(watchDebounce)
+
+		case <-timer:
+			timer = nil
+			if w.restart {
+				return true, nil
+			}
+			w.update()
+		}
+	}
+}
+
+// watcher holds state kept by the watch command between updates.
+type watcher struct {
+	// root is the root configuration, after flags are applied. It's cloned
+	// for each update, since the walk configures it with the root build file.
+	root *config.Config
+
+	// u generates and resolves rules. Its rule index is kept between updates.
+	u *updater
+
+	// cache holds directory information read by the walk. Entries are
+	// invalidated when files change.
+	cache *walk.Cache
+
+	fsw   *fsnotify.Watcher
+	onRun func(error)
+
+	// scope lists slash-separated paths to directories that should be updated,
+	// from the command line. If recursive is true, their subdirectories should
+	// be updated, too. Other directories are only indexed.
+	scope     []string
+	recursive bool
+
+	// configs holds the configuration for each visited directory, keyed by
+	// slash-separated path relative to the repository root.
+	configs map[string]*config.Config
+
+	// written holds the content of each build file last written, keyed by
+	// absolute path. Events for these files are ignored if the content
+	// hasn't changed since.
+	written map[string][]byte
+
+	// dirty and removed are sets of directories that changed or were removed
+	// since the last update.
+	dirty, removed map[string]bool
+
+	// running is the set of directories being updated. After the first
+	// update, rules in other directories are already indexed.
+	running map[string]bool
+
+	// restart is set when a repository configuration file changes.
+	restart bool
+}
+
+// run updates build files in dirs and reports the result.
+func (w *watcher) run(dirs []string, mode walk.Mode) {
+	w.u.c = w.root.Clone()
+	err := w.u.run(dirs, mode)
+	uc := getUpdateConfig(w.root)
+	if werr := uc.diagnostics.write(os.Stderr); err == nil {
+		err = werr
+	}
+	uc.diagnostics.diags = nil
+	if w.onRun != nil {
+		w.onRun(err)
+	} else if err != nil {
+		log.Print(err)
+	}
+}
+
+// visit records the configuration for a visited directory and starts
+// watching it. Directories excluded by .bazelignore or exclude directives
+// aren't visited, so they aren't watched.
+func (w *watcher) visit(args walk.Walk2FuncArgs) {
+	w.configs[args.Rel] = args.Config
+	if err := w.fsw.Add(args.Dir); err != nil {
+		log.Print(err)
+	}
+}
+
+// emit writes a build file and records its content, so the watcher can
+// ignore the event for its own change.
+func (w *watcher) emit(c *config.Config, f *rule.File) error {
+	if err := fixFile(c, f); err != nil {
+		return err
+	}
+	w.written[findOutputPath(c, f)] = f.Content
+	return nil
+}
+
+// inScope returns whether the directory rel should be updated, as opposed to
+// only indexed.
+func (w *watcher) inScope(rel string) bool {
+	for _, s := range w.scope {
+		if rel == s || (w.recursive && pathtools.HasPrefix(rel, s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// owner returns the nearest visited directory that contains rel, including
+// rel itself.
+func (w *watcher) owner(rel string) (string, bool) {
+	for {
+		if _, ok := w.configs[rel]; ok {
+			return rel, true
+		}
+		if rel == "" {
+			return "", false
+		}
+		rel = path.Dir(rel)
+		if rel == "." {
+			rel = ""
+		}
+	}
+}
+
+// handle invalidates cached information affected by a file system event and
+// marks directories that need to be updated.
+func (w *watcher) handle(ev fsnotify.Event) {
+	if ev.Op == fsnotify.Chmod {
+		return
+	}
+	rel, err := filepath.Rel(w.root.RepoRoot, ev.Name)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if repoConfigFiles[rel] {
+		w.restart = true
+		return
+	}
+	dirRel := path.Dir(rel)
+	if dirRel == "." {
+		dirRel = ""
+	}
+	owner, ok := w.owner(dirRel)
+	if !ok {
+		return
+	}
+	c := w.configs[owner]
+
+	fi, statErr := os.Lstat(ev.Name)
+	_, wasDir := w.configs[rel]
+	isDir := (statErr == nil && fi.IsDir()) || (statErr != nil && wasDir)
+	if walk.IsExcluded(c, rel, isDir) {
+		return
+	}
+
+	switch {
+	case isDir && statErr != nil:
+		// A directory was removed. Forget it and its subdirectories.
+		for r := range w.configs {
+			if pathtools.HasPrefix(r, rel) {
+				delete(w.configs, r)
+				delete(w.dirty, r)
+				w.removed[r] = true
+			}
+		}
+		w.cache.Invalidate(rel, true)
+		w.cache.Invalidate(dirRel, false)
+		w.dirty[owner] = true
+
+	case isDir:
+		// A directory was created or moved here. Update it and its
+		// subdirectories, which won't be watched until they're visited.
+		w.cache.Invalidate(dirRel, false)
+		w.dirty[owner] = true
+		filepath.WalkDir(ev.Name, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				if r, err := filepath.Rel(w.root.RepoRoot, p); err == nil {
+					w.dirty[filepath.ToSlash(r)] = true
+				}
+			}
+			return nil
+		})
+
+	case c.IsValidBuildFileName(path.Base(rel)) || path.Base(rel) == ".gitignore":
+		if content, err := os.ReadFile(ev.Name); err == nil && bytes.Equal(content, w.written[ev.Name]) {
+			return
+		}
+		// Directives in the build file and patterns in .gitignore may affect
+		// subdirectories.
+		w.cache.Invalidate(dirRel, true)
+		w.dirty[owner] = true
+		w.dirty[dirRel] = true
+		for r := range w.configs {
+			if pathtools.HasPrefix(r, dirRel) {
+				w.dirty[r] = true
+			}
+		}
+
+	default:
+		w.cache.Invalidate(dirRel, false)
+		w.dirty[owner] = true
+	}
+}
+
+// update regenerates and resolves rules in directories that changed since
+// the last update. Rules from those directories are removed from the index
+// first; rules in other directories stay indexed.
+func (w *watcher) update() {
+	for rel := range w.removed {
+		w.u.ruleIndex.RemovePackage(rel)
+		w.u.mrslv.ResetPackage(rel)
+	}
+	dirs := make([]string, 0, len(w.dirty))
+	for rel := range w.dirty {
+		w.u.ruleIndex.RemovePackage(rel)
+		w.u.mrslv.ResetPackage(rel)
+		dirs = append(dirs, filepath.Join(w.root.RepoRoot, filepath.FromSlash(rel)))
+	}
+	sort.Strings(dirs)
+	w.running = w.dirty
+	w.dirty = make(map[string]bool)
+	w.removed = make(map[string]bool)
+	if len(dirs) > 0 {
+		w.run(dirs, walk.UpdateDirsMode)
+	}
+}
@@ -0,0 +1,137 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+)
+
+func TestWatch(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: ".bazelignore", Content: "ignored"},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:prefix example.com/repo",
+		},
+		{Path: "a/a.go", Content: "package a"},
+		{Path: "b/b.go", Content: "package b"},
+		{Path: "ignored/x.go", Content: "package x"},
+	})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan error, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- runWatch(ctx, dir, nil, func(err error) { runs <- err })
+	}()
+	// stop cancels the watcher and waits for its goroutine to return, so
+	// nothing is written to the directory while files are checked.
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	defer stop()
+	waitForRun := func() {
+		t.Helper()
+		select {
+		case err := <-runs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for update")
+		}
+	}
+	writeFile := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitForRun()
+
+	// A new import in an existing package is resolved with the resident index.
+	writeFile("b/b.go", "package b\n\nimport _ \"example.com/repo/a\"\n")
+	waitForRun()
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{{
+		Path: "b/BUILD.bazel",
+		Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/repo/b",
+    visibility = ["//visibility:public"],
+    deps = ["//a"],
+)
+`,
+	}})
+
+	// Changes in ignored directories don't cause an update.
+	writeFile("ignored/x.go", "package x\n\nimport _ \"example.com/repo/a\"\n")
+
+	// New directories are updated and watched.
+	writeFile("c/c.go", "package c\n\nimport _ \"example.com/repo/b\"\n")
+	waitForRun()
+	writeFile("c/c2.go", "package c\n")
+	waitForRun()
+	stop()
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "c/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "c",
+    srcs = [
+        "c.go",
+        "c2.go",
+    ],
+    importpath = "example.com/repo/c",
+    visibility = ["//visibility:public"],
+    deps = ["//b"],
+)
+`,
+		},
+		{Path: "ignored/BUILD.bazel", NotExist: true},
+	})
+	select {
+	case err := <-runs:
+		t.Fatalf("unexpected update (err %v)", err)
+	default:
+	}
+}
@@ -29,7 +29,7 @@ package config
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -96,12 +96,27 @@ type Config struct {
 	// # gazelle:map_kind.
 	KindMap map[string]MappedKind
 
+	// ConditionalKindMap maps from a kind name to replacements that only
+	// apply to some rules, set with # gazelle:map_kind directives that have
+	// tag= or path= conditions. Replacements are listed in order of
+	// precedence and are checked before KindMap. Use LookupMappedKind to find
+	// the replacement for a rule.
+	ConditionalKindMap map[string][]MappedKind
+
 	// AliasMap maps a wrapper macro name to the kind of rule that it wraps.
 	// It provides a way for users to define custom macros that generate rules
 	// that are understood by gazelle, while still allowing gazelle to update
 	// the attrs for the macro calls. Configured via # gazelle:macro.
 	AliasMap map[string]string
 
+	// ManagedAttrs maps rule kinds to attributes whose management was changed
+	// by # gazelle:managed_attrs directives. An attribute mapped to true is
+	// merged like attributes in rule.KindInfo.MergeableAttrs. An attribute
+	// mapped to false is not merged: Gazelle sets it on new rules but doesn't
+	// change it in existing rules. Inner maps must not be modified, since
+	// they're shared with parent directories.
+	ManagedAttrs map[string]map[string]bool
+
 	// Repos is a list of repository rules declared in the main WORKSPACE file
 	// or in macros called by the main WORKSPACE file. This may affect rule
 	// generation and dependency resolution.
@@ -124,11 +139,47 @@ type Config struct {
 	// to the apparent name (repo_name) specified in the MODULE.bazel file. It
 	// returns the empty string if the module is not found.
 	ModuleToApparentName func(string) string
+
+	// Diagnostics receives errors and warnings reported with Report. If nil,
+	// they are logged. The same sink is shared by all directories.
+	Diagnostics DiagnosticSink
+
+	// FS is the file system Gazelle reads directories, build files, and
+	// source files from, rooted at RepoRoot. If nil, the OS file system is
+	// used. Setting FS lets Gazelle run on files that aren't on disk, like
+	// unsaved editor buffers or an in-memory tree in tests. Extensions should
+	// read files with ReadFile, ReadDir, Stat, and WalkDir, which accept the
+	// absolute paths Gazelle passes to extensions.
+	FS fs.FS
+
+	// FileCache holds information extracted from files in earlier runs. The
+	// update command sets it when -cache_dir is given. If nil, nothing is
+	// cached. Extensions may store their own entries in it.
+	FileCache *FileCache
 }
 
 // MappedKind describes a replacement to use for a built-in kind.
 type MappedKind struct {
 	FromKind, KindName, KindLoad string
+
+	// Tags, if not empty, restricts the replacement to rules that have all
+	// of these tags.
+	Tags []string
+
+	// Paths, if not empty, restricts the replacement to directories matching
+	// at least one of these doublestar patterns. Patterns are matched against
+	// slash-separated paths relative to the repository root.
+	Paths []string
+
+	// Attrs lists attributes renamed or removed when the replacement is
+	// applied, in order.
+	Attrs []AttrMapping
+}
+
+// AttrMapping renames the attribute From to To when a kind is mapped. If To
+// is empty, the attribute is removed instead.
+type AttrMapping struct {
+	From, To string
 }
 
 func New() *Config {
@@ -151,6 +202,12 @@ func (c *Config) Clone() *Config {
 	for k, v := range c.KindMap {
 		cc.KindMap[k] = v
 	}
+	if c.ConditionalKindMap != nil {
+		cc.ConditionalKindMap = make(map[string][]MappedKind, len(c.ConditionalKindMap))
+		for k, v := range c.ConditionalKindMap {
+			cc.ConditionalKindMap[k] = v
+		}
+	}
 	return &cc
 }
 
@@ -222,7 +279,7 @@ func (cc *CommonConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *Confi
 	cc.indexLazy = false
 	fs.StringVar(&cc.repoRoot, "repo_root", "", "path to a directory which corresponds to go_prefix, otherwise gazelle searches for it.")
 	fs.Var(indexFlag{indexLibraries: &cc.indexLibraries, indexLazy: &cc.indexLazy}, "index", "determines how Gazelle indexes library rules. 'all' means index all libraries in all repo directories. 'lazy' means specific directories, determined by extensions. 'none' means indexing is disabled.")
-	fs.BoolVar(&cc.strict, "strict", false, "when true, gazelle will exit with none-zero value for build file syntax errors or unknown directives")
+	fs.BoolVar(&cc.strict, "strict", false, "when true, gazelle will exit with none-zero value for build file syntax errors, unknown directives, or invalid directive values")
 	fs.StringVar(&cc.langCsv, "lang", "", "if non-empty, process only these languages (e.g. \"go,proto\")")
 	fs.BoolVar(&cc.bzlmod, "bzlmod", false, "for internal usage only")
 }
@@ -266,7 +323,52 @@ func (cc *CommonConfigurer) CheckFlags(fs *flag.FlagSet, c *Config) error {
 }
 
 func (cc *CommonConfigurer) KnownDirectives() []string {
-	return []string{"map_kind", "alias_kind", "lang"}
+	return []string{"map_kind", "alias_kind", "lang", "managed_attrs"}
+}
+
+var _ DirectiveSchemaProvider = (*CommonConfigurer)(nil)
+
+func (cc *CommonConfigurer) DirectiveSchemas() []DirectiveSchema {
+	return []DirectiveSchema{
+		{
+			Key:     "alias_kind",
+			Usage:   "alias_kind underlying_kind",
+			Doc:     "Treats rules of kind alias_kind, usually a wrapper macro, like rules of underlying_kind when indexing and updating them.",
+			Type:    ArgFields,
+			MinArgs: 2,
+			MaxArgs: 2,
+		},
+		{
+			Key:        "lang",
+			Usage:      "lang1,lang2,...",
+			Doc:        "Restricts rule generation to the listed languages. An empty value enables all languages.",
+			Type:       ArgList,
+			AllowEmpty: true,
+		},
+		{
+			Key:     "managed_attrs",
+			Usage:   "kind [+attr|-attr],...",
+			Doc:     "Changes which attributes of rules of kind Gazelle manages. +attr makes Gazelle merge attr like other generated attributes. -attr makes Gazelle leave attr alone in existing rules. Without a list, changes for the kind are cleared.",
+			Type:    ArgFields,
+			MinArgs: 1,
+			MaxArgs: 2,
+			Check: func(value string) error {
+				_, _, err := parseManagedAttrs(value)
+				return err
+			},
+		},
+		{
+			Key:     "map_kind",
+			Usage:   "from_kind to_kind to_kind_load [tag=tag] [path=glob] [attr=from:to] [drop=attr]",
+			Doc:     "Replaces the kind of generated rules of from_kind with to_kind, loaded from to_kind_load. Optional tag= and path= conditions restrict the replacement to rules with a tag or in matching directories. attr= renames and drop= removes attributes of replaced rules.",
+			Type:    ArgFields,
+			MinArgs: 3,
+			Check: func(value string) error {
+				_, err := parseMappedKind(value)
+				return err
+			},
+		},
+	}
 }
 
 func (cc *CommonConfigurer) Configure(c *Config, rel string, f *rule.File) {
@@ -277,30 +379,28 @@ func (cc *CommonConfigurer) Configure(c *Config, rel string, f *rule.File) {
 		switch d.Key {
 		case "map_kind":
 			vals := strings.Fields(d.Value)
-			if len(vals) != 3 {
-				log.Printf("expected three arguments (gazelle:map_kind from_kind to_kind load_file), got %v", vals)
+			if len(vals) < 3 {
+				c.ReportDirectivef(SeverityWarning, "map-kind-args", f, d, "expected three arguments (gazelle:map_kind from_kind to_kind load_file), got %v", vals)
 				continue
 			}
-			if c.KindMap == nil {
-				c.KindMap = make(map[string]MappedKind)
-			}
-			c.KindMap[vals[0]] = MappedKind{
-				FromKind: vals[0],
-				KindName: vals[1],
-				KindLoad: vals[2],
+			mk, err := parseMappedKind(d.Value)
+			if err != nil {
+				c.ReportDirectivef(SeverityWarning, "map-kind-args", f, d, "%v", err)
+				continue
 			}
+			c.addMappedKind(mk)
 
 		case "alias_kind":
 			vals := strings.Fields(d.Value)
 			if len(vals) != 2 {
-				log.Printf("expected two arguments (gazelle:alias_kind alias_kind underlying_kind), got %v", vals)
+				c.ReportDirectivef(SeverityWarning, "alias-kind-args", f, d, "expected two arguments (gazelle:alias_kind alias_kind underlying_kind), got %v", vals)
 				continue
 			}
 
 			aliasName := vals[0]
 			underlyingKind := vals[1]
 			if aliasName == underlyingKind {
-				log.Printf("alias_kind: alias kind %q is the same as the underlying kind %q", aliasName, underlyingKind)
+				c.ReportDirectivef(SeverityWarning, "alias-kind-self", f, d, "alias_kind: alias kind %q is the same as the underlying kind %q", aliasName, underlyingKind)
 				continue
 			}
 
@@ -309,6 +409,14 @@ func (cc *CommonConfigurer) Configure(c *Config, rel string, f *rule.File) {
 			}
 			c.AliasMap[aliasName] = underlyingKind
 
+		case "managed_attrs":
+			kind, attrs, err := parseManagedAttrs(d.Value)
+			if err != nil {
+				c.ReportDirectivef(SeverityWarning, "managed-attrs-args", f, d, "%v", err)
+				continue
+			}
+			c.setManagedAttrs(kind, attrs)
+
 		case "lang":
 			if len(d.Value) > 0 {
 				c.Langs = strings.Split(d.Value, ",")
@@ -17,10 +17,13 @@ package config
 
 import (
 	"flag"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/bazelbuild/bazel-gazelle/rule"
 )
@@ -77,6 +80,109 @@ func TestCommonConfigurerDirectives(t *testing.T) {
 	}
 }
 
+func TestMapKindConditions(t *testing.T) {
+	c := New()
+	var diags diagnosticsRecorder
+	c.Diagnostics = &diags
+	cc := &CommonConfigurer{}
+	buildData := []byte(`# gazelle:map_kind go_test my_test //:def.bzl
+# gazelle:map_kind go_test integration_test //:def.bzl tag=integration attr=embed:library drop=race
+# gazelle:map_kind go_test old_test //:def.bzl path=legacy/**
+# gazelle:map_kind go_test legacy_test //:def.bzl path=legacy/**
+# gazelle:map_kind go_test bad_test //:def.bzl attr=embed
+`)
+	f, err := rule.LoadData(filepath.Join("test", "BUILD.bazel"), "", buildData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.Configure(c, "", f)
+	if len(diags) != 1 || diags[0].Line != 5 {
+		t.Errorf("got diagnostics %#v; want one for line 5", diags)
+	}
+
+	for _, tc := range []struct {
+		rel  string
+		tags []string
+		want string
+	}{
+		{rel: "a", want: "my_test"},
+		{rel: "a", tags: []string{"manual", "integration"}, want: "integration_test"},
+		{rel: "legacy/x", want: "legacy_test"},
+		{rel: "legacy/x", tags: []string{"integration"}, want: "legacy_test"},
+	} {
+		mk, ok := c.LookupMappedKind("go_test", tc.rel, tc.tags)
+		if !ok || mk.KindName != tc.want {
+			t.Errorf("LookupMappedKind(go_test, %q, %q): got %q, %v; want %q", tc.rel, tc.tags, mk.KindName, ok, tc.want)
+		}
+	}
+
+	mk, _ := c.LookupMappedKind("go_test", "", []string{"integration"})
+	info := mk.MapKindInfo(rule.KindInfo{
+		MatchAttrs:     []string{"embed", "race"},
+		MergeableAttrs: map[string]bool{"embed": true, "race": true, "srcs": true},
+	})
+	wantInfo := rule.KindInfo{
+		MatchAttrs:     []string{"library"},
+		MergeableAttrs: map[string]bool{"library": true, "srcs": true},
+	}
+	if !reflect.DeepEqual(info, wantInfo) {
+		t.Errorf("MapKindInfo: got %#v; want %#v", info, wantInfo)
+	}
+
+	r := rule.NewRule("go_test", "a_test")
+	r.SetAttr("embed", []string{":a"})
+	r.SetAttr("race", "on")
+	mk.MapAttrs(r)
+	if got, want := r.AttrKeys(), []string{"name", "library"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after MapAttrs, got attributes %q; want %q", got, want)
+	}
+	mk.UnmapAttrs(r)
+	if got, want := r.AttrKeys(), []string{"name", "embed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after UnmapAttrs, got attributes %q; want %q", got, want)
+	}
+}
+
+func TestManagedAttrs(t *testing.T) {
+	c := New()
+	var diags diagnosticsRecorder
+	c.Diagnostics = &diags
+	cc := &CommonConfigurer{}
+	f, err := rule.LoadData(filepath.Join("test", "BUILD.bazel"), "", []byte(`# gazelle:managed_attrs go_test -data,-size
+# gazelle:managed_attrs go_library +tags
+# gazelle:managed_attrs go_library tags
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.Configure(c, "", f)
+	if len(diags) != 1 || diags[0].Line != 3 {
+		t.Errorf("got diagnostics %#v; want one for line 3", diags)
+	}
+
+	sub := c.Clone()
+	f, err = rule.LoadData(filepath.Join("test", "sub", "BUILD.bazel"), "sub", []byte(`# gazelle:managed_attrs go_test +size
+# gazelle:managed_attrs go_library
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.Configure(sub, "sub", f)
+
+	wantRoot := map[string]map[string]bool{
+		"go_library": {"tags": true},
+		"go_test":    {"data": false, "size": false},
+	}
+	if !reflect.DeepEqual(c.ManagedAttrs, wantRoot) {
+		t.Errorf("root: got %v; want %v", c.ManagedAttrs, wantRoot)
+	}
+	wantSub := map[string]map[string]bool{
+		"go_test": {"data": false, "size": true},
+	}
+	if !reflect.DeepEqual(sub.ManagedAttrs, wantSub) {
+		t.Errorf("sub: got %v; want %v", sub.ManagedAttrs, wantSub)
+	}
+}
+
 func TestCommonConfigurerRepoName(t *testing.T) {
 	cases := []struct {
 		desc     string
@@ -150,3 +256,230 @@ func TestCommonConfigurerRepoName(t *testing.T) {
 		})
 	}
 }
+
+type diagnosticsRecorder []Diagnostic
+
+func (r *diagnosticsRecorder) Report(d Diagnostic) {
+	*r = append(*r, d)
+}
+
+func TestCommonConfigurerDiagnostics(t *testing.T) {
+	c := New()
+	c.RepoRoot = "/repo"
+	var diags diagnosticsRecorder
+	c.Diagnostics = &diags
+	cc := &CommonConfigurer{}
+	buildData := []byte(`# gazelle:map_kind go_library my_library
+# gazelle:alias_kind my_macro my_macro
+`)
+	f, err := rule.LoadData(filepath.FromSlash("/repo/sub/BUILD.bazel"), "sub", buildData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.Configure(c, "sub", f)
+
+	want := diagnosticsRecorder{
+		{
+			Severity: SeverityWarning,
+			Code:     "map-kind-args",
+			File:     "sub/BUILD.bazel",
+			Line:     1,
+			Message:  "expected three arguments (gazelle:map_kind from_kind to_kind load_file), got [go_library my_library]",
+		},
+		{
+			Severity: SeverityWarning,
+			Code:     "alias-kind-self",
+			File:     "sub/BUILD.bazel",
+			Line:     2,
+			Message:  `alias_kind: alias kind "my_macro" is the same as the underlying kind "my_macro"`,
+		},
+	}
+	if !reflect.DeepEqual(diags, want) {
+		t.Errorf("got diagnostics %#v; want %#v", diags, want)
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	for _, tc := range []struct {
+		d    Diagnostic
+		want string
+	}{
+		{Diagnostic{Message: "oops"}, "oops"},
+		{Diagnostic{File: "a/BUILD", Message: "oops"}, "a/BUILD: oops"},
+		{Diagnostic{File: "a/BUILD", Line: 3, Message: "oops"}, "a/BUILD:3: oops"},
+	} {
+		if got := tc.d.String(); got != tc.want {
+			t.Errorf("%#v: got %q; want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestDirectiveSchemaValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		schema DirectiveSchema
+		value  string
+		ok     bool
+	}{
+		{"string", DirectiveSchema{}, "anything at all", true},
+		{"none", DirectiveSchema{Type: ArgNone}, "", true},
+		{"none_with_value", DirectiveSchema{Type: ArgNone}, "x", false},
+		{"bool", DirectiveSchema{Type: ArgBool}, "true", true},
+		{"bool_invalid", DirectiveSchema{Type: ArgBool}, "yes please", false},
+		{"enum", DirectiveSchema{Type: ArgEnum, Values: []string{"a", "b"}}, "b", true},
+		{"enum_invalid", DirectiveSchema{Type: ArgEnum, Values: []string{"a", "b"}}, "c", false},
+		{"enum_empty", DirectiveSchema{Type: ArgEnum, Values: []string{"a"}}, "", false},
+		{"enum_allow_empty", DirectiveSchema{Type: ArgEnum, Values: []string{"a"}, AllowEmpty: true}, "", true},
+		{"list", DirectiveSchema{Type: ArgList}, "a,b", true},
+		{"list_empty_elem", DirectiveSchema{Type: ArgList}, "a,,b", false},
+		{"list_values", DirectiveSchema{Type: ArgList, Values: []string{"a", "b"}}, "a,c", false},
+		{"fields", DirectiveSchema{Type: ArgFields, MinArgs: 2, MaxArgs: 3}, "a b c", true},
+		{"fields_few", DirectiveSchema{Type: ArgFields, MinArgs: 2, MaxArgs: 3}, "a", false},
+		{"fields_many", DirectiveSchema{Type: ArgFields, MinArgs: 2, MaxArgs: 3}, "a b c d", false},
+		{"fields_unbounded", DirectiveSchema{Type: ArgFields, MinArgs: 1}, "a b c d", true},
+		{"check", DirectiveSchema{Check: func(string) error { return os.ErrInvalid }}, "a", false},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.schema.Validate(tc.value)
+			if tc.ok && err != nil {
+				t.Errorf("got error %v; want success", err)
+			} else if !tc.ok && err == nil {
+				t.Error("got success; want error")
+			}
+		})
+	}
+}
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside.txt")
+	if err := os.WriteFile(outside, []byte("disk"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "repo")
+	c := &Config{
+		RepoRoot: root,
+		FS: fstest.MapFS{
+			"a/b.txt": {Data: []byte("mem")},
+		},
+	}
+
+	for _, name := range []string{filepath.Join(root, "a", "b.txt"), filepath.Join("a", "b.txt")} {
+		if got, err := c.ReadFile(name); err != nil {
+			t.Error(err)
+		} else if string(got) != "mem" {
+			t.Errorf("ReadFile(%q): got %q; want %q", name, got, "mem")
+		}
+	}
+	if got, err := c.ReadFile(outside); err != nil {
+		t.Error(err)
+	} else if string(got) != "disk" {
+		t.Errorf("ReadFile(%q): got %q; want %q", outside, got, "disk")
+	}
+	if ents, err := c.ReadDir(filepath.Join(root, "a")); err != nil {
+		t.Error(err)
+	} else if len(ents) != 1 || ents[0].Name() != "b.txt" {
+		t.Errorf("ReadDir: got %v; want [b.txt]", ents)
+	}
+	if fi, err := c.Stat(filepath.Join(root, "a")); err != nil {
+		t.Error(err)
+	} else if !fi.IsDir() {
+		t.Errorf("Stat: got mode %v; want directory", fi.Mode())
+	}
+
+	var walked []string
+	err := c.WalkDir(root, func(p string, _ fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{root, filepath.Join(root, "a"), filepath.Join(root, "a", "b.txt")}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("WalkDir: got %q; want %q", walked, want)
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	fsys := fstest.MapFS{
+		"a.txt":    {Data: []byte("a"), ModTime: old},
+		"b.txt":    {Data: []byte("b"), ModTime: old.Add(time.Second)},
+		"long.txt": {Data: []byte("long"), ModTime: old},
+		"new.txt":  {Data: []byte("a"), ModTime: time.Now()},
+	}
+	stat := func(name string) fs.FileInfo {
+		fi, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi
+	}
+
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	fc, err := LoadFileCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Put("ns", "a.txt", stat("a.txt"), []byte("a"), "A")
+	fc.Put("ns", "new.txt", stat("new.txt"), []byte("a"), "NEW")
+	if err := fc.Save(cachePath, fsys); err != nil {
+		t.Fatal(err)
+	}
+	fc, err = LoadFileCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc, ns, rel, stat string
+		content             []byte
+		want                string
+	}{
+		{desc: "same_mtime", ns: "ns", rel: "a.txt", stat: "a.txt", want: "A"},
+		{desc: "other_namespace", ns: "other", rel: "a.txt", stat: "a.txt"},
+		{desc: "changed_mtime", ns: "ns", rel: "a.txt", stat: "b.txt"},
+		{desc: "changed_mtime_same_hash", ns: "ns", rel: "a.txt", stat: "b.txt", content: []byte("a"), want: "A"},
+		{desc: "changed_hash", ns: "ns", rel: "a.txt", stat: "b.txt", content: []byte("b")},
+		{desc: "changed_size", ns: "ns", rel: "a.txt", stat: "long.txt", content: []byte("a")},
+		{desc: "racy_mtime", ns: "ns", rel: "new.txt", stat: "new.txt"},
+		{desc: "racy_mtime_same_hash", ns: "ns", rel: "new.txt", stat: "new.txt", content: []byte("a"), want: "NEW"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got string
+			ok := fc.Get(tc.ns, tc.rel, stat(tc.stat), tc.content, &got)
+			if ok != (tc.want != "") || got != tc.want {
+				t.Errorf("got %q, %v; want %q", got, ok, tc.want)
+			}
+		})
+	}
+
+	// Entries that weren't used are saved unless their files were deleted.
+	fc, err = LoadFileCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newInfo := stat("new.txt")
+	delete(fsys, "new.txt")
+	if err := fc.Save(cachePath, fsys); err != nil {
+		t.Fatal(err)
+	}
+	fc, err = LoadFileCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if !fc.Get("ns", "a.txt", stat("a.txt"), nil, &got) {
+		t.Error("unused entry was not saved")
+	}
+	if fc.Get("ns", "new.txt", newInfo, []byte("a"), &got) {
+		t.Error("entry for deleted file was saved")
+	}
+
+	var nilCache *FileCache
+	nilCache.Put("ns", "a.txt", stat("a.txt"), []byte("a"), "A")
+	if nilCache.Get("ns", "a.txt", stat("a.txt"), nil, &got) {
+		t.Error("nil cache: got an entry")
+	}
+}
@@ -0,0 +1,109 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// Setting is one value in the effective configuration of a directory.
+type Setting struct {
+	// Directive is the key of the directive that controls the setting, for
+	// example, "prefix".
+	Directive string
+
+	// Value is the effective value. Where possible, it's formatted like the
+	// directive's argument, so the directive that set it can be found. Settings
+	// that accumulate, like "exclude", are reported once per value.
+	Value string
+
+	// Flag is the name of a command line flag that also controls the setting,
+	// if there is one.
+	Flag string
+
+	// File and Line identify the directive that set the value, if the
+	// Configurer recorded it. File is a slash-separated path relative to the
+	// repository root. If File is empty, the directive is found by matching
+	// Directive and Value against directives in the directory and its parents.
+	File string
+	Line int
+}
+
+// Describer may be implemented by a Configurer to describe the effective
+// configuration of a directory. "gazelle config" prints these settings.
+type Describer interface {
+	// DescribeConfig returns settings in c, the configuration of a directory
+	// after Configure was called for it.
+	DescribeConfig(c *Config) []Setting
+}
+
+var _ Describer = (*CommonConfigurer)(nil)
+
+func (cc *CommonConfigurer) DescribeConfig(c *Config) []Setting {
+	settings := []Setting{{Directive: "lang", Value: strings.Join(c.Langs, ","), Flag: "lang"}}
+
+	fromKinds := make([]string, 0, len(c.KindMap))
+	for from := range c.KindMap {
+		fromKinds = append(fromKinds, from)
+	}
+	sort.Strings(fromKinds)
+	for _, from := range fromKinds {
+		mk := c.KindMap[from]
+		settings = append(settings, Setting{
+			Directive: "map_kind",
+			Value:     mk.directiveValue(),
+		})
+	}
+	fromKinds = fromKinds[:0]
+	for from := range c.ConditionalKindMap {
+		fromKinds = append(fromKinds, from)
+	}
+	sort.Strings(fromKinds)
+	for _, from := range fromKinds {
+		for _, mk := range c.ConditionalKindMap[from] {
+			settings = append(settings, Setting{
+				Directive: "map_kind",
+				Value:     mk.directiveValue(),
+			})
+		}
+	}
+
+	aliases := make([]string, 0, len(c.AliasMap))
+	for alias := range c.AliasMap {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		settings = append(settings, Setting{
+			Directive: "alias_kind",
+			Value:     alias + " " + c.AliasMap[alias],
+		})
+	}
+
+	managedKinds := make([]string, 0, len(c.ManagedAttrs))
+	for kind := range c.ManagedAttrs {
+		managedKinds = append(managedKinds, kind)
+	}
+	sort.Strings(managedKinds)
+	for _, kind := range managedKinds {
+		settings = append(settings, Setting{
+			Directive: "managed_attrs",
+			Value:     c.managedAttrsValue(kind),
+		})
+	}
+	return settings
+}
@@ -0,0 +1,163 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// Severity indicates how serious a Diagnostic is.
+type Severity int
+
+const (
+	// SeverityError indicates a problem that prevented Gazelle from doing
+	// something it was asked to do, like resolving an import.
+	SeverityError Severity = iota
+
+	// SeverityWarning indicates a problem that Gazelle worked around, like
+	// a malformed directive that was ignored.
+	SeverityWarning
+
+	// SeverityInfo indicates something that isn't a problem but may be
+	// useful to know.
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic describes an error or warning found while Gazelle was running.
+type Diagnostic struct {
+	Severity Severity
+
+	// Code is a short, stable identifier for the kind of problem, for
+	// example, "ambiguous-import". Tools may use this to filter or group
+	// diagnostics, so it should not change once published.
+	Code string
+
+	// File is the slash-separated path to the file the diagnostic is about,
+	// relative to the repository root. Empty if the diagnostic is not about
+	// a specific file.
+	File string
+
+	// Line is the 1-based line number within File. 0 if unknown.
+	Line int
+
+	// Lang is the name of the language extension that reported the
+	// diagnostic. Empty for diagnostics reported by Gazelle itself.
+	Lang string
+
+	// Message is a human-readable description of the problem.
+	Message string
+}
+
+// String formats the diagnostic the way Gazelle logs it.
+func (d Diagnostic) String() string {
+	switch {
+	case d.File != "" && d.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+	case d.File != "":
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	default:
+		return d.Message
+	}
+}
+
+// DiagnosticSink receives diagnostics reported with Config.Report.
+// Implementations must be safe for concurrent use, since diagnostics may be
+// reported from several goroutines at once.
+type DiagnosticSink interface {
+	Report(d Diagnostic)
+}
+
+// Report reports a diagnostic to c.Diagnostics. If c.Diagnostics is nil,
+// the diagnostic is logged.
+func (c *Config) Report(d Diagnostic) {
+	if c.Diagnostics == nil {
+		log.Print(d)
+		return
+	}
+	c.Diagnostics.Report(d)
+}
+
+// Reportf reports a diagnostic about the build file f, which may be nil.
+// The message is formatted with fmt.Sprintf.
+func (c *Config) Reportf(severity Severity, code string, f *rule.File, format string, args ...interface{}) {
+	c.Report(Diagnostic{
+		Severity: severity,
+		Code:     code,
+		File:     c.RelFile(f),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ReportDirectivef reports a diagnostic about the directive d in the build
+// file f. The diagnostic includes the directive's line number. If d was read
+// from another file, like gazelle.json, the diagnostic refers to that file.
+func (c *Config) ReportDirectivef(severity Severity, code string, f *rule.File, d rule.Directive, format string, args ...interface{}) {
+	file, line := c.DirectivePosition(f, d)
+	c.Report(Diagnostic{
+		Severity: severity,
+		Code:     code,
+		File:     file,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// DirectivePosition returns the slash-separated path, relative to the
+// repository root, of the file the directive d in f was read from, and the
+// line it was read from, or 0 if that's not known. The file is the build
+// file f unless d was read from another file, like gazelle.json.
+func (c *Config) DirectivePosition(f *rule.File, d rule.Directive) (file string, line int) {
+	pos := f.DirectivePosition(d)
+	if pos.File != "" {
+		return pos.File, pos.Line
+	}
+	return c.RelFile(f), pos.Line
+}
+
+// RelFile returns the slash-separated path to f relative to the repository
+// root, suitable for Diagnostic.File. It returns "" if f is nil.
+func (c *Config) RelFile(f *rule.File) string {
+	if f == nil {
+		return ""
+	}
+	for _, root := range []string{c.RepoRoot, c.ReadBuildFilesDir} {
+		if root == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(root, f.Path); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path.Join(f.Pkg, filepath.Base(f.Path))
+}
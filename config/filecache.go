@@ -0,0 +1,254 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileCacheVersion should be incremented whenever the format of the cache
+// file changes. Changes to the data stored by an extension should be handled
+// by changing the extension's namespace instead.
+const fileCacheVersion = 1
+
+// fileCacheRacyWindow is how recently a file may have been modified for its
+// modification time not to be trusted. File systems with coarse timestamps
+// may not change the modification time of a file written twice within this
+// window, so entries for such files are only reused if their content hash
+// matches.
+const fileCacheRacyWindow = 2 * time.Second
+
+// FileCache persists information extracted from files and directories across
+// runs, so that files that haven't changed don't need to be read and parsed
+// again.
+//
+// Each entry is stored under a namespace, chosen by the extension that owns
+// it, and a slash-separated path relative to the repository root. Namespaces
+// should include a version that's changed whenever the meaning of the stored
+// data changes. An entry records the size and modification time of the file
+// when it was stored and, for regular files, a hash of its content. Get
+// returns an entry only if those still match, so stale entries are never
+// used; they're replaced by the next Put. Entries loaded from the cache are
+// saved again unless their files no longer exist, so runs that only visit
+// some directories keep the entries for the others, and entries for deleted
+// files don't accumulate.
+//
+// Methods may be called on a nil FileCache, which holds no entries. A
+// FileCache may be used concurrently.
+type FileCache struct {
+	mu sync.Mutex
+
+	// old holds entries loaded from the cache file. new holds entries stored
+	// or used during this run, which replace old entries when saved.
+	old, new map[string]fileCacheEntry
+}
+
+type fileCacheFile struct {
+	Version int                       `json:"version"`
+	Entries map[string]fileCacheEntry `json:"entries"`
+}
+
+type fileCacheEntry struct {
+	Size int64 `json:"size"`
+
+	// ModTime is the file's modification time in nanoseconds since the Unix
+	// epoch, or 0 if the file was modified too recently for it to be trusted.
+	ModTime int64 `json:"mtime,omitempty"`
+
+	// Hash is the SHA-256 hash of the file's content, or empty for
+	// directories.
+	Hash string `json:"hash,omitempty"`
+
+	Data json.RawMessage `json:"data"`
+}
+
+// LoadFileCache reads a file cache from path. If the file doesn't exist or
+// was written by a different version of Gazelle, an empty cache is
+// returned. An error is returned only if the file exists but can't be read
+// or parsed.
+func LoadFileCache(path string) (*FileCache, error) {
+	fc := &FileCache{
+		old: make(map[string]fileCacheEntry),
+		new: make(map[string]fileCacheEntry),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fc, nil
+	} else if err != nil {
+		return fc, err
+	}
+	var f fileCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fc, err
+	}
+	if f.Version == fileCacheVersion && f.Entries != nil {
+		fc.old = f.Entries
+	}
+	return fc, nil
+}
+
+// Save writes the cache to path. Entries stored or used during this run are
+// written, along with entries loaded from the cache that weren't used, as
+// long as their files still exist in fsys, the repository root. If fsys is
+// nil, all loaded entries are kept.
+func (fc *FileCache) Save(path string, fsys fs.FS) error {
+	if fc == nil {
+		return nil
+	}
+	fc.mu.Lock()
+	entries := make(map[string]fileCacheEntry, len(fc.old)+len(fc.new))
+	for key, e := range fc.old {
+		if _, ok := fc.new[key]; ok {
+			continue
+		}
+		if fsys != nil {
+			_, rel, _ := strings.Cut(key, ":")
+			if rel == "" {
+				rel = "."
+			}
+			if _, err := fs.Stat(fsys, rel); err != nil {
+				continue
+			}
+		}
+		entries[key] = e
+	}
+	for key, e := range fc.new {
+		entries[key] = e
+	}
+	fc.mu.Unlock()
+	data, err := json.Marshal(fileCacheFile{
+		Version: fileCacheVersion,
+		Entries: entries,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a concurrent run never sees a
+	// partially written cache.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Get looks up the entry for the file rel in the namespace ns and decodes
+// its data into v, which should be a pointer. fi describes the file as it is
+// now.
+//
+// If content is nil, Get returns true only if the file's size and
+// modification time haven't changed since the entry was stored. This avoids
+// reading the file. If content is not nil, it's the file's current content,
+// and Get returns true if its hash matches, even if the modification time
+// changed.
+func (fc *FileCache) Get(ns, rel string, fi fs.FileInfo, content []byte, v any) bool {
+	if fc == nil {
+		return false
+	}
+	key := ns + ":" + rel
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	e, ok := fc.new[key]
+	if !ok {
+		e, ok = fc.old[key]
+	}
+	if !ok || e.Size != fi.Size() {
+		return false
+	}
+	if content == nil {
+		if e.ModTime == 0 || e.ModTime != fi.ModTime().UnixNano() {
+			return false
+		}
+	} else {
+		if e.Hash == "" || e.Hash != hashFileCacheContent(content) {
+			return false
+		}
+		// The content is the same. Record the new modification time, so the
+		// next run doesn't need to read the file.
+		e.ModTime = trustedModTime(fi)
+	}
+	if json.Unmarshal(e.Data, v) != nil {
+		return false
+	}
+	fc.new[key] = e
+	return true
+}
+
+// Put stores v, encoded as JSON, as the entry for the file rel in the
+// namespace ns. fi describes the file, and content is the content that v
+// was extracted from. content should be nil for directories; entries for
+// directories are only reused while their modification time is unchanged.
+func (fc *FileCache) Put(ns, rel string, fi fs.FileInfo, content []byte, v any) {
+	if fc == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	e := fileCacheEntry{
+		Size:    fi.Size(),
+		ModTime: trustedModTime(fi),
+		Data:    data,
+	}
+	if content != nil {
+		e.Hash = hashFileCacheContent(content)
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.new[ns+":"+rel] = e
+}
+
+// trustedModTime returns fi's modification time in nanoseconds since the
+// Unix epoch, or 0 if the file was modified too recently (or in the future)
+// for the time to be trusted.
+func trustedModTime(fi fs.FileInfo) int64 {
+	if time.Since(fi.ModTime()) < fileCacheRacyWindow {
+		return 0
+	}
+	return fi.ModTime().UnixNano()
+}
+
+func hashFileCacheContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fsPath returns the path within c.FS for name, an OS file path. It returns
+// false if c.FS is nil or name is not in c.RepoRoot, in which case name
+// should be read from the OS file system.
+func (c *Config) fsPath(name string) (string, bool) {
+	if c.FS == nil {
+		return "", false
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(c.RepoRoot, name)
+	}
+	rel, err := filepath.Rel(c.RepoRoot, name)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// ReadFile reads the file name. name is an absolute path or a path relative
+// to c.RepoRoot. Files in c.RepoRoot are read from c.FS if it's set.
+// Other files are read from the OS file system.
+func (c *Config) ReadFile(name string) ([]byte, error) {
+	if p, ok := c.fsPath(name); ok {
+		return fs.ReadFile(c.FS, p)
+	}
+	return os.ReadFile(name)
+}
+
+// ReadDir reads the directory name and returns its entries sorted by file
+// name, like os.ReadDir. Paths are interpreted as in ReadFile.
+func (c *Config) ReadDir(name string) ([]fs.DirEntry, error) {
+	if p, ok := c.fsPath(name); ok {
+		return fs.ReadDir(c.FS, p)
+	}
+	return os.ReadDir(name)
+}
+
+// Stat returns information about the file name, following symbolic links,
+// like os.Stat. Paths are interpreted as in ReadFile.
+func (c *Config) Stat(name string) (fs.FileInfo, error) {
+	if p, ok := c.fsPath(name); ok {
+		return fs.Stat(c.FS, p)
+	}
+	return os.Stat(name)
+}
+
+// WalkDir walks the file tree rooted at root, an absolute path, like
+// filepath.WalkDir. Paths are interpreted as in ReadFile. Paths passed to fn
+// are absolute OS file paths.
+func (c *Config) WalkDir(root string, fn fs.WalkDirFunc) error {
+	p, ok := c.fsPath(root)
+	if !ok {
+		return filepath.WalkDir(root, fn)
+	}
+	return fs.WalkDir(c.FS, p, func(p string, d fs.DirEntry, err error) error {
+		return fn(filepath.Join(c.RepoRoot, filepath.FromSlash(p)), d, err)
+	})
+}
@@ -0,0 +1,84 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parseManagedAttrs parses the value of a # gazelle:managed_attrs directive:
+// a kind followed by a comma-separated list of attributes, each prefixed
+// with + to start managing it or - to stop. The list may be omitted to
+// clear the changes made for the kind.
+func parseManagedAttrs(value string) (kind string, attrs map[string]bool, err error) {
+	fields := strings.Fields(value)
+	if len(fields) < 1 || len(fields) > 2 {
+		return "", nil, fmt.Errorf("expected a kind and a list of attributes (gazelle:managed_attrs kind +attr,-attr), got %v", fields)
+	}
+	kind = fields[0]
+	attrs = make(map[string]bool)
+	if len(fields) == 1 {
+		return kind, attrs, nil
+	}
+	for _, a := range strings.Split(fields[1], ",") {
+		if len(a) < 2 || (a[0] != '+' && a[0] != '-') {
+			return "", nil, fmt.Errorf("%q: expected an attribute prefixed with + or -", a)
+		}
+		attrs[a[1:]] = a[0] == '+'
+	}
+	return kind, attrs, nil
+}
+
+// setManagedAttrs records changes made by a # gazelle:managed_attrs
+// directive. Changes are combined with those inherited for the kind, and
+// later changes to an attribute replace earlier ones. An empty attrs clears
+// the changes for the kind.
+func (c *Config) setManagedAttrs(kind string, attrs map[string]bool) {
+	managed := make(map[string]map[string]bool, len(c.ManagedAttrs)+1)
+	for k, v := range c.ManagedAttrs {
+		managed[k] = v
+	}
+	if len(attrs) == 0 {
+		delete(managed, kind)
+	} else {
+		merged := make(map[string]bool, len(managed[kind])+len(attrs))
+		for a, m := range managed[kind] {
+			merged[a] = m
+		}
+		for a, m := range attrs {
+			merged[a] = m
+		}
+		managed[kind] = merged
+	}
+	c.ManagedAttrs = managed
+}
+
+// managedAttrsValue returns the value of a # gazelle:managed_attrs
+// directive that configures the changes for kind.
+func (c *Config) managedAttrsValue(kind string) string {
+	attrs := make([]string, 0, len(c.ManagedAttrs[kind]))
+	for a, m := range c.ManagedAttrs[kind] {
+		if m {
+			attrs = append(attrs, "+"+a)
+		} else {
+			attrs = append(attrs, "-"+a)
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i][1:] < attrs[j][1:] })
+	return kind + " " + strings.Join(attrs, ",")
+}
@@ -0,0 +1,228 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// parseMappedKind parses the value of a # gazelle:map_kind directive:
+// three fields naming the kinds and the load, followed by optional
+// conditions and attribute mappings:
+//
+//	tag=name      the rule must have this tag
+//	path=glob     the directory must match this doublestar pattern
+//	attr=from:to  rename the attribute from to to
+//	drop=attr     remove the attribute
+func parseMappedKind(value string) (MappedKind, error) {
+	vals := strings.Fields(value)
+	if len(vals) < 3 {
+		return MappedKind{}, fmt.Errorf("expected three arguments (gazelle:map_kind from_kind to_kind load_file), got %v", vals)
+	}
+	mk := MappedKind{
+		FromKind: vals[0],
+		KindName: vals[1],
+		KindLoad: vals[2],
+	}
+	for _, opt := range vals[3:] {
+		key, arg, ok := strings.Cut(opt, "=")
+		if !ok || arg == "" {
+			return MappedKind{}, fmt.Errorf("%q: expected tag=, path=, attr=, or drop= followed by a value", opt)
+		}
+		switch key {
+		case "tag":
+			mk.Tags = append(mk.Tags, arg)
+		case "path":
+			if !doublestar.ValidatePattern(arg) {
+				return MappedKind{}, fmt.Errorf("%q: invalid path pattern", opt)
+			}
+			mk.Paths = append(mk.Paths, arg)
+		case "attr":
+			from, to, ok := strings.Cut(arg, ":")
+			if !ok || from == "" || to == "" {
+				return MappedKind{}, fmt.Errorf("%q: expected attr=from:to", opt)
+			}
+			mk.Attrs = append(mk.Attrs, AttrMapping{From: from, To: to})
+		case "drop":
+			mk.Attrs = append(mk.Attrs, AttrMapping{From: arg})
+		default:
+			return MappedKind{}, fmt.Errorf("%q: unknown option %q", opt, key)
+		}
+	}
+	return mk, nil
+}
+
+// directiveValue returns the value of a # gazelle:map_kind directive that
+// configures the replacement.
+func (mk MappedKind) directiveValue() string {
+	vals := []string{mk.FromKind, mk.KindName, mk.KindLoad}
+	for _, tag := range mk.Tags {
+		vals = append(vals, "tag="+tag)
+	}
+	for _, p := range mk.Paths {
+		vals = append(vals, "path="+p)
+	}
+	for _, a := range mk.Attrs {
+		if a.To == "" {
+			vals = append(vals, "drop="+a.From)
+		} else {
+			vals = append(vals, "attr="+a.From+":"+a.To)
+		}
+	}
+	return strings.Join(vals, " ")
+}
+
+// addMappedKind records a replacement set by a directive. A replacement
+// without conditions replaces the previous unconditional replacement for the
+// kind. A replacement with conditions takes precedence over replacements
+// configured earlier and replaces one with the same conditions.
+func (c *Config) addMappedKind(mk MappedKind) {
+	if !mk.IsConditional() {
+		if c.KindMap == nil {
+			c.KindMap = make(map[string]MappedKind)
+		}
+		c.KindMap[mk.FromKind] = mk
+		return
+	}
+	if c.ConditionalKindMap == nil {
+		c.ConditionalKindMap = make(map[string][]MappedKind)
+	}
+	old := c.ConditionalKindMap[mk.FromKind]
+	mks := make([]MappedKind, 0, len(old)+1)
+	mks = append(mks, mk)
+	for _, o := range old {
+		if !slices.Equal(o.Tags, mk.Tags) || !slices.Equal(o.Paths, mk.Paths) {
+			mks = append(mks, o)
+		}
+	}
+	c.ConditionalKindMap[mk.FromKind] = mks
+}
+
+// LookupMappedKind returns the replacement for a rule of the given kind in
+// the directory rel, a slash-separated path relative to the repository
+// root. tags are the rule's tags. Conditional replacements are checked
+// first, then KindMap. LookupMappedKind doesn't follow chains of
+// replacements.
+func (c *Config) LookupMappedKind(kind, rel string, tags []string) (MappedKind, bool) {
+	for _, mk := range c.ConditionalKindMap[kind] {
+		if mk.Matches(rel, tags) {
+			return mk, true
+		}
+	}
+	mk, ok := c.KindMap[kind]
+	return mk, ok
+}
+
+// IsConditional returns whether the replacement only applies to some rules.
+func (mk MappedKind) IsConditional() bool {
+	return len(mk.Tags) > 0 || len(mk.Paths) > 0
+}
+
+// Matches returns whether the replacement's conditions hold for a rule in
+// the directory rel with the given tags.
+func (mk MappedKind) Matches(rel string, tags []string) bool {
+	for _, tag := range mk.Tags {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	if len(mk.Paths) == 0 {
+		return true
+	}
+	for _, p := range mk.Paths {
+		if ok, _ := doublestar.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MapAttrs renames and removes attributes of r as listed in mk.Attrs.
+func (mk MappedKind) MapAttrs(r *rule.Rule) {
+	for _, a := range mk.Attrs {
+		if a.To == "" {
+			if r.Attr(a.From) != nil {
+				r.DelAttr(a.From)
+			}
+		} else {
+			r.RenameAttr(a.From, a.To)
+		}
+	}
+}
+
+// UnmapAttrs reverses the renames done by MapAttrs. Removed attributes
+// can't be restored.
+func (mk MappedKind) UnmapAttrs(r *rule.Rule) {
+	for i := len(mk.Attrs) - 1; i >= 0; i-- {
+		if a := mk.Attrs[i]; a.To != "" {
+			r.RenameAttr(a.To, a.From)
+		}
+	}
+}
+
+// MapKindInfo returns a copy of info for the replacement kind, with
+// attributes renamed and removed as listed in mk.Attrs.
+func (mk MappedKind) MapKindInfo(info rule.KindInfo) rule.KindInfo {
+	if len(mk.Attrs) == 0 {
+		return info
+	}
+	var matchAttrs []string
+	for _, attr := range info.MatchAttrs {
+		for _, a := range mk.Attrs {
+			if attr == a.From {
+				attr = a.To
+			}
+		}
+		if attr != "" {
+			matchAttrs = append(matchAttrs, attr)
+		}
+	}
+	info.MatchAttrs = matchAttrs
+	info.NonEmptyAttrs = mapAttrKeys(mk, info.NonEmptyAttrs)
+	info.SubstituteAttrs = mapAttrKeys(mk, info.SubstituteAttrs)
+	info.MergeableAttrs = mapAttrKeys(mk, info.MergeableAttrs)
+	info.ResolveAttrs = mapAttrKeys(mk, info.ResolveAttrs)
+	info.MergeStrategies = mapAttrKeys(mk, info.MergeStrategies)
+	return info
+}
+
+// mapAttrKeys returns a copy of m, a map keyed by attribute names, with keys
+// renamed and removed as listed in mk.Attrs.
+func mapAttrKeys[V any](mk MappedKind, m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	mapped := make(map[string]V, len(m))
+	for k, v := range m {
+		mapped[k] = v
+	}
+	for _, a := range mk.Attrs {
+		v, ok := mapped[a.From]
+		if !ok {
+			continue
+		}
+		delete(mapped, a.From)
+		if a.To != "" {
+			mapped[a.To] = v
+		}
+	}
+	return mapped
+}
@@ -0,0 +1,150 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ArgType describes the grammar of a directive's value.
+type ArgType int
+
+const (
+	// ArgString accepts any value.
+	ArgString ArgType = iota
+
+	// ArgNone accepts only an empty value.
+	ArgNone
+
+	// ArgBool accepts a value parsed by strconv.ParseBool.
+	ArgBool
+
+	// ArgEnum accepts one of DirectiveSchema.Values.
+	ArgEnum
+
+	// ArgList accepts a comma-separated list. If DirectiveSchema.Values is
+	// set, each element must be one of them.
+	ArgList
+
+	// ArgFields accepts whitespace-separated fields. The number of fields
+	// must be between DirectiveSchema.MinArgs and DirectiveSchema.MaxArgs.
+	ArgFields
+)
+
+// DirectiveSchema describes a directive: the grammar of its value and how
+// it's documented. Gazelle checks directive values against schemas before
+// Configure is called and reports invalid directives, so Configurers don't
+// see them.
+type DirectiveSchema struct {
+	// Key is the name of the directive, for example, "prefix".
+	Key string
+
+	// Usage is a short synopsis of the directive's arguments, for example,
+	// "from_kind to_kind load_file". Empty if the directive takes none.
+	Usage string
+
+	// Doc describes what the directive does, in one or more sentences.
+	Doc string
+
+	// Type is the grammar of the value.
+	Type ArgType
+
+	// Values lists allowed values for ArgEnum and ArgList.
+	Values []string
+
+	// MinArgs and MaxArgs bound the number of fields for ArgFields. If
+	// MaxArgs is 0, there is no upper bound.
+	MinArgs, MaxArgs int
+
+	// AllowEmpty permits an empty value, regardless of Type. Directives that
+	// reset a setting to its default with an empty value should set this.
+	AllowEmpty bool
+
+	// Check, if set, is called after the value is checked against Type. It
+	// returns an error if the value is not valid.
+	Check func(value string) error
+}
+
+// DirectiveSchemaProvider may be implemented by a Configurer to describe the
+// directives it knows. Each directive in KnownDirectives should have a
+// schema. "gazelle help directives" prints documentation from schemas.
+type DirectiveSchemaProvider interface {
+	DirectiveSchemas() []DirectiveSchema
+}
+
+// Validate returns an error if value is not valid for the directive.
+func (s DirectiveSchema) Validate(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" && s.AllowEmpty {
+		return nil
+	}
+
+	switch s.Type {
+	case ArgNone:
+		if value != "" {
+			return errors.New("takes no arguments")
+		}
+
+	case ArgBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+
+	case ArgEnum:
+		if !contains(s.Values, value) {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(s.Values, ", "))
+		}
+
+	case ArgList:
+		for _, elem := range strings.Split(value, ",") {
+			elem = strings.TrimSpace(elem)
+			if elem == "" {
+				return fmt.Errorf("list %q has an empty element", value)
+			}
+			if s.Values != nil && !contains(s.Values, elem) {
+				return fmt.Errorf("%q is not one of %s", elem, strings.Join(s.Values, ", "))
+			}
+		}
+
+	case ArgFields:
+		n := len(strings.Fields(value))
+		switch {
+		case s.MaxArgs == s.MinArgs && n != s.MinArgs:
+			return fmt.Errorf("expected %d arguments, got %d", s.MinArgs, n)
+		case n < s.MinArgs:
+			return fmt.Errorf("expected at least %d arguments, got %d", s.MinArgs, n)
+		case s.MaxArgs > 0 && n > s.MaxArgs:
+			return fmt.Errorf("expected at most %d arguments, got %d", s.MaxArgs, n)
+		}
+	}
+
+	if s.Check != nil {
+		return s.Check(value)
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -16,15 +16,24 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//cmd/fetch_repo:module.go"),
     Label("//cmd/fetch_repo:vcs.go"),
     Label("//cmd/gazelle:BUILD.bazel"),
+    Label("//cmd/gazelle:changed.go"),
+    Label("//cmd/gazelle:config.go"),
+    Label("//cmd/gazelle:diagnostics.go"),
     Label("//cmd/gazelle:diff.go"),
+    Label("//cmd/gazelle:explain.go"),
     Label("//cmd/gazelle:fix-update.go"),
     Label("//cmd/gazelle:fix.go"),
+    Label("//cmd/gazelle:generate.go"),
+    Label("//cmd/gazelle:help.go"),
+    Label("//cmd/gazelle:indexcache.go"),
+    Label("//cmd/gazelle:json.go"),
     Label("//cmd/gazelle:langs.go"),
     Label("//cmd/gazelle:main.go"),
     Label("//cmd/gazelle:metaresolver.go"),
     Label("//cmd/gazelle:print.go"),
     Label("//cmd/gazelle:profiler.go"),
     Label("//cmd/gazelle:update-repos.go"),
+    Label("//cmd/gazelle:watch.go"),
     Label("//cmd/generate_repo_config:BUILD.bazel"),
     Label("//cmd/generate_repo_config:main.go"),
     Label("//cmd/move_labels:BUILD.bazel"),
@@ -32,6 +41,13 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//config:BUILD.bazel"),
     Label("//config:config.go"),
     Label("//config:constants.go"),
+    Label("//config:describe.go"),
+    Label("//config:diagnostics.go"),
+    Label("//config:filecache.go"),
+    Label("//config:fs.go"),
+    Label("//config:managedattrs.go"),
+    Label("//config:mapkind.go"),
+    Label("//config:schema.go"),
     Label("//flag:BUILD.bazel"),
     Label("//flag:flag.go"),
     Label("//internal:BUILD.bazel"),
@@ -64,12 +80,18 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//language/bazel/visibility:config.go"),
     Label("//language/bazel/visibility:lang.go"),
     Label("//language/bazel/visibility:resolve.go"),
+    Label("//language/bridge:BUILD.bazel"),
+    Label("//language/bridge:bridge.go"),
+    Label("//language/bridge:client.go"),
+    Label("//language/bridge:protocol.go"),
+    Label("//language/bridge:rules.go"),
     Label("//language/go:BUILD.bazel"),
     Label("//language/go:build_constraints.go"),
     Label("//language/go:config.go"),
     Label("//language/go:constants.go"),
     Label("//language/go:embed.go"),
     Label("//language/go:fileinfo.go"),
+    Label("//language/go:fileinfo_cache.go"),
     Label("//language/go:fix.go"),
     Label("//language/go/gen_std_package_list:BUILD.bazel"),
     Label("//language/go/gen_std_package_list:gen_std_package_list.go"),
@@ -108,6 +130,7 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//merger:BUILD.bazel"),
     Label("//merger:fix.go"),
     Label("//merger:merger.go"),
+    Label("//merger:snapshot.go"),
     Label("//pathtools:BUILD.bazel"),
     Label("//pathtools:path.go"),
     Label("//repo:BUILD.bazel"),
@@ -115,7 +138,9 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//repo:repo.go"),
     Label("//resolve:BUILD.bazel"),
     Label("//resolve:config.go"),
+    Label("//resolve:explain.go"),
     Label("//resolve:index.go"),
+    Label("//resolve:indexcache.go"),
     Label("//rule:BUILD.bazel"),
     Label("//rule:directives.go"),
     Label("//rule:expr.go"),
@@ -127,6 +152,7 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//rule:types.go"),
     Label("//rule:value.go"),
     Label("//testtools:BUILD.bazel"),
+    Label("//testtools:bridge.go"),
     Label("//testtools:config.go"),
     Label("//testtools:files.go"),
     Label("//tools:BUILD.bazel"),
@@ -137,6 +163,9 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//walk:BUILD.bazel"),
     Label("//walk:cache.go"),
     Label("//walk:config.go"),
+    Label("//walk:dircache.go"),
     Label("//walk:dirinfo.go"),
+    Label("//walk:gitignore.go"),
+    Label("//walk:repoconfig.go"),
     Label("//walk:walk.go"),
 ]
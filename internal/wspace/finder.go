@@ -23,6 +23,11 @@ var workspaceFiles = []string{"WORKSPACE.bazel", "WORKSPACE"}
 // See https://bazel.build/versions/8.0.0/external/overview#repository
 var repoBoundaryMarkerFiles = []string{"WORKSPACE.bazel", "WORKSPACE", "REPO.bazel", "MODULE.bazel"}
 
+// nestedRepoBoundaryMarkerFiles mark the root of a repository nested inside
+// another, like a module added with local_path_override. WORKSPACE files
+// aren't included, since they're common in test data.
+var nestedRepoBoundaryMarkerFiles = []string{"REPO.bazel", "MODULE.bazel"}
+
 // IsWORKSPACE checks whether path is named WORKSPACE or WORKSPACE.bazel
 func IsWORKSPACE(path string) bool {
 	base := filepath.Base(path)
@@ -34,6 +39,17 @@ func IsWORKSPACE(path string) bool {
 	return false
 }
 
+// IsNestedRepoBoundaryFile checks whether a file with the given base name
+// marks the root of a repository nested inside another repository.
+func IsNestedRepoBoundaryFile(name string) bool {
+	for _, boundaryFile := range nestedRepoBoundaryMarkerFiles {
+		if name == boundaryFile {
+			return true
+		}
+	}
+	return false
+}
+
 // FindWORKSPACEFile returns a path to a file in the provided root directory,
 // either to an existing WORKSPACE or WORKSPACE.bazel file, or to root/WORKSPACE
 // if neither exists. Note that this function does NOT recursively check parent directories.
@@ -57,6 +57,26 @@ func (*visibilityExtension) KnownDirectives() []string {
 	return []string{_featureDirectiveName, _visibilityDirectiveName}
 }
 
+var _ config.DirectiveSchemaProvider = (*visibilityExtension)(nil)
+
+// DirectiveSchemas describes the directives this extension operates on.
+func (*visibilityExtension) DirectiveSchemas() []config.DirectiveSchema {
+	return []config.DirectiveSchema{
+		{
+			Key:   _featureDirectiveName,
+			Usage: "feature1,feature2,...",
+			Doc:   "Sets default_features on package rules in generated build files.",
+			Type:  config.ArgList,
+		},
+		{
+			Key:   _visibilityDirectiveName,
+			Usage: "label1,label2,...",
+			Doc:   "Sets default_visibility on package rules in generated build files.",
+			Type:  config.ArgList,
+		},
+	}
+}
+
 // Configure identifies the visibility targets from the directive value, if it exists.
 //
 // To set multiple visibility targets, either multiple directives can be used, or a
@@ -25,6 +25,8 @@ import (
 // TODO: Rename this extension now that it handles multiple package() attributes.
 type visibilityExtension struct{}
 
+var _ language.ConcurrentLanguage = (*visibilityExtension)(nil)
+
 // NewLanguage constructs a new language.Language modifying visibility.
 func NewLanguage() language.Language {
 	return &visibilityExtension{}
@@ -43,6 +45,12 @@ func (*visibilityExtension) Kinds() map[string]rule.KindInfo {
 	}
 }
 
+// ConcurrentGenerateRules returns true because GenerateRules only reads
+// configuration.
+func (*visibilityExtension) ConcurrentGenerateRules() bool {
+	return true
+}
+
 func (*visibilityExtension) Loads() []rule.LoadInfo {
 	panic("ApparentLoads should be called instead")
 }
@@ -45,3 +45,8 @@ func (*visibilityExtension) Embeds(r *rule.Rule, from label.Label) []label.Label
 // Resolve noops because we don't have deps=[] to resolve.
 func (*visibilityExtension) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, r *rule.Rule, imports interface{}, from label.Label) {
 }
+
+// ConcurrentResolve returns true because Resolve does nothing.
+func (*visibilityExtension) ConcurrentResolve() bool {
+	return true
+}
@@ -0,0 +1,407 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bridge provides a language extension that forwards calls to a
+// plugin running in a separate process. Plugins may be written in any
+// language and updated without rebuilding Gazelle.
+//
+// A plugin is an executable that reads JSON-RPC 2.0 requests from its
+// standard input and writes responses to its standard output, one JSON
+// object per line. Anything the plugin writes to standard error is copied
+// to Gazelle's standard error. The methods a plugin must implement, and
+// their parameters and results, are described in protocol.go. Gazelle
+// sends the initialize request first, then the remaining requests one at a
+// time, and finally the shutdown request, after which it closes the
+// plugin's standard input.
+//
+// To use a plugin, add a Go package to a gazelle_binary with a function
+// that creates a bridge language:
+//
+//	func NewLanguage() language.Language {
+//		return bridge.New("fake", exec.Command("path/to/plugin"))
+//	}
+//
+// The plugin is started when Gazelle checks command line flags, so
+// commands that don't generate rules don't start it.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// Language is a language.Language that forwards calls to a plugin.
+type Language struct {
+	language.BaseLifecycleManager
+
+	name string
+	cmd  *exec.Cmd
+
+	stdin   io.WriteCloser
+	client  *Client
+	version string
+
+	knownDirectives []string
+	kinds           map[string]rule.KindInfo
+	loads           []rule.LoadInfo
+}
+
+var (
+	_ language.Language           = (*Language)(nil)
+	_ resolve.IndexCacheVersioner = (*Language)(nil)
+)
+
+// New returns a Language named name that forwards calls to the plugin
+// started by cmd. cmd's Stdin and Stdout are set by the Language. If
+// cmd.Stderr is nil, it's set to os.Stderr.
+func New(name string, cmd *exec.Cmd) *Language {
+	return &Language{name: name, cmd: cmd}
+}
+
+// start starts the plugin and fetches metadata that Gazelle may need before
+// any directory is configured.
+func (l *Language) start() error {
+	if l.client != nil {
+		return nil
+	}
+	if l.cmd.Process != nil {
+		// The plugin was stopped after an earlier run. An exec.Cmd can't be
+		// started twice, so start a copy.
+		l.cmd = copyCmd(l.cmd)
+	}
+	stdin, err := l.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := l.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if l.cmd.Stderr == nil {
+		l.cmd.Stderr = os.Stderr
+	}
+	if err := l.cmd.Start(); err != nil {
+		return fmt.Errorf("starting plugin: %w", err)
+	}
+	l.stdin = stdin
+	l.client = NewClient(stdout, stdin)
+
+	var init InitializeResult
+	if err := l.client.Call(MethodInitialize, InitializeParams{ProtocolVersion: ProtocolVersion, Name: l.name}, &init); err != nil {
+		return l.stopWithError(err)
+	}
+	if init.ProtocolVersion != ProtocolVersion {
+		return l.stopWithError(fmt.Errorf("plugin speaks protocol version %d; want %d", init.ProtocolVersion, ProtocolVersion))
+	}
+	if init.Name != l.name {
+		return l.stopWithError(fmt.Errorf("plugin language is named %q; want %q", init.Name, l.name))
+	}
+	l.version = init.Version
+
+	if err := l.client.Call(MethodKnownDirectives, nil, &l.knownDirectives); err != nil {
+		return l.stopWithError(err)
+	}
+	var kinds map[string]KindInfo
+	if err := l.client.Call(MethodKinds, nil, &kinds); err != nil {
+		return l.stopWithError(err)
+	}
+	l.kinds = make(map[string]rule.KindInfo, len(kinds))
+	for kind, ki := range kinds {
+		info, err := kindInfo(ki)
+		if err != nil {
+			return l.stopWithError(fmt.Errorf("kind %s: %w", kind, err))
+		}
+		l.kinds[kind] = info
+	}
+	var loads []LoadInfo
+	if err := l.client.Call(MethodLoads, nil, &loads); err != nil {
+		return l.stopWithError(err)
+	}
+	l.loads = nil
+	for _, li := range loads {
+		l.loads = append(l.loads, rule.LoadInfo{Name: li.Name, Symbols: li.Symbols, After: li.After})
+	}
+	return nil
+}
+
+// IndexCacheVersion returns the version reported by the plugin along with
+// the size and modification time of the plugin executable, so the index
+// cache is invalidated when the plugin is replaced. The plugin is started
+// if it isn't running yet. If it can't be started, IndexCacheVersion
+// returns an empty string; the error is reported when flags are checked.
+func (l *Language) IndexCacheVersion() string {
+	if err := l.start(); err != nil {
+		return ""
+	}
+	v := fmt.Sprintf("protocol %d version %q", ProtocolVersion, l.version)
+	if fi, err := os.Stat(l.cmd.Path); err == nil {
+		v += fmt.Sprintf(" size %d modified %d", fi.Size(), fi.ModTime().UnixNano())
+	}
+	return v
+}
+
+// copyCmd returns a command that can be started to run cmd again. Stdin and
+// Stdout aren't copied since start replaces them. A Cancel function isn't
+// copied either, since it only works with the Context cmd was created with.
+func copyCmd(cmd *exec.Cmd) *exec.Cmd {
+	c := exec.Command(cmd.Path)
+	c.Args = cmd.Args
+	c.Env = cmd.Env
+	c.Dir = cmd.Dir
+	c.Stderr = cmd.Stderr
+	c.ExtraFiles = cmd.ExtraFiles
+	c.SysProcAttr = cmd.SysProcAttr
+	c.WaitDelay = cmd.WaitDelay
+	return c
+}
+
+func (l *Language) stopWithError(err error) error {
+	l.stdin.Close()
+	l.cmd.Wait()
+	l.client = nil
+	return fmt.Errorf("plugin %s: %w", l.name, err)
+}
+
+// Close sends the shutdown request to the plugin, if it was started, and
+// waits for it to exit.
+func (l *Language) Close() error {
+	if l.client == nil {
+		return nil
+	}
+	callErr := l.client.Call(MethodShutdown, nil, nil)
+	l.client = nil
+	closeErr := l.stdin.Close()
+	waitErr := l.cmd.Wait()
+	if err := errors.Join(callErr, closeErr, waitErr); err != nil {
+		return fmt.Errorf("plugin %s: %w", l.name, err)
+	}
+	return nil
+}
+
+// reportf reports an error about the build file f, which may be nil.
+func (l *Language) reportf(c *config.Config, f *rule.File, format string, args ...interface{}) {
+	c.Report(config.Diagnostic{
+		Severity: config.SeverityError,
+		Code:     "bridge-error",
+		Lang:     l.name,
+		File:     c.RelFile(f),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// call sends a request to the plugin. If the plugin hasn't been started,
+// call returns an error.
+func (l *Language) call(method string, params, result interface{}) error {
+	if l.client == nil {
+		return fmt.Errorf("plugin %s: %s called before the plugin was started", l.name, method)
+	}
+	if err := l.client.Call(method, params, result); err != nil {
+		return fmt.Errorf("plugin %s: %w", l.name, err)
+	}
+	return nil
+}
+
+func (l *Language) Name() string { return l.name }
+
+func (l *Language) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {}
+
+// CheckFlags starts the plugin.
+func (l *Language) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	return l.start()
+}
+
+func (l *Language) KnownDirectives() []string { return l.knownDirectives }
+
+func (l *Language) Configure(c *config.Config, rel string, f *rule.File) {
+	params := ConfigureParams{Rel: rel}
+	if f != nil {
+		known := make(map[string]bool, len(l.knownDirectives))
+		for _, d := range l.knownDirectives {
+			known[d] = true
+		}
+		for _, d := range f.Directives {
+			if known[d.Key] {
+				params.Directives = append(params.Directives, Directive{Key: d.Key, Value: d.Value})
+			}
+		}
+	}
+	if err := l.call(MethodConfigure, params, nil); err != nil {
+		l.reportf(c, f, "%v", err)
+	}
+}
+
+func (l *Language) Kinds() map[string]rule.KindInfo { return l.kinds }
+
+func (l *Language) Loads() []rule.LoadInfo { return l.loads }
+
+func (l *Language) Fix(c *config.Config, f *rule.File) {}
+
+func (l *Language) GenerateRules(args language.GenerateArgs) language.GenerateResult {
+	params := GenerateParams{
+		Rel:          args.Rel,
+		Dir:          args.Dir,
+		Subdirs:      args.Subdirs,
+		RegularFiles: args.RegularFiles,
+		GenFiles:     args.GenFiles,
+	}
+	if args.File != nil {
+		params.Rules = wireRules(args.File.Rules)
+	}
+	var result GenerateResult
+	if err := l.call(MethodGenerateRules, params, &result); err != nil {
+		l.reportf(args.Config, args.File, "%v", err)
+		return language.GenerateResult{}
+	}
+	if len(result.Imports) != len(result.Gen) {
+		l.reportf(args.Config, args.File, "plugin %s: generateRules returned %d rules but %d import lists", l.name, len(result.Gen), len(result.Imports))
+		return language.GenerateResult{}
+	}
+
+	var res language.GenerateResult
+	for i, wr := range result.Gen {
+		res.Gen = append(res.Gen, newRule(wr))
+		res.Imports = append(res.Imports, result.Imports[i])
+	}
+	for _, wr := range result.Empty {
+		res.Empty = append(res.Empty, newRule(wr))
+	}
+	return res
+}
+
+// embedsKey is the private attribute where Imports stores the labels of
+// libraries embedded by a rule, so Embeds can return them.
+const embedsKey = "_bridge_embeds"
+
+// Imports returns the import specs for r. If r may be imported, Imports
+// also asks the plugin which libraries r embeds, since Embeds can't report
+// errors; the RuleIndex always calls Imports before Embeds.
+func (l *Language) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.ImportSpec {
+	var specs []ImportSpec
+	if err := l.call(MethodImports, ImportsParams{Rel: f.Pkg, Rule: wireRule(r)}, &specs); err != nil {
+		l.reportf(c, f, "%v", err)
+		return nil
+	}
+	if specs == nil {
+		return nil
+	}
+
+	from := label.New(c.RepoName, f.Pkg, r.Name())
+	embeds, err := l.embeds(r, from)
+	if err != nil {
+		l.reportf(c, f, "%v", err)
+	}
+	r.SetPrivateAttr(embedsKey, embeds)
+
+	imps := make([]resolve.ImportSpec, len(specs))
+	for i, s := range specs {
+		imps[i] = resolve.ImportSpec{Lang: s.Lang, Imp: s.Imp}
+	}
+	return imps
+}
+
+// Embeds returns the labels of libraries embedded by r, as fetched by Imports.
+func (l *Language) Embeds(r *rule.Rule, from label.Label) []label.Label {
+	labels, _ := r.PrivateAttr(embedsKey).([]label.Label)
+	return labels
+}
+
+// embeds asks the plugin which libraries r embeds.
+func (l *Language) embeds(r *rule.Rule, from label.Label) ([]label.Label, error) {
+	var strs []string
+	if err := l.call(MethodEmbeds, EmbedsParams{Rel: from.Pkg, Rule: wireRule(r), From: from.String()}, &strs); err != nil {
+		return nil, err
+	}
+	labels := make([]label.Label, 0, len(strs))
+	for _, s := range strs {
+		lbl, err := label.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %s: embedded label %q: %w", l.name, from, s, err)
+		}
+		labels = append(labels, lbl)
+	}
+	return labels, nil
+}
+
+func (l *Language) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, r *rule.Rule, imports interface{}, from label.Label) {
+	params := ResolveParams{Rel: from.Pkg, Rule: wireRule(r), From: from.String()}
+	imps, _ := imports.([]string)
+	for _, imp := range imps {
+		spec := resolve.ImportSpec{Lang: l.name, Imp: imp}
+		ri := ResolvedImport{Imp: imp}
+		if dep, ok := resolve.FindRuleWithOverride(c, spec, l.name); ok {
+			ri.Labels = []string{dep.Rel(from.Repo, from.Pkg).String()}
+			ri.Override = true
+		} else {
+			for _, m := range ix.FindRulesByImportWithConfig(c, spec, l.name) {
+				if !m.IsSelfImport(from) {
+					ri.Labels = append(ri.Labels, m.Label.Rel(from.Repo, from.Pkg).String())
+				}
+			}
+		}
+		params.Imports = append(params.Imports, ri)
+	}
+
+	var result ResolveResult
+	if err := l.call(MethodResolve, params, &result); err != nil {
+		c.Report(config.Diagnostic{
+			Severity: config.SeverityError,
+			Code:     "bridge-error",
+			Lang:     l.name,
+			Message:  fmt.Sprintf("%s: %v", from, err),
+		})
+		return
+	}
+	keys := make([]string, 0, len(result.Attrs))
+	for key := range result.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if v := result.Attrs[key]; v == nil {
+			r.DelAttr(key)
+		} else {
+			r.SetAttr(key, valueFromJSON(v))
+		}
+	}
+}
+
+// Before starts the plugin if it was stopped by AfterResolvingDeps, which
+// happens when "gazelle watch" updates build files more than once.
+func (l *Language) Before(ctx context.Context) {
+	if err := l.start(); err != nil {
+		log.Print(err)
+	}
+}
+
+// AfterResolvingDeps stops the plugin.
+func (l *Language) AfterResolvingDeps(ctx context.Context) {
+	if err := l.Close(); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,245 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/language/bridge"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/google/go-cmp/cmp"
+)
+
+const pluginEnv = "GAZELLE_BRIDGE_TEST_PLUGIN"
+
+// TestMain runs the fake plugin when the test binary is started by
+// fakePlugin, instead of running tests.
+func TestMain(m *testing.M) {
+	if os.Getenv(pluginEnv) == "1" {
+		if err := testtools.ServeFakeBridgePlugin(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakePlugin returns a command that starts this test binary as the fake
+// plugin.
+func fakePlugin() *exec.Cmd {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), pluginEnv+"=1")
+	return cmd
+}
+
+func startFake(t *testing.T) (*bridge.Language, *config.Config) {
+	t.Helper()
+	lang := bridge.New("fake", fakePlugin())
+	c := testtools.NewTestConfig(t, []config.Configurer{&resolve.Configurer{}}, []language.Language{lang}, nil)
+	t.Cleanup(func() {
+		if err := lang.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return lang, c
+}
+
+func TestMetadata(t *testing.T) {
+	lang, _ := startFake(t)
+
+	if diff := cmp.Diff([]string{"fake_prefix"}, lang.KnownDirectives()); diff != "" {
+		t.Errorf("KnownDirectives (-want,+got):\n%s", diff)
+	}
+	wantKinds := map[string]rule.KindInfo{
+		"fake_library": {
+			MatchAny:       true,
+			NonEmptyAttrs:  map[string]bool{"deps": true, "srcs": true},
+			MergeableAttrs: map[string]bool{"srcs": true},
+			ResolveAttrs:   map[string]bool{"deps": true},
+			MergeStrategies: map[string]rule.MergeStrategy{
+				"srcs": {Mode: rule.MergeUnion},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantKinds, lang.Kinds()); diff != "" {
+		t.Errorf("Kinds (-want,+got):\n%s", diff)
+	}
+	wantLoads := []rule.LoadInfo{{Name: "@fake//:def.bzl", Symbols: []string{"fake_library"}}}
+	if diff := cmp.Diff(wantLoads, lang.Loads()); diff != "" {
+		t.Errorf("Loads (-want,+got):\n%s", diff)
+	}
+	if v := lang.IndexCacheVersion(); !strings.Contains(v, `version "1"`) {
+		t.Errorf("IndexCacheVersion: got %q; want the plugin version", v)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	lang, _ := startFake(t)
+
+	// gazelle watch stops and starts languages on each run.
+	for i := 0; i < 2; i++ {
+		lang.AfterResolvingDeps(context.Background())
+		lang.Before(context.Background())
+	}
+	wantLoads := []rule.LoadInfo{{Name: "@fake//:def.bzl", Symbols: []string{"fake_library"}}}
+	if diff := cmp.Diff(wantLoads, lang.Loads()); diff != "" {
+		t.Errorf("Loads (-want,+got):\n%s", diff)
+	}
+}
+
+func TestGenerateAndResolve(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "a/a.fake", Content: "import example.com/b\nimport example.com/self\n"},
+		{Path: "b/b.fake"},
+	})
+	defer cleanup()
+	lang, c := startFake(t)
+	c.RepoRoot = dir
+
+	root, err := rule.LoadData("BUILD.bazel", "", []byte("# gazelle:fake_prefix example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang.Configure(c, "", root)
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver {
+		if _, ok := lang.Kinds()[r.Kind()]; ok {
+			return lang
+		}
+		return nil
+	}, lang)
+	var files []*rule.File
+	var imports []interface{}
+	for _, rel := range []string{"a", "b"} {
+		lang.Configure(c, rel, nil)
+		res := lang.GenerateRules(language.GenerateArgs{
+			Config:       c,
+			Dir:          filepath.Join(dir, rel),
+			Rel:          rel,
+			RegularFiles: []string{rel + ".fake"},
+		})
+		if len(res.Gen) != 1 {
+			t.Fatalf("%s: got %d rules; want 1", rel, len(res.Gen))
+		}
+		f := rule.EmptyFile(filepath.Join(dir, rel, "BUILD.bazel"), rel)
+		res.Gen[0].Insert(f)
+		for _, r := range f.Rules {
+			ix.AddRule(c, r, f)
+		}
+		files = append(files, f)
+		imports = append(imports, res.Imports[0])
+	}
+	ix.Finish()
+
+	f := files[0]
+	r := f.Rules[0]
+	if diff := cmp.Diff([]resolve.ImportSpec{{Lang: "fake", Imp: "example.com/a"}}, lang.Imports(c, r, f)); diff != "" {
+		t.Errorf("Imports (-want,+got):\n%s", diff)
+	}
+	lang.Resolve(c, ix, nil, r, imports[0], label.New("", "a", "a"))
+	f.Sync()
+	want := `fake_library(
+    name = "a",
+    srcs = ["a.fake"],
+    importpath = "example.com/a",
+    deps = ["//b"],
+)
+`
+	if diff := cmp.Diff(want, string(bzl.Format(f.File))); diff != "" {
+		t.Errorf("build file (-want,+got):\n%s", diff)
+	}
+}
+
+func TestNameMismatch(t *testing.T) {
+	lang := bridge.New("other", fakePlugin())
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	err := lang.CheckFlags(fs, config.New())
+	want := `plugin language is named "fake"; want "other"`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v; want %q", err, want)
+	}
+}
+
+func TestPluginError(t *testing.T) {
+	lang, c := startFake(t)
+	r := rule.NewRule("fake_library", "a")
+	imports := []string{"example.com/dup"}
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang }, lang)
+	for _, rel := range []string{"b", "c"} {
+		f := rule.EmptyFile(filepath.Join(rel, "BUILD.bazel"), rel)
+		dup := rule.NewRule("fake_library", rel)
+		dup.SetAttr("importpath", "example.com/dup")
+		dup.Insert(f)
+		ix.AddRule(c, dup, f)
+	}
+	ix.Finish()
+
+	var diags []config.Diagnostic
+	c.Diagnostics = diagnosticRecorder(func(d config.Diagnostic) { diags = append(diags, d) })
+	lang.Resolve(c, ix, nil, r, imports, label.New("", "a", "a"))
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, `import "example.com/dup" is provided by multiple rules: //b, //c`) {
+		t.Errorf("got diagnostics %v; want one about multiple rules", diags)
+	}
+}
+
+func TestEmbeds(t *testing.T) {
+	lang, c := startFake(t)
+	var diags []config.Diagnostic
+	c.Diagnostics = diagnosticRecorder(func(d config.Diagnostic) { diags = append(diags, d) })
+
+	f := rule.EmptyFile(filepath.Join("a", "BUILD.bazel"), "a")
+	r := rule.NewRule("fake_library", "a")
+	r.SetAttr("embed", []string{"//b"})
+	lang.Imports(c, r, f)
+	if diff := cmp.Diff([]label.Label{label.New("", "b", "b")}, lang.Embeds(r, label.New("", "a", "a"))); diff != "" {
+		t.Errorf("Embeds (-want,+got):\n%s", diff)
+	}
+	if len(diags) != 0 {
+		t.Errorf("got diagnostics %v; want none", diags)
+	}
+
+	bad := rule.NewRule("fake_library", "bad")
+	bad.SetAttr("embed", []string{"//b", "//b:c:d"})
+	lang.Imports(c, bad, f)
+	if got := lang.Embeds(bad, label.New("", "a", "bad")); len(got) != 0 {
+		t.Errorf("Embeds: got %v; want none", got)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, `embedded label "//b:c:d"`) {
+		t.Errorf("got diagnostics %v; want one about an invalid label", diags)
+	}
+	if len(diags) == 1 && diags[0].Lang != "fake" {
+		t.Errorf("got diagnostic for language %q; want %q", diags[0].Lang, "fake")
+	}
+}
+
+type diagnosticRecorder func(config.Diagnostic)
+
+func (r diagnosticRecorder) Report(d config.Diagnostic) { r(d) }
@@ -0,0 +1,129 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Request is a JSON-RPC 2.0 request. Requests are written as a single line
+// of JSON, terminated by a newline.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response. Responses are written as a single
+// line of JSON, terminated by a newline. Exactly one of Result and Error
+// should be set.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Client sends requests to a plugin and reads its responses. Requests are
+// sent one at a time; Call may be called concurrently, but calls are
+// serialized.
+type Client struct {
+	mu     sync.Mutex
+	r      *bufio.Reader
+	w      io.Writer
+	nextID int64
+}
+
+// NewClient returns a Client that writes requests to w and reads responses
+// from r.
+func NewClient(r io.Reader, w io.Writer) *Client {
+	return &Client{r: bufio.NewReader(r), w: w, nextID: 1}
+}
+
+// Call sends a request for method with params, which may be nil, and waits
+// for the response. If the response has a result and result is not nil,
+// the result is unmarshaled into it. If the response has an error, Call
+// returns it as an *Error.
+func (c *Client) Call(method string, params, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req := Request{JSONRPC: "2.0", ID: c.nextID, Method: method}
+	c.nextID++
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("%s: encoding params: %w", method, err)
+		}
+		req.Params = data
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("%s: encoding request: %w", method, err)
+	}
+	data = append(data, '\n')
+	if _, err := c.w.Write(data); err != nil {
+		return fmt.Errorf("%s: writing request: %w", method, err)
+	}
+
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("%s: reading response: %w", method, err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return fmt.Errorf("%s: decoding response: %w", method, err)
+	}
+	if resp.ID != req.ID {
+		return fmt.Errorf("%s: got response with id %d; want %d", method, resp.ID, req.ID)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s: %w", method, resp.Error)
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("%s: decoding result: %w", method, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+// ProtocolVersion is the version of the protocol described in this package.
+// It is sent in the initialize request. Plugins must reject requests with
+// a version they don't support. The version is incremented whenever a
+// change is made that existing plugins can't safely ignore.
+const ProtocolVersion = 1
+
+// Methods that Gazelle calls on a plugin. Each corresponds to a method of
+// language.Language, except for initialize and shutdown.
+const (
+	// MethodInitialize is the first request sent to a plugin.
+	// Params: InitializeParams. Result: InitializeResult.
+	MethodInitialize = "initialize"
+
+	// MethodKnownDirectives returns directives the plugin handles.
+	// Params: none. Result: []string.
+	MethodKnownDirectives = "knownDirectives"
+
+	// MethodKinds returns kinds of rules the plugin generates.
+	// Params: none. Result: map[string]KindInfo.
+	MethodKinds = "kinds"
+
+	// MethodLoads returns .bzl files and symbols they define.
+	// Params: none. Result: []LoadInfo.
+	MethodLoads = "loads"
+
+	// MethodConfigure applies directives in a directory.
+	// Params: ConfigureParams. Result: null.
+	MethodConfigure = "configure"
+
+	// MethodGenerateRules generates rules in a directory.
+	// Params: GenerateParams. Result: GenerateResult.
+	MethodGenerateRules = "generateRules"
+
+	// MethodImports returns import strings a rule may be imported by.
+	// Params: ImportsParams. Result: []ImportSpec.
+	MethodImports = "imports"
+
+	// MethodEmbeds returns labels of rules a rule embeds. It's sent after
+	// imports for the same rule, if imports returned a non-null list.
+	// Params: EmbedsParams. Result: []string.
+	MethodEmbeds = "embeds"
+
+	// MethodResolve sets dependency attributes on a rule.
+	// Params: ResolveParams. Result: ResolveResult.
+	MethodResolve = "resolve"
+
+	// MethodShutdown is the last request sent to a plugin. The plugin should
+	// respond, then exit when its standard input is closed.
+	// Params: none. Result: null.
+	MethodShutdown = "shutdown"
+)
+
+// InitializeParams are the parameters of the initialize request.
+type InitializeParams struct {
+	// ProtocolVersion is the version of the protocol Gazelle speaks.
+	ProtocolVersion int `json:"protocolVersion"`
+
+	// Name is the name Gazelle expects the plugin's language to have.
+	Name string `json:"name"`
+}
+
+// InitializeResult is the result of the initialize request.
+type InitializeResult struct {
+	// ProtocolVersion is the version of the protocol the plugin speaks.
+	// It must equal the version in InitializeParams.
+	ProtocolVersion int `json:"protocolVersion"`
+
+	// Name is the name of the plugin's language. It must equal the name in
+	// InitializeParams.
+	Name string `json:"name"`
+
+	// Version is the version of the plugin. It's included in the key for
+	// Gazelle's index cache, so it should change whenever the results of
+	// the imports or embeds methods may change for the same rules. It may
+	// be empty.
+	Version string `json:"version,omitempty"`
+}
+
+// Rule is a rule in a build file.
+//
+// Attribute values may be strings, booleans, numbers, lists, or objects with
+// string keys, nested arbitrarily. When Gazelle sends an existing rule to a
+// plugin, attributes whose values can't be represented this way, like
+// select expressions, are omitted.
+type Rule struct {
+	Kind  string                 `json:"kind"`
+	Name  string                 `json:"name"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// KindInfo describes how rules of a kind are matched and merged.
+// See rule.KindInfo.
+//
+// MergeStrategies maps attribute names to the names of merge modes:
+// "replace", "union", "keep_existing", or "append_only", for
+// rule.MergeReplace, rule.MergeUnion, rule.MergeKeepExisting, and
+// rule.MergeAppendOnly. Merge functions can't be used by plugins.
+type KindInfo struct {
+	MatchAny         bool              `json:"matchAny,omitempty"`
+	MatchAttrs       []string          `json:"matchAttrs,omitempty"`
+	MatchSrcsOverlap float64           `json:"matchSrcsOverlap,omitempty"`
+	NonEmptyAttrs    []string          `json:"nonEmptyAttrs,omitempty"`
+	SubstituteAttrs  []string          `json:"substituteAttrs,omitempty"`
+	MergeableAttrs   []string          `json:"mergeableAttrs,omitempty"`
+	ResolveAttrs     []string          `json:"resolveAttrs,omitempty"`
+	MergeStrategies  map[string]string `json:"mergeStrategies,omitempty"`
+}
+
+// LoadInfo describes a .bzl file and the symbols it defines.
+// See rule.LoadInfo.
+type LoadInfo struct {
+	Name    string   `json:"name"`
+	Symbols []string `json:"symbols"`
+	After   []string `json:"after,omitempty"`
+}
+
+// Directive is a directive comment like "# gazelle:key value".
+type Directive struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ConfigureParams are the parameters of the configure request.
+//
+// Gazelle sends a configure request for each directory it visits, parents
+// before children. Configuration in a directory should be inherited from
+// its parent directory, then modified by Directives.
+type ConfigureParams struct {
+	// Rel is the slash-separated path to the directory, relative to the
+	// repository root. "" for the root directory.
+	Rel string `json:"rel"`
+
+	// Directives lists the directives in the directory's build file that
+	// the plugin returned from knownDirectives, in order.
+	Directives []Directive `json:"directives,omitempty"`
+}
+
+// GenerateParams are the parameters of the generateRules request.
+// See language.GenerateArgs.
+type GenerateParams struct {
+	// Rel is the slash-separated path to the directory, relative to the
+	// repository root. "" for the root directory.
+	Rel string `json:"rel"`
+
+	// Dir is the absolute path to the directory.
+	Dir string `json:"dir"`
+
+	// Rules lists the rules in the directory's existing build file, if there
+	// is one.
+	Rules []Rule `json:"rules,omitempty"`
+
+	Subdirs      []string `json:"subdirs,omitempty"`
+	RegularFiles []string `json:"regularFiles,omitempty"`
+	GenFiles     []string `json:"genFiles,omitempty"`
+}
+
+// GenerateResult is the result of the generateRules request.
+// See language.GenerateResult.
+type GenerateResult struct {
+	Gen   []Rule `json:"gen,omitempty"`
+	Empty []Rule `json:"empty,omitempty"`
+
+	// Imports lists the import strings of each rule in Gen, which Gazelle
+	// resolves before sending a resolve request. It must have the same
+	// length as Gen.
+	Imports [][]string `json:"imports,omitempty"`
+}
+
+// ImportsParams are the parameters of the imports request.
+type ImportsParams struct {
+	// Rel is the slash-separated path to the rule's package, relative to
+	// the repository root.
+	Rel  string `json:"rel"`
+	Rule Rule   `json:"rule"`
+}
+
+// ImportSpec describes an import string a rule may be imported by.
+// See resolve.ImportSpec.
+type ImportSpec struct {
+	Lang string `json:"lang"`
+	Imp  string `json:"imp"`
+}
+
+// EmbedsParams are the parameters of the embeds request.
+type EmbedsParams struct {
+	Rel  string `json:"rel"`
+	Rule Rule   `json:"rule"`
+
+	// From is the label of the rule.
+	From string `json:"from"`
+}
+
+// ResolveParams are the parameters of the resolve request.
+type ResolveParams struct {
+	Rel  string `json:"rel"`
+	Rule Rule   `json:"rule"`
+
+	// From is the label of the rule.
+	From string `json:"from"`
+
+	// Imports lists the rule's import strings returned from generateRules,
+	// and the rules that provide them.
+	Imports []ResolvedImport `json:"imports,omitempty"`
+}
+
+// ResolvedImport describes the rules that provide an import string.
+//
+// Since a plugin doesn't have access to Gazelle's rule index, Gazelle looks
+// up each import before sending a resolve request. If a
+// "# gazelle:resolve" directive matches the import, Labels contains that
+// label and Override is true. Otherwise, Labels contains labels of indexed
+// rules that may be imported with the import string in the plugin's
+// language, excluding the rule itself. The plugin chooses a dependency
+// from Labels, or reports an error if there are none or too many.
+type ResolvedImport struct {
+	Imp      string   `json:"imp"`
+	Labels   []string `json:"labels,omitempty"`
+	Override bool     `json:"override,omitempty"`
+}
+
+// ResolveResult is the result of the resolve request.
+type ResolveResult struct {
+	// Attrs lists attributes to set on the rule. A null value deletes the
+	// attribute.
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
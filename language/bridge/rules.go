@@ -0,0 +1,205 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// wireRule converts r to its JSON representation. Attributes whose values
+// can't be represented are omitted.
+func wireRule(r *rule.Rule) Rule {
+	wr := Rule{Kind: r.Kind(), Name: r.Name()}
+	for _, key := range r.AttrKeys() {
+		if key == "name" {
+			continue
+		}
+		if v, ok := valueFromExpr(r.Attr(key)); ok {
+			if wr.Attrs == nil {
+				wr.Attrs = make(map[string]interface{})
+			}
+			wr.Attrs[key] = v
+		}
+	}
+	return wr
+}
+
+// wireRules converts each rule in rs with wireRule.
+func wireRules(rs []*rule.Rule) []Rule {
+	if len(rs) == 0 {
+		return nil
+	}
+	wrs := make([]Rule, len(rs))
+	for i, r := range rs {
+		wrs[i] = wireRule(r)
+	}
+	return wrs
+}
+
+// newRule creates a rule from its JSON representation.
+func newRule(wr Rule) *rule.Rule {
+	r := rule.NewRule(wr.Kind, wr.Name)
+	keys := make([]string, 0, len(wr.Attrs))
+	for key := range wr.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if v := wr.Attrs[key]; v != nil {
+			r.SetAttr(key, valueFromJSON(v))
+		}
+	}
+	return r
+}
+
+// valueFromExpr converts a Starlark expression to a value that can be
+// encoded as JSON. It returns false if e can't be represented.
+func valueFromExpr(e bzl.Expr) (interface{}, bool) {
+	switch e := e.(type) {
+	case *bzl.StringExpr:
+		return e.Value, true
+	case *bzl.Ident:
+		switch e.Name {
+		case "True":
+			return true, true
+		case "False":
+			return false, true
+		}
+	case *bzl.LiteralExpr:
+		if n, err := strconv.ParseInt(e.Token, 0, 64); err == nil {
+			return n, true
+		}
+		if f, err := strconv.ParseFloat(e.Token, 64); err == nil {
+			return f, true
+		}
+	case *bzl.ListExpr:
+		list := make([]interface{}, 0, len(e.List))
+		for _, elem := range e.List {
+			v, ok := valueFromExpr(elem)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, v)
+		}
+		return list, true
+	case *bzl.DictExpr:
+		dict := make(map[string]interface{}, len(e.List))
+		for _, kv := range e.List {
+			k, ok := kv.Key.(*bzl.StringExpr)
+			if !ok {
+				return nil, false
+			}
+			v, ok := valueFromExpr(kv.Value)
+			if !ok {
+				return nil, false
+			}
+			dict[k.Value] = v
+		}
+		return dict, true
+	}
+	return nil, false
+}
+
+// valueFromJSON converts a value decoded from JSON to a value that can be
+// passed to rule.Rule.SetAttr. Integral numbers are converted to ints, and
+// lists of strings are converted to []string, so they're formatted the same
+// way as values set by languages written in Go.
+func valueFromJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int(v)
+		}
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, elem := range v {
+			s, ok := elem.(string)
+			if !ok {
+				strs = nil
+				break
+			}
+			strs = append(strs, s)
+		}
+		if strs != nil {
+			return strs
+		}
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			list[i] = valueFromJSON(elem)
+		}
+		return list
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			dict[k] = valueFromJSON(elem)
+		}
+		return dict
+	default:
+		return v
+	}
+}
+
+// mergeModes maps the names of merge modes used in KindInfo.MergeStrategies
+// to the modes.
+var mergeModes = map[string]rule.MergeMode{
+	"replace":       rule.MergeReplace,
+	"union":         rule.MergeUnion,
+	"keep_existing": rule.MergeKeepExisting,
+	"append_only":   rule.MergeAppendOnly,
+}
+
+// kindInfo converts the JSON representation of a KindInfo.
+func kindInfo(ki KindInfo) (rule.KindInfo, error) {
+	var strategies map[string]rule.MergeStrategy
+	if len(ki.MergeStrategies) > 0 {
+		strategies = make(map[string]rule.MergeStrategy, len(ki.MergeStrategies))
+		for attr, name := range ki.MergeStrategies {
+			mode, ok := mergeModes[name]
+			if !ok {
+				return rule.KindInfo{}, fmt.Errorf("attribute %q: unknown merge mode %q", attr, name)
+			}
+			strategies[attr] = rule.MergeStrategy{Mode: mode}
+		}
+	}
+	return rule.KindInfo{
+		MatchAny:         ki.MatchAny,
+		MatchAttrs:       ki.MatchAttrs,
+		MatchSrcsOverlap: ki.MatchSrcsOverlap,
+		NonEmptyAttrs:    attrSet(ki.NonEmptyAttrs),
+		SubstituteAttrs:  attrSet(ki.SubstituteAttrs),
+		MergeableAttrs:   attrSet(ki.MergeableAttrs),
+		ResolveAttrs:     attrSet(ki.ResolveAttrs),
+		MergeStrategies:  strategies,
+	}, nil
+}
+
+func attrSet(attrs []string) map[string]bool {
+	if len(attrs) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		set[a] = true
+	}
+	return set
+}
@@ -20,23 +20,16 @@ import (
 	"bytes"
 	"fmt"
 	"go/build/constraint"
-	"os"
 	"strings"
 )
 
-// readTags reads and extracts build tags from the block of comments
-// and blank lines at the start of a file which is separated from the
+// readTags extracts build tags from the block of comments and blank lines
+// at the start of data, the content of a file, which is separated from the
 // rest of the file by a blank line. Each string in the returned slice
 // is the trimmed text of a line after a "+build" prefix.
 // Based on go/build.Context.shouldBuild.
-func readTags(path string) (*buildTags, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	content, err := readComments(f)
+func readTags(data []byte) (*buildTags, error) {
+	content, err := readComments(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +25,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -67,6 +68,9 @@ type goConfig struct {
 	// to infer an importpath for a rule without setting the prefix.
 	prefixSet bool
 
+	// prefixPos is where the prefix was set, if it was set in a file.
+	prefixPos directivePos
+
 	// importMapPrefix is a prefix of a package path, used to generate importmap
 	// attributes. Set with # gazelle:importmap_prefix.
 	importMapPrefix string
@@ -89,6 +93,11 @@ type goConfig struct {
 	// imports in external repositories with unknown naming conventions.
 	goNamingConventionExternal namingConvention
 
+	// goNamingConventionPos and goNamingConventionExternalPos are where
+	// goNamingConvention and goNamingConventionExternal were set by
+	// directives, if they were.
+	goNamingConventionPos, goNamingConventionExternalPos directivePos
+
 	// goProtoCompilers is the protocol buffers compiler(s) to use for go code.
 	goProtoCompilers []string
 
@@ -199,6 +208,20 @@ func getGoConfig(c *config.Config) *goConfig {
 	return c.Exts[goName].(*goConfig)
 }
 
+// directivePos is the file and line where a setting was read, used to
+// explain resolution decisions. file is a slash-separated path relative to
+// the repository root, or "" if the setting wasn't read from a file.
+type directivePos struct {
+	file string
+	line int
+}
+
+// newDirectivePos returns the position of the directive d in f.
+func newDirectivePos(c *config.Config, f *rule.File, d rule.Directive) directivePos {
+	file, line := c.DirectivePosition(f, d)
+	return directivePos{file, line}
+}
+
 func (gc *goConfig) clone() *goConfig {
 	gcCopy := *gc
 	gcCopy.genericTags = make(map[string]bool)
@@ -401,6 +424,85 @@ func (*goLang) KnownDirectives() []string {
 	}
 }
 
+var _ config.DirectiveSchemaProvider = (*goLang)(nil)
+
+func (*goLang) DirectiveSchemas() []config.DirectiveSchema {
+	namingConventions := []string{"go_default_library", "import", "import_alias"}
+	return []config.DirectiveSchema{
+		{
+			Key:   "build_tags",
+			Usage: "tag1,tag2,...",
+			Doc:   "Build tags Gazelle considers true when filtering sources, in addition to platform and release tags.",
+			Type:  config.ArgList,
+		},
+		{
+			Key:   "go_generate_proto",
+			Usage: "true|false",
+			Doc:   "Whether to generate go_proto_library rules for proto_library rules.",
+			Type:  config.ArgBool,
+		},
+		{
+			Key:        "go_grpc_compilers",
+			Usage:      "label1,label2,...",
+			Doc:        "Compilers used by go_proto_library rules for gRPC services. An empty value restores the default.",
+			Type:       config.ArgList,
+			AllowEmpty: true,
+		},
+		{
+			Key:    "go_naming_convention",
+			Usage:  strings.Join(namingConventions, "|"),
+			Doc:    "How go_library, go_binary, and go_test rules are named.",
+			Type:   config.ArgEnum,
+			Values: namingConventions,
+		},
+		{
+			Key:    "go_naming_convention_external",
+			Usage:  strings.Join(namingConventions, "|"),
+			Doc:    "The naming convention assumed for external repositories when it isn't known.",
+			Type:   config.ArgEnum,
+			Values: namingConventions,
+		},
+		{
+			Key:        "go_proto_compilers",
+			Usage:      "label1,label2,...",
+			Doc:        "Compilers used by go_proto_library rules. An empty value restores the default.",
+			Type:       config.ArgList,
+			AllowEmpty: true,
+		},
+		{
+			Key:        "go_search",
+			Usage:      "dir [prefix]",
+			Doc:        "With lazy indexing, index dir when resolving imports that start with prefix. An empty value clears search directories.",
+			Type:       config.ArgFields,
+			MinArgs:    1,
+			MaxArgs:    2,
+			AllowEmpty: true,
+		},
+		{
+			Key:    "go_test",
+			Usage:  "default|file",
+			Doc:    "Whether to generate one go_test per package or one per test file.",
+			Type:   config.ArgEnum,
+			Values: []string{"default", "file"},
+		},
+		{
+			Key:   "go_visibility",
+			Usage: "label",
+			Doc:   "Adds a label to the visibility of generated Go rules. May be repeated.",
+		},
+		{
+			Key:   "importmap_prefix",
+			Usage: "path",
+			Doc:   "Prefix for the importmap attribute of go_library rules in vendor directories.",
+		},
+		{
+			Key:   "prefix",
+			Usage: "path",
+			Doc:   "Go import path prefix corresponding to this directory.",
+		},
+	}
+}
+
 func (*goLang) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
 	gc := newGoConfig()
 	switch cmd {
@@ -566,7 +668,7 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 	}
 
 	if !gc.moduleMode {
-		st, err := os.Stat(filepath.Join(c.RepoRoot, filepath.FromSlash(rel), "go.mod"))
+		st, err := c.Stat(filepath.Join(c.RepoRoot, filepath.FromSlash(rel), "go.mod"))
 		if err == nil && !st.IsDir() {
 			gc.moduleMode = true
 		}
@@ -577,10 +679,11 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 		gc.importMapPrefixRel = rel
 		gc.prefix = ""
 		gc.prefixRel = rel
+		gc.prefixPos = directivePos{}
 	}
 
 	if f != nil {
-		setPrefix := func(prefix string) {
+		setPrefix := func(prefix string, pos directivePos) {
 			if err := checkPrefix(prefix); err != nil {
 				log.Print(err)
 				return
@@ -588,6 +691,7 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 			gc.prefix = prefix
 			gc.prefixSet = true
 			gc.prefixRel = rel
+			gc.prefixPos = pos
 			gc.goSearch = append(gc.goSearch, goSearch{rel: rel, prefix: prefix})
 		}
 		for _, d := range f.Directives {
@@ -608,6 +712,7 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 			case "go_naming_convention":
 				if nc, err := namingConventionFromString(d.Value); err == nil {
 					gc.goNamingConvention = nc
+					gc.goNamingConventionPos = newDirectivePos(c, f, d)
 				} else {
 					log.Print(err)
 				}
@@ -615,6 +720,7 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 			case "go_naming_convention_external":
 				if nc, err := namingConventionFromString(d.Value); err == nil {
 					gc.goNamingConventionExternal = nc
+					gc.goNamingConventionExternalPos = newDirectivePos(c, f, d)
 				} else {
 					log.Print(err)
 				}
@@ -681,7 +787,7 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 				gc.importMapPrefixRel = rel
 
 			case "prefix":
-				setPrefix(d.Value)
+				setPrefix(d.Value, newDirectivePos(c, f, d))
 			}
 		}
 
@@ -697,11 +803,11 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 					if !ok {
 						continue
 					}
-					setPrefix(s.Value)
+					setPrefix(s.Value, directivePos{file: c.RelFile(f)})
 
 				case "gazelle":
 					if prefix := r.AttrString("prefix"); prefix != "" {
-						setPrefix(prefix)
+						setPrefix(prefix, directivePos{file: c.RelFile(f)})
 					}
 				}
 			}
@@ -709,7 +815,7 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 		if !gc.prefixSet {
 			// Parse the module directive out of the go.mod file, if present.
 			goModPath := filepath.Join(c.RepoRoot, filepath.FromSlash(rel), "go.mod")
-			goMod, err := os.ReadFile(goModPath)
+			goMod, err := c.ReadFile(goModPath)
 			// Reading the go.mod file is best-effort and may fail for various reasons, such as
 			// the file not existing or being a directory. Do not report errors.
 			if err == nil {
@@ -718,7 +824,11 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 				if err != nil {
 					log.Printf("parsing %s: %s", goModPath, err)
 				} else {
-					setPrefix(goModFile.Module.Mod.Path)
+					pos := directivePos{file: path.Join(rel, "go.mod")}
+					if goModFile.Module.Syntax != nil {
+						pos.line = goModFile.Module.Syntax.Start.Line
+					}
+					setPrefix(goModFile.Module.Mod.Path, pos)
 				}
 			}
 		}
@@ -729,6 +839,40 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 	}
 }
 
+var _ config.Describer = (*goLang)(nil)
+
+func (*goLang) DescribeConfig(c *config.Config) []config.Setting {
+	gc := getGoConfig(c)
+	var settings []config.Setting
+	if gc.prefixSet {
+		settings = append(settings, config.Setting{Directive: "prefix", Value: gc.prefix, Flag: "go_prefix", File: gc.prefixPos.file, Line: gc.prefixPos.line})
+	}
+	if gc.importMapPrefix != "" {
+		settings = append(settings, config.Setting{Directive: "importmap_prefix", Value: gc.importMapPrefix})
+	}
+
+	var tags []string
+	for tag := range gc.genericTags {
+		if tag != "gc" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	settings = append(settings,
+		config.Setting{Directive: "build_tags", Value: strings.Join(tags, ","), Flag: "build_tags"},
+		config.Setting{Directive: "go_generate_proto", Value: strconv.FormatBool(gc.goGenerateProto)},
+		config.Setting{Directive: "go_naming_convention", Value: gc.goNamingConvention.String(), Flag: "go_naming_convention", File: gc.goNamingConventionPos.file, Line: gc.goNamingConventionPos.line},
+		config.Setting{Directive: "go_naming_convention_external", Value: gc.goNamingConventionExternal.String(), Flag: "go_naming_convention_external", File: gc.goNamingConventionExternalPos.file, Line: gc.goNamingConventionExternalPos.line},
+		config.Setting{Directive: "go_test", Value: gc.testMode.String()},
+		config.Setting{Directive: "go_proto_compilers", Value: strings.Join(gc.goProtoCompilers, ","), Flag: "go_proto_compiler"},
+		config.Setting{Directive: "go_grpc_compilers", Value: strings.Join(gc.goGrpcCompilers, ","), Flag: "go_grpc_compiler"},
+	)
+	for _, v := range gc.goVisibility {
+		settings = append(settings, config.Setting{Directive: "go_visibility", Value: v})
+	}
+	return settings
+}
+
 // checkPrefix checks that a string may be used as a prefix. We forbid local
 // (relative) imports and those beginning with "/". We allow the empty string,
 // but generated rules must not have an empty importpath.
@@ -835,7 +979,7 @@ func detectNamingConvention(c *config.Config, rootFile *rule.File) namingConvent
 		var f *rule.File
 		for _, name := range c.ValidBuildFileNames {
 			fpath := filepath.Join(dir, name)
-			data, err := os.ReadFile(fpath)
+			data, err := c.ReadFile(fpath)
 			if err != nil {
 				continue
 			}
@@ -857,7 +1001,7 @@ func detectNamingConvention(c *config.Config, rootFile *rule.File) namingConvent
 		}
 	}
 
-	ents, err := os.ReadDir(c.RepoRoot)
+	ents, err := c.ReadDir(c.RepoRoot)
 	if err != nil {
 		return importNamingConvention
 	}
@@ -17,13 +17,14 @@ package golang
 
 import (
 	"fmt"
+	"io/fs"
 	"log"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/bazelbuild/bazel-gazelle/config"
 	"golang.org/x/mod/module"
 )
 
@@ -75,7 +76,7 @@ func (f *embeddableNode) isHidden() bool {
 //
 // subdirs, regFiles, and genFiles are lists of subdirectories, regular files,
 // and declared generated files in dir, respectively.
-func newEmbedResolver(dir, rel string, validBuildFileNames []string, pkgRels map[string]bool, subdirs, regFiles, genFiles []string) *embedResolver {
+func newEmbedResolver(c *config.Config, dir, rel string, validBuildFileNames []string, pkgRels map[string]bool, subdirs, regFiles, genFiles []string) *embedResolver {
 	root := &embeddableNode{entries: []*embeddableNode{}}
 	index := make(map[string]*embeddableNode)
 
@@ -107,14 +108,14 @@ func newEmbedResolver(dir, rel string, validBuildFileNames []string, pkgRels map
 	}
 
 	for _, subdir := range subdirs {
-		err := filepath.Walk(filepath.Join(dir, subdir), func(p string, info os.FileInfo, err error) error {
+		err := c.WalkDir(filepath.Join(dir, subdir), func(p string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
 			fileRel, _ := filepath.Rel(dir, p)
 			fileRel = filepath.ToSlash(fileRel)
 			base := filepath.Base(p)
-			if !info.IsDir() {
+			if !d.IsDir() {
 				if !isBadEmbedName(base) {
 					add(fileRel, false)
 					return nil
@@ -130,7 +131,7 @@ func newEmbedResolver(dir, rel string, validBuildFileNames []string, pkgRels map
 				return filepath.SkipDir
 			}
 			for _, name := range validBuildFileNames {
-				if bFileInfo, err := os.Stat(filepath.Join(p, name)); err == nil && !bFileInfo.IsDir() {
+				if bFileInfo, err := c.Stat(filepath.Join(p, name)); err == nil && !bFileInfo.IsDir() {
 					// Directory already contains a build file.
 					return filepath.SkipDir
 				}
@@ -211,18 +211,24 @@ func fileNameInfo(path_ string) fileInfo {
 // otherFileInfo returns information about a non-.go file. It will parse
 // part of the file to determine build tags. If the file can't be read, an
 // error will be logged, and partial information will be returned.
-func otherFileInfo(path string) fileInfo {
+func otherFileInfo(c *config.Config, path string) fileInfo {
 	info := fileNameInfo(path)
 	if info.ext == unknownExt {
 		return info
 	}
 
-	tags, err := readTags(info.path)
+	info, err := cachedFileInfo(c, info, "", func(info fileInfo, content []byte) (fileInfo, bool) {
+		tags, err := readTags(content)
+		if err != nil {
+			log.Printf("%s: error reading file: %v", info.path, err)
+			return info, false
+		}
+		info.tags = tags
+		return info, true
+	})
 	if err != nil {
 		log.Printf("%s: error reading file: %v", info.path, err)
-		return info
 	}
-	info.tags = tags
 	return info
 }
 
@@ -232,13 +238,25 @@ func otherFileInfo(path string) fileInfo {
 // will be returned.
 // This function is intended to match go/build.Context.Import.
 // TODD(#53): extract canonical import path
-func goFileInfo(path, srcdir string) fileInfo {
-	info := fileNameInfo(path)
+func goFileInfo(c *config.Config, path, srcdir string) fileInfo {
+	info, err := cachedFileInfo(c, fileNameInfo(path), srcdir, func(info fileInfo, content []byte) (fileInfo, bool) {
+		return parseGoFileInfo(info, content, srcdir)
+	})
+	if err != nil {
+		log.Printf("%s: error reading go file: %v", info.path, err)
+	}
+	return info
+}
+
+// parseGoFileInfo extracts information about a .go file from its content.
+// It returns false if any errors or warnings were logged.
+func parseGoFileInfo(info fileInfo, content []byte, srcdir string) (fileInfo, bool) {
+	complete := true
 	fset := token.NewFileSet()
-	pf, err := parser.ParseFile(fset, info.path, nil, parser.ImportsOnly|parser.ParseComments)
+	pf, err := parser.ParseFile(fset, info.path, content, parser.ImportsOnly|parser.ParseComments)
 	if err != nil {
 		log.Printf("%s: error reading go file: %v", info.path, err)
-		return info
+		return info, false
 	}
 
 	info.packageName = pf.Name.Name
@@ -262,12 +280,14 @@ func goFileInfo(path, srcdir string) fileInfo {
 			path, err := strconv.Unquote(quoted)
 			if err != nil {
 				log.Printf("%s: error reading go file: %v", info.path, err)
+				complete = false
 				continue
 			}
 
 			if path == "C" {
 				if info.isTest {
 					log.Printf("%s: warning: use of cgo in test not supported", info.path)
+					complete = false
 				}
 				info.isCgo = true
 				cg := spec.Doc
@@ -277,6 +297,7 @@ func goFileInfo(path, srcdir string) fileInfo {
 				if cg != nil {
 					if err := saveCgo(&info, srcdir, cg); err != nil {
 						log.Printf("%s: error reading go file: %v", info.path, err)
+						complete = false
 					}
 				}
 				continue
@@ -288,18 +309,18 @@ func goFileInfo(path, srcdir string) fileInfo {
 		}
 	}
 
-	tags, err := readTags(info.path)
+	tags, err := readTags(content)
 	if err != nil {
 		log.Printf("%s: error reading go file: %v", info.path, err)
-		return info
+		return info, false
 	}
 	info.tags = tags
 
 	if importsEmbed || info.packageName == "main" {
-		pf, err = parser.ParseFile(fset, info.path, nil, parser.ParseComments)
+		pf, err = parser.ParseFile(fset, info.path, content, parser.ParseComments)
 		if err != nil {
 			log.Printf("%s: error reading go file: %v", info.path, err)
-			return info
+			return info, false
 		}
 		for _, cg := range pf.Comments {
 			for _, c := range cg.List {
@@ -317,6 +338,7 @@ func goFileInfo(path, srcdir string) fileInfo {
 				embeds, err := parseGoEmbed(args, pos)
 				if err != nil {
 					log.Printf("%v: parsing //go:embed directive: %v", pos, err)
+					complete = false
 					continue
 				}
 				info.embeds = append(info.embeds, embeds...)
@@ -332,7 +354,7 @@ func goFileInfo(path, srcdir string) fileInfo {
 		}
 	}
 
-	return info
+	return info, complete
 }
 
 // saveCgo extracts CFLAGS, CPPFLAGS, CXXFLAGS, and LDFLAGS directives
@@ -0,0 +1,198 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"go/build/constraint"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+)
+
+// fileInfoCacheNamespace is the namespace for fileInfo records in
+// config.FileCache. It should be changed whenever fileInfoRecord or the
+// information extracted from files changes.
+const fileInfoCacheNamespace = "go.fileinfo.v1"
+
+// cachedFileInfo returns information about a file, using a record from
+// c.FileCache if the file hasn't changed since it was stored. Otherwise, it
+// reads the file, calls parse with its content, and stores the result.
+// info should contain the information from the file's name. parse returns
+// false if it logged errors or warnings; its results aren't stored, so the
+// messages are logged again on the next run.
+//
+// srcdir is the directory used to expand ${SRCDIR} in cgo options, or empty
+// for files other than .go files. Records stored with a different srcdir
+// aren't used.
+func cachedFileInfo(c *config.Config, info fileInfo, srcdir string, parse func(fileInfo, []byte) (fileInfo, bool)) (fileInfo, error) {
+	var rel string
+	var st fs.FileInfo
+	cacheable := false
+	if c.FileCache != nil {
+		if r, err := filepath.Rel(c.RepoRoot, info.path); err == nil && filepath.IsLocal(r) {
+			if st, err = c.Stat(info.path); err == nil {
+				rel, cacheable = filepath.ToSlash(r), true
+			}
+		}
+	}
+
+	var rec fileInfoRecord
+	if cacheable && c.FileCache.Get(fileInfoCacheNamespace, rel, st, nil, &rec) && rec.Srcdir == srcdir {
+		return rec.apply(info), nil
+	}
+
+	content, err := c.ReadFile(info.path)
+	if err != nil {
+		return info, err
+	}
+	if cacheable && c.FileCache.Get(fileInfoCacheNamespace, rel, st, content, &rec) && rec.Srcdir == srcdir {
+		return rec.apply(info), nil
+	}
+
+	info, complete := parse(info, content)
+	if cacheable && complete {
+		c.FileCache.Put(fileInfoCacheNamespace, rel, st, content, newFileInfoRecord(info, srcdir))
+	}
+	return info, nil
+}
+
+// fileInfoRecord holds the information in fileInfo that's extracted from a
+// file's content. Information from the file's name isn't stored.
+type fileInfoRecord struct {
+	Srcdir          string         `json:"srcdir,omitempty"`
+	PackageName     string         `json:"package,omitempty"`
+	HasMainFunction bool           `json:"main,omitempty"`
+	IsExternalTest  bool           `json:"xtest,omitempty"`
+	Imports         []string       `json:"imports,omitempty"`
+	Embeds          []embedRecord  `json:"embeds,omitempty"`
+	IsCgo           bool           `json:"cgo,omitempty"`
+	Tags            *tagsRecord    `json:"tags,omitempty"`
+	CPPOpts         []cgoOptRecord `json:"cppopts,omitempty"`
+	COpts           []cgoOptRecord `json:"copts,omitempty"`
+	CXXOpts         []cgoOptRecord `json:"cxxopts,omitempty"`
+	CLinkOpts       []cgoOptRecord `json:"clinkopts,omitempty"`
+}
+
+type embedRecord struct {
+	Path   string `json:"path"`
+	Offset int    `json:"offset"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// tagsRecord holds a buildTags. The expression is stored in its //go:build
+// form and parsed again when loaded.
+type tagsRecord struct {
+	Expr string   `json:"expr,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type cgoOptRecord struct {
+	Tags *tagsRecord `json:"tags,omitempty"`
+	Opts string      `json:"opts"`
+}
+
+func newFileInfoRecord(info fileInfo, srcdir string) fileInfoRecord {
+	rec := fileInfoRecord{
+		Srcdir:          srcdir,
+		PackageName:     info.packageName,
+		HasMainFunction: info.hasMainFunction,
+		IsExternalTest:  info.isExternalTest,
+		Imports:         info.imports,
+		IsCgo:           info.isCgo,
+		Tags:            newTagsRecord(info.tags),
+		CPPOpts:         newCgoOptRecords(info.cppopts),
+		COpts:           newCgoOptRecords(info.copts),
+		CXXOpts:         newCgoOptRecords(info.cxxopts),
+		CLinkOpts:       newCgoOptRecords(info.clinkopts),
+	}
+	for _, e := range info.embeds {
+		rec.Embeds = append(rec.Embeds, embedRecord{
+			Path:   e.path,
+			Offset: e.pos.Offset,
+			Line:   e.pos.Line,
+			Column: e.pos.Column,
+		})
+	}
+	return rec
+}
+
+// apply returns info with the information from rec added.
+func (rec fileInfoRecord) apply(info fileInfo) fileInfo {
+	info.packageName = rec.PackageName
+	info.hasMainFunction = rec.HasMainFunction
+	info.isExternalTest = rec.IsExternalTest
+	info.imports = rec.Imports
+	info.isCgo = rec.IsCgo
+	info.tags = rec.Tags.buildTags()
+	info.cppopts = cgoOptsFromRecords(rec.CPPOpts)
+	info.copts = cgoOptsFromRecords(rec.COpts)
+	info.cxxopts = cgoOptsFromRecords(rec.CXXOpts)
+	info.clinkopts = cgoOptsFromRecords(rec.CLinkOpts)
+	for _, e := range rec.Embeds {
+		info.embeds = append(info.embeds, fileEmbed{
+			path: e.Path,
+			pos: token.Position{
+				Filename: info.path,
+				Offset:   e.Offset,
+				Line:     e.Line,
+				Column:   e.Column,
+			},
+		})
+	}
+	return info
+}
+
+func newTagsRecord(tags *buildTags) *tagsRecord {
+	if tags == nil {
+		return nil
+	}
+	rec := &tagsRecord{Tags: tags.rawTags}
+	if tags.expr != nil {
+		rec.Expr = tags.expr.String()
+	}
+	return rec
+}
+
+func (rec *tagsRecord) buildTags() *buildTags {
+	if rec == nil {
+		return nil
+	}
+	tags := &buildTags{rawTags: rec.Tags}
+	if rec.Expr != "" {
+		// The expression was valid when stored, so it parses again.
+		tags.expr, _ = constraint.Parse("//go:build " + rec.Expr)
+	}
+	return tags
+}
+
+func newCgoOptRecords(opts []*cgoTagsAndOpts) []cgoOptRecord {
+	var recs []cgoOptRecord
+	for _, o := range opts {
+		recs = append(recs, cgoOptRecord{Tags: newTagsRecord(o.buildTags), Opts: o.opts})
+	}
+	return recs
+}
+
+func cgoOptsFromRecords(recs []cgoOptRecord) []*cgoTagsAndOpts {
+	var opts []*cgoTagsAndOpts
+	for _, r := range recs {
+		opts = append(opts, &cgoTagsAndOpts{buildTags: r.Tags.buildTags(), opts: r.Opts})
+	}
+	return opts
+}
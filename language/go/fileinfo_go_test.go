@@ -16,12 +16,15 @@ limitations under the License.
 package golang
 
 import (
+	"bytes"
 	"go/build/constraint"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -180,7 +183,7 @@ var src string
 				t.Fatal(err)
 			}
 
-			got := goFileInfo(path, "")
+			got := goFileInfo(&config.Config{}, path, "")
 			// Clear fields we don't care about for testing.
 			got = fileInfo{
 				packageName: got.packageName,
@@ -214,7 +217,7 @@ func TestGoFileInfoFailure(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got := goFileInfo(path, "")
+	got := goFileInfo(&config.Config{}, path, "")
 	want := fileInfo{
 		path:   path,
 		name:   name,
@@ -363,7 +366,7 @@ import "C"
 				t.Fatal(err)
 			}
 
-			got := goFileInfo(path, "")
+			got := goFileInfo(&config.Config{}, path, "")
 
 			// Clear fields we don't care about for testing.
 			got = fileInfo{
@@ -382,6 +385,65 @@ import "C"
 	}
 }
 
+func TestGoFileInfoCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cgo.go")
+	content := []byte(`//go:build linux && !arm
+
+package main
+
+/*
+#cgo linux CFLAGS: -I${SRCDIR}/include
+#cgo LDFLAGS: -lm
+*/
+import "C"
+
+import (
+	"embed"
+	"example.com/repo/lib"
+)
+
+//go:embed static/*.txt
+var static embed.FS
+
+func main() {}
+`)
+	if err := os.WriteFile(path, content, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	want := goFileInfo(&config.Config{}, path, "src")
+
+	fc, err := config.LoadFileCache(filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &config.Config{RepoRoot: dir, FileCache: fc}
+	if got := goFileInfo(c, path, "src"); !cmp.Equal(want, got, fileInfoCmpOption) {
+		t.Fatalf("first call: (-want, +got): %s", cmp.Diff(want, got, fileInfoCmpOption))
+	}
+
+	// Overwrite the file with content of the same size, and restore its
+	// modification time. The cached information should be used.
+	if err := os.WriteFile(path, bytes.Repeat([]byte(" "), len(content)), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if got := goFileInfo(c, path, "src"); !cmp.Equal(want, got, fileInfoCmpOption) {
+		t.Errorf("cached call: (-want, +got): %s", cmp.Diff(want, got, fileInfoCmpOption))
+	}
+
+	// Records stored with a different srcdir aren't used.
+	if got := goFileInfo(c, path, "other"); got.packageName != "" {
+		t.Errorf("other srcdir: got package %q; want the file to be parsed again", got.packageName)
+	}
+}
+
 // Copied from go/build build_test.go
 var (
 	expandSrcDirPath = filepath.Join(string(filepath.Separator)+"projects", "src", "add")
@@ -449,7 +511,7 @@ import "C"
 		t,
 		"-repo_root="+repo,
 		"-go_prefix=example.com/repo")
-	fi := goFileInfo(filepath.Join(sub, "sub.go"), "sub")
+	fi := goFileInfo(&config.Config{}, filepath.Join(sub, "sub.go"), "sub")
 	pkgs, _ := buildPackages(c, sub, "sub", false, nil, []fileInfo{fi})
 	got, ok := pkgs["sub"]
 	if !ok {
@@ -521,7 +583,7 @@ func TestGoExperimentsTagsIgnored(t *testing.T) {
 			}
 
 			c, _, _ := testConfig(t)
-			fi := goFileInfo(path, "")
+			fi := goFileInfo(&config.Config{}, path, "")
 			if !checkConstraints(c, "", "", fi.goos, fi.goarch, fi.tags, nil) {
 				t.Fatalf("constraints should be satisfied for %s", tc.desc)
 			}
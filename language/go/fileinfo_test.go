@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -54,7 +55,7 @@ func TestOtherFileInfo(t *testing.T) {
 			}
 			defer os.Remove(tc.name)
 
-			got := otherFileInfo(filepath.Join(dir, tc.name))
+			got := otherFileInfo(&config.Config{}, filepath.Join(dir, tc.name))
 
 			// Only check that we can extract tags. Everything else is covered
 			// by other tests.
@@ -384,18 +385,7 @@ package main`,
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
-			f, err := os.CreateTemp(".", "TestReadTags")
-			if err != nil {
-				t.Fatal(err)
-			}
-			path := f.Name()
-			defer os.Remove(path)
-
-			if _, err := f.WriteString(tc.source); err != nil {
-				t.Fatal(err)
-			}
-
-			if got, err := readTags(path); err != nil {
+			if got, err := readTags([]byte(tc.source)); err != nil {
 				t.Fatal(err)
 			} else if diff := cmp.Diff(tc.want, got, fileInfoCmpOption); diff != "" {
 				t.Errorf("(-want, +got): %s", diff)
@@ -625,7 +615,7 @@ import "C"
 				t.Fatal(err)
 			}
 
-			fi := goFileInfo(path, "")
+			fi := goFileInfo(&config.Config{}, path, "")
 			var cgoTags *cgoTagsAndOpts
 			if len(fi.copts) > 0 {
 				cgoTags = fi.copts[0]
@@ -724,7 +714,7 @@ func TestIsOSArchSpecific(t *testing.T) {
 			if err := os.WriteFile(path, []byte(tc.content), 0o666); err != nil {
 				t.Fatal(err)
 			}
-			fi := goFileInfo(path, "")
+			fi := goFileInfo(&config.Config{}, path, "")
 			var cgoTags *cgoTagsAndOpts
 			if len(fi.copts) > 0 {
 				cgoTags = fi.copts[0]
@@ -111,7 +111,9 @@ func (gl *goLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 	var hasTestdata bool
 	for _, sub := range args.Subdirs {
 		if sub == "testdata" {
+			gl.goPkgRelsMu.RLock()
 			_, ok := gl.goPkgRels[path.Join(args.Rel, "testdata")]
+			gl.goPkgRelsMu.RUnlock()
 			hasTestdata = !ok
 			break
 		}
@@ -138,9 +140,11 @@ func (gl *goLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 	var er *embedResolver
 	for i, name := range goFiles {
 		path := filepath.Join(args.Dir, name)
-		goFileInfos[i] = goFileInfo(path, srcdir)
+		goFileInfos[i] = goFileInfo(c, path, srcdir)
 		if len(goFileInfos[i].embeds) > 0 && er == nil {
-			er = newEmbedResolver(args.Dir, args.Rel, c.ValidBuildFileNames, gl.goPkgRels, args.Subdirs, args.RegularFiles, args.GenFiles)
+			gl.goPkgRelsMu.RLock()
+			er = newEmbedResolver(c, args.Dir, args.Rel, c.ValidBuildFileNames, gl.goPkgRels, args.Subdirs, args.RegularFiles, args.GenFiles)
+			gl.goPkgRelsMu.RUnlock()
 		}
 	}
 	goPackageMap, goFilesWithUnknownPackage := buildPackages(c, args.Dir, args.Rel, hasTestdata, er, goFileInfos)
@@ -292,7 +296,7 @@ func (gl *goLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 
 		// Process the other static files.
 		for _, file := range otherFiles {
-			info := otherFileInfo(filepath.Join(args.Dir, file))
+			info := otherFileInfo(c, filepath.Join(args.Dir, file))
 			if err := pkg.addFile(c, er, info, cgo); err != nil {
 				log.Print(err)
 			}
@@ -392,6 +396,8 @@ func (gl *goLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 	}
 	sort.Strings(res.RelsToIndex) // for deterministic output
 
+	gl.goPkgRelsMu.Lock()
+	defer gl.goPkgRelsMu.Unlock()
 	if args.File != nil || len(res.Gen) > 0 {
 		gl.goPkgRels[args.Rel] = true
 	} else {
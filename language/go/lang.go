@@ -53,7 +53,12 @@ limitations under the License.
 // Known Types and Google APIs. rules_go declares canonical rules for these.
 package golang
 
-import "github.com/bazelbuild/bazel-gazelle/language"
+import (
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
 
 const goName = "go"
 
@@ -62,10 +67,23 @@ type goLang struct {
 	// Go code. If the value is false, it means the directory does not contain
 	// buildable Go code, but it has a subdir which does.
 	goPkgRels map[string]bool
+
+	// goPkgRelsMu guards goPkgRels, since GenerateRules may be called
+	// concurrently for sibling directories.
+	goPkgRelsMu sync.RWMutex
 }
 
+var (
+	_ language.ConcurrentLanguage = (*goLang)(nil)
+	_ resolve.ConcurrentResolver  = (*goLang)(nil)
+)
+
 func (*goLang) Name() string { return goName }
 
+func (*goLang) ConcurrentGenerateRules() bool { return true }
+
+func (*goLang) ConcurrentResolve() bool { return true }
+
 func NewLanguage() language.Language {
 	return &goLang{goPkgRels: make(map[string]bool)}
 }
@@ -19,7 +19,6 @@ import (
 	"errors"
 	"fmt"
 	"go/build"
-	"log"
 	"path"
 	"strings"
 
@@ -70,30 +69,49 @@ func (gl *goLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remo
 	}
 	imports := importsRaw.(rule.PlatformStrings)
 	r.DelAttr("deps")
-	var resolve func(*config.Config, *resolve.RuleIndex, *repo.RemoteCache, string, label.Label) (label.Label, error)
+	var resolveImport func(*config.Config, *resolve.RuleIndex, *repo.RemoteCache, string, label.Label) (label.Label, error)
 	switch r.Kind() {
 	case "go_proto_library":
-		resolve = resolveProto
+		resolveImport = resolveProto
 	default:
-		resolve = ResolveGo
+		resolveImport = ResolveGo
+	}
+	impLang := goName
+	if r.Kind() == "go_proto_library" {
+		impLang = "proto"
 	}
 	deps, errs := imports.Map(func(imp string) (string, error) {
-		l, err := resolve(c, ix, rc, imp, from)
+		l, err := resolveImport(c, ix, rc, imp, from)
+		spec := resolve.ImportSpec{Lang: impLang, Imp: imp}
 		if err == errSkipImport {
+			resolve.Explainf(c, spec, goName, "no dependency needed")
 			return "", nil
 		} else if err != nil {
+			resolve.Explainf(c, spec, goName, "failed: %v", err)
 			return "", err
 		}
 		for _, embed := range gl.Embeds(r, from) {
 			if embed.Equal(l) {
+				resolve.Explainf(c, spec, goName, "%s is embedded by %s; no dependency needed", l, from)
 				return "", nil
 			}
 		}
+		resolve.Explainf(c, spec, goName, "resolved to %s", l)
 		l = l.Rel(from.Repo, from.Pkg)
 		return l.String(), nil
 	})
 	for _, err := range errs {
-		log.Print(err)
+		code := "unresolved-import"
+		var aerr *ambiguousImportError
+		if errors.As(err, &aerr) {
+			code = "ambiguous-import"
+		}
+		c.Report(config.Diagnostic{
+			Severity: config.SeverityError,
+			Code:     code,
+			Lang:     goName,
+			Message:  err.Error(),
+		})
 	}
 	if !deps.IsEmpty() {
 		if r.Kind() == "go_proto_library" {
@@ -112,6 +130,18 @@ var (
 	errNotFound   = errors.New("rule not found")
 )
 
+// ambiguousImportError is returned when an import matches several indexed
+// rules, and none of them is preferred.
+type ambiguousImportError struct {
+	from        label.Label
+	imp         string
+	first, next label.Label
+}
+
+func (e *ambiguousImportError) Error() string {
+	return fmt.Sprintf("rule %s imports %q which matches multiple rules: %s and %s. # gazelle:resolve may be used to disambiguate", e.from, e.imp, e.first, e.next)
+}
+
 // ResolveGo resolves a Go import path to a Bazel label, possibly using the
 // given rule index and remote cache. Some special cases may be applied to
 // known proto import paths, depending on the current proto mode.
@@ -127,8 +157,10 @@ func ResolveGo(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, im
 		}
 		imp = path.Join(gc.prefix, cleanRel)
 	}
+	spec := resolve.ImportSpec{Lang: "go", Imp: imp}
 
 	if IsStandard(imp) {
+		resolve.Explainf(c, spec, goName, "%s is in the standard library", imp)
 		return label.NoLabel, errSkipImport
 	}
 
@@ -149,9 +181,11 @@ func ResolveGo(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, im
 	if !c.Bzlmod {
 		if pathtools.HasPrefix(imp, "github.com/bazelbuild/rules_go") {
 			pkg := pathtools.TrimPrefix(imp, "github.com/bazelbuild/rules_go")
+			resolve.Explainf(c, spec, goName, "special case for rules_go")
 			return label.New("io_bazel_rules_go", pkg, "go_default_library"), nil
 		} else if pathtools.HasPrefix(imp, "github.com/bazelbuild/bazel-gazelle") {
 			pkg := pathtools.TrimPrefix(imp, "github.com/bazelbuild/bazel-gazelle")
+			resolve.Explainf(c, spec, goName, "special case for bazel_gazelle")
 			return label.New("bazel_gazelle", pkg, "go_default_library"), nil
 		}
 	}
@@ -162,22 +196,37 @@ func ResolveGo(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, im
 		if pathtools.HasPrefix(imp, gc.prefix) {
 			pkg := path.Join(gc.prefixRel, pathtools.TrimPrefix(imp, gc.prefix))
 			libName := libNameByConvention(gc.goNamingConvention, imp, "")
+			resolve.Explain(c, resolve.ExplainStep{
+				Imp:     spec,
+				Source:  goName,
+				Message: fmt.Sprintf("indexing is disabled and %s has the prefix %q; using the %s naming convention", imp, gc.prefix, gc.goNamingConvention),
+				File:    gc.prefixPos.file,
+				Line:    gc.prefixPos.line,
+			})
 			return label.New("", pkg, libName), nil
 		}
 	}
 
 	if gc.depMode == vendorMode {
+		resolve.Explain(c, resolve.ExplainStep{
+			Imp:     spec,
+			Source:  goName,
+			Message: fmt.Sprintf("external dependencies are vendored (-external=vendored); using the %s naming convention", gc.goNamingConvention),
+			File:    gc.goNamingConventionPos.file,
+			Line:    gc.goNamingConventionPos.line,
+		})
 		return resolveVendored(gc, imp)
 	}
 	var resolveFn func(string) (string, string, error)
+	var resolveFnName string
 	if gc.depMode == staticMode {
-		resolveFn = rc.RootStatic
+		resolveFn, resolveFnName = rc.RootStatic, "RemoteCache.RootStatic"
 	} else if gc.moduleMode || pathWithoutSemver(imp) != "" {
-		resolveFn = rc.Mod
+		resolveFn, resolveFnName = rc.Mod, "RemoteCache.Mod"
 	} else {
-		resolveFn = rc.Root
+		resolveFn, resolveFnName = rc.Root, "RemoteCache.Root"
 	}
-	return resolveToExternalLabel(c, resolveFn, imp)
+	return resolveToExternalLabel(c, resolveFn, resolveFnName, imp)
 }
 
 // IsStandard returns whether a package is in the standard library.
@@ -186,7 +235,8 @@ func IsStandard(imp string) bool {
 }
 
 func resolveWithIndexGo(c *config.Config, ix *resolve.RuleIndex, imp string, from label.Label) (label.Label, error) {
-	matches := ix.FindRulesByImportWithConfig(c, resolve.ImportSpec{Lang: "go", Imp: imp}, "go")
+	spec := resolve.ImportSpec{Lang: "go", Imp: imp}
+	matches := ix.FindRulesByImportWithConfig(c, spec, "go")
 	var bestMatch resolve.FindResult
 	var bestMatchIsVendored bool
 	var bestMatchVendorRoot string
@@ -216,6 +266,7 @@ func resolveWithIndexGo(c *config.Config, ix *resolve.RuleIndex, imp string, fro
 		}
 		if isVendored && !label.New(m.Label.Repo, vendorRoot, "").Contains(from) {
 			// vendor directory not visible
+			resolve.Explainf(c, spec, goName, "%s lost: its vendor directory is not visible from %s", m.Label, from)
 			continue
 		}
 
@@ -230,6 +281,9 @@ func resolveWithIndexGo(c *config.Config, ix *resolve.RuleIndex, imp string, fro
 			(isVendored && (!bestMatchIsVendored || len(vendorRoot) > len(bestMatchVendorRoot))) ||
 			(goRepositoryMode && !bestMatchEmbedsProtos && embedsProtos) {
 			// Current match is better
+			if !bestMatch.Label.Equal(label.NoLabel) {
+				resolve.Explainf(c, spec, goName, "%s won over %s: %s", m.Label, bestMatch.Label, goMatchReason(isVendored, vendorRoot, embedsProtos, bestMatchIsVendored, bestMatchVendorRoot, bestMatchEmbedsProtos, goRepositoryMode))
+			}
 			bestMatch = m
 			bestMatchIsVendored = isVendored
 			bestMatchVendorRoot = vendorRoot
@@ -239,10 +293,11 @@ func resolveWithIndexGo(c *config.Config, ix *resolve.RuleIndex, imp string, fro
 			(isVendored && len(vendorRoot) < len(bestMatchVendorRoot)) ||
 			(goRepositoryMode && bestMatchEmbedsProtos && !embedsProtos) {
 			// Current match is worse
+			resolve.Explainf(c, spec, goName, "%s lost to %s: %s", m.Label, bestMatch.Label, goMatchReason(bestMatchIsVendored, bestMatchVendorRoot, bestMatchEmbedsProtos, isVendored, vendorRoot, embedsProtos, goRepositoryMode))
 		} else {
 			// Match is ambiguous
 			// TODO: consider listing all the ambiguous rules here.
-			matchError = fmt.Errorf("rule %s imports %q which matches multiple rules: %s and %s. # gazelle:resolve may be used to disambiguate", from, imp, bestMatch.Label, m.Label)
+			matchError = &ambiguousImportError{from: from, imp: imp, first: bestMatch.Label, next: m.Label}
 		}
 	}
 	if matchError != nil {
@@ -252,18 +307,38 @@ func resolveWithIndexGo(c *config.Config, ix *resolve.RuleIndex, imp string, fro
 		return label.NoLabel, errNotFound
 	}
 	if bestMatch.IsSelfImport(from) {
+		resolve.Explainf(c, spec, goName, "%s is %s or embeds it", bestMatch.Label, from)
 		return label.NoLabel, errSkipImport
 	}
 	return bestMatch.Label, nil
 }
 
-func resolveToExternalLabel(c *config.Config, resolveFn func(string) (string, string, error), imp string) (label.Label, error) {
+// goMatchReason explains why a winning index match was preferred over a
+// losing one in resolveWithIndexGo.
+func goMatchReason(winVendored bool, winVendorRoot string, winEmbedsProtos, loseVendored bool, loseVendorRoot string, loseEmbedsProtos, goRepositoryMode bool) string {
+	switch {
+	case winVendored && !loseVendored:
+		return "vendored libraries are preferred"
+	case winVendored && loseVendored && len(winVendorRoot) > len(loseVendorRoot):
+		return "libraries in closer vendor directories are preferred"
+	case goRepositoryMode && winEmbedsProtos && !loseEmbedsProtos:
+		return "in external repositories, libraries that embed go_proto_library are preferred"
+	default:
+		return "first match found"
+	}
+}
+
+func resolveToExternalLabel(c *config.Config, resolveFn func(string) (string, string, error), resolveFnName, imp string) (label.Label, error) {
+	spec := resolve.ImportSpec{Lang: "go", Imp: imp}
 	prefix, repo, err := resolveFn(imp)
 	if err != nil {
+		resolve.Explainf(c, spec, "external", "%s failed: %v", resolveFnName, err)
 		return label.NoLabel, err
 	} else if prefix == "" && repo == "" {
+		resolve.Explainf(c, spec, "external", "%s found no repository", resolveFnName)
 		return label.NoLabel, errSkipImport
 	}
+	resolve.Explainf(c, spec, "external", "%s found repository %s with prefix %s", resolveFnName, repo, prefix)
 
 	var pkg string
 	if pathtools.HasPrefix(imp, prefix) {
@@ -283,19 +358,37 @@ func resolveToExternalLabel(c *config.Config, resolveFn func(string) (string, st
 	// user has told us otherwise.
 	gc := getGoConfig(c)
 	nc := gc.repoNamingConvention[repo]
+	var ncReason string
+	var ncPos directivePos
 	if nc == unknownNamingConvention {
 		if gc.goNamingConventionExternal != unknownNamingConvention {
 			nc = gc.goNamingConventionExternal
+			ncReason = "repository is not declared; set by # gazelle:go_naming_convention_external"
+			ncPos = gc.goNamingConventionExternalPos
 		} else {
 			nc = goDefaultLibraryNamingConvention
+			ncReason = "repository is not declared; default"
 		}
 	} else if nc == importAliasNamingConvention {
 		if gc.goNamingConventionExternal != unknownNamingConvention {
 			nc = gc.goNamingConventionExternal
+			ncReason = "repository uses import_alias; set by # gazelle:go_naming_convention_external"
+			ncPos = gc.goNamingConventionExternalPos
 		} else {
 			nc = gc.goNamingConvention
+			ncReason = "repository uses import_alias; same as the current directory"
+			ncPos = gc.goNamingConventionPos
 		}
-	}
+	} else {
+		ncReason = "declared by the repository's build_naming_convention"
+	}
+	resolve.Explain(c, resolve.ExplainStep{
+		Imp:     spec,
+		Source:  "external",
+		Message: fmt.Sprintf("using the %s naming convention (%s)", nc, ncReason),
+		File:    ncPos.file,
+		Line:    ncPos.line,
+	})
 
 	name := libNameByConvention(nc, imp, "")
 	return label.New(repo, pkg, name), nil
@@ -81,8 +81,8 @@ type Language interface {
 	// A GenerateResult struct is returned. Optional fields may be added to this
 	// type in the future.
 	//
-	// Any non-fatal errors this function encounters should be logged using
-	// log.Print.
+	// Any non-fatal errors this function encounters should be reported with
+	// args.Config.Report, so they can be rendered as structured diagnostics.
 	GenerateRules(args GenerateArgs) GenerateResult
 
 	// Loads returns .bzl files and symbols they define. Every rule generated by
@@ -110,6 +110,26 @@ type FinishableLanguage interface {
 	DoneGeneratingRules()
 }
 
+// ConcurrentLanguage may be implemented by a Language whose GenerateRules
+// method is safe to call concurrently for different directories.
+//
+// When concurrent generation is enabled with -generate_jobs, Gazelle may call
+// GenerateRules for sibling directories on a pool of worker goroutines, but
+// only if every language enabled in those directories implements this
+// interface. GenerateRules is still called for a directory after it has
+// returned for all subdirectories, and languages are still called in order
+// within a directory. Results are merged and indexed in the same order as
+// a serial run.
+//
+// Implementations must synchronize access to any state shared between calls.
+// GenerateRules should not call walk.GetDirInfo, since it may finish after
+// the walk has returned.
+type ConcurrentLanguage interface {
+	// ConcurrentGenerateRules returns true if GenerateRules may be called
+	// concurrently for different directories.
+	ConcurrentGenerateRules() bool
+}
+
 type ModuleAwareLanguage interface {
 	// ApparentLoads returns .bzl files and symbols they define. Every rule
 	// generated by GenerateRules, now or in the past, should be loadable from
@@ -214,6 +214,44 @@ func (*protoLang) KnownDirectives() []string {
 	return []string{"proto", "proto_group", "proto_strip_import_prefix", "proto_import_prefix", "proto_search"}
 }
 
+var _ config.DirectiveSchemaProvider = (*protoLang)(nil)
+
+func (*protoLang) DirectiveSchemas() []config.DirectiveSchema {
+	return []config.DirectiveSchema{
+		{
+			Key:    "proto",
+			Usage:  "default|package|legacy|disable|disable_global|file",
+			Doc:    "How proto_library rules are generated, or whether they're generated at all.",
+			Type:   config.ArgEnum,
+			Values: []string{"default", "package", "legacy", "disable", "disable_global", "file"},
+		},
+		{
+			Key:   "proto_group",
+			Usage: "option_name",
+			Doc:   "In package mode, groups .proto files into rules by the value of this option.",
+		},
+		{
+			Key:   "proto_import_prefix",
+			Usage: "path",
+			Doc:   "Sets import_prefix on generated proto_library rules.",
+		},
+		{
+			Key:        "proto_search",
+			Usage:      "strip_import_prefix import_prefix",
+			Doc:        "With lazy indexing, where to find proto_library rules for imports. An empty value clears search paths.",
+			Type:       config.ArgFields,
+			MinArgs:    2,
+			MaxArgs:    2,
+			AllowEmpty: true,
+		},
+		{
+			Key:   "proto_strip_import_prefix",
+			Usage: "path",
+			Doc:   "Sets strip_import_prefix on generated proto_library rules.",
+		},
+	}
+}
+
 func (*protoLang) Configure(c *config.Config, rel string, f *rule.File) {
 	pc := &ProtoConfig{}
 	*pc = *GetProtoConfig(c)
@@ -258,6 +296,23 @@ func (*protoLang) Configure(c *config.Config, rel string, f *rule.File) {
 	inferProtoMode(c, rel, f)
 }
 
+var _ config.Describer = (*protoLang)(nil)
+
+func (*protoLang) DescribeConfig(c *config.Config) []config.Setting {
+	pc := GetProtoConfig(c)
+	settings := []config.Setting{{Directive: "proto", Value: pc.Mode.String(), Flag: "proto"}}
+	if pc.groupOption != "" {
+		settings = append(settings, config.Setting{Directive: "proto_group", Value: pc.groupOption, Flag: "proto_group"})
+	}
+	if pc.StripImportPrefix != "" {
+		settings = append(settings, config.Setting{Directive: "proto_strip_import_prefix", Value: pc.StripImportPrefix})
+	}
+	if pc.ImportPrefix != "" {
+		settings = append(settings, config.Setting{Directive: "proto_import_prefix", Value: pc.ImportPrefix, Flag: "proto_import_prefix"})
+	}
+	return settings
+}
+
 // inferProtoMode sets ProtoConfig.Mode based on the directory name and the
 // contents of f. If the proto mode is set explicitly, this function does not
 // change it. If this is a vendor directory, or go_proto_library is loaded from
@@ -18,12 +18,13 @@ package proto
 import (
 	"bytes"
 	"log"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
 )
 
 // FileInfo contains metadata extracted from a .proto file.
@@ -50,12 +51,19 @@ type Option struct {
 
 var protoRe = buildProtoRegexp()
 
+// ProtoFileInfo extracts metadata from the .proto file name in dir. If the
+// file can't be read, an error is logged, and partial information is returned.
 func ProtoFileInfo(dir, name string) FileInfo {
+	return protoFileInfo(&config.Config{}, dir, name)
+}
+
+// protoFileInfo is like ProtoFileInfo, but it reads the file with c.ReadFile.
+func protoFileInfo(c *config.Config, dir, name string) FileInfo {
 	info := FileInfo{
 		Path: filepath.Join(dir, name),
 		Name: name,
 	}
-	content, err := os.ReadFile(info.Path)
+	content, err := c.ReadFile(info.Path)
 	if err != nil {
 		log.Printf("%s: error reading proto file: %v", info.Path, err)
 		return info
@@ -59,14 +59,26 @@ limitations under the License.
 // @com_google_protobuf.
 package proto
 
-import "github.com/bazelbuild/bazel-gazelle/language"
+import (
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
 
 const protoName = "proto"
 
 type protoLang struct{}
 
+var (
+	_ language.ConcurrentLanguage = (*protoLang)(nil)
+	_ resolve.ConcurrentResolver  = (*protoLang)(nil)
+)
+
 func (*protoLang) Name() string { return protoName }
 
+func (*protoLang) ConcurrentGenerateRules() bool { return true }
+
+func (*protoLang) ConcurrentResolve() bool { return true }
+
 func NewLanguage() language.Language {
 	return &protoLang{}
 }
@@ -18,7 +18,6 @@ package proto
 import (
 	"errors"
 	"fmt"
-	"log"
 	"path"
 	"sort"
 	"strings"
@@ -63,11 +62,25 @@ func (*protoLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remo
 	depSet := make(map[string]bool)
 	for _, imp := range imports {
 		l, err := resolveProto(c, ix, r, imp, from)
+		spec := resolve.ImportSpec{Lang: "proto", Imp: imp}
 		if err == errSkipImport {
+			resolve.Explainf(c, spec, protoName, "no dependency needed")
 			continue
 		} else if err != nil {
-			log.Print(err)
+			resolve.Explainf(c, spec, protoName, "failed: %v", err)
+			code := "unresolved-import"
+			var aerr *ambiguousImportError
+			if errors.As(err, &aerr) {
+				code = "ambiguous-import"
+			}
+			c.Report(config.Diagnostic{
+				Severity: config.SeverityError,
+				Code:     code,
+				Lang:     protoName,
+				Message:  err.Error(),
+			})
 		} else {
+			resolve.Explainf(c, spec, protoName, "resolved to %s", l)
 			l = l.Rel(from.Repo, from.Pkg)
 			depSet[l.String()] = true
 		}
@@ -136,7 +149,7 @@ func resolveWithIndex(c *config.Config, ix *resolve.RuleIndex, imp string, from
 		return label.NoLabel, errNotFound
 	}
 	if len(matches) > 1 {
-		return label.NoLabel, fmt.Errorf("multiple rules (%s and %s) may be imported with %q from %s", matches[0].Label, matches[1].Label, imp, from)
+		return label.NoLabel, &ambiguousImportError{from: from, imp: imp, first: matches[0].Label, next: matches[1].Label}
 	}
 	if matches[0].IsSelfImport(from) {
 		return label.NoLabel, errSkipImport
@@ -197,3 +210,15 @@ func transformImport(libRel, protoName, stripImportPrefix, importPrefix string)
 	cleanRel = path.Join(importPrefix, cleanRel)
 	return cleanRel, true
 }
+
+// ambiguousImportError is returned when an import matches several indexed
+// rules.
+type ambiguousImportError struct {
+	from        label.Label
+	imp         string
+	first, next label.Label
+}
+
+func (e *ambiguousImportError) Error() string {
+	return fmt.Sprintf("multiple rules (%s and %s) may be imported with %q from %s", e.first, e.next, e.imp, e.from)
+}
@@ -42,6 +42,7 @@ import (
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
 )
 
 // Phase indicates which attributes should be merged in matching rules.
@@ -84,7 +85,9 @@ const UnstableInsertIndexKey = "_gazelle_insert_index"
 // genRules is a list of newly generated rules. These are merged with
 // matching rules. A rule matches if it has the same kind and name or if
 // some other attribute in rule.KindInfo.MatchAttrs matches (e.g.,
-// "importpath" in go_library). Elements of genRules that don't match
+// "importpath" in go_library). For kinds with rule.KindInfo.MatchSrcsOverlap
+// set, a rule that doesn't match otherwise may match the existing rule whose
+// "srcs" overlap the most. Elements of genRules that don't match
 // any existing rule are appended to the end of oldFile.
 //
 // phase indicates whether this is a pre- or post-resolve merge. Different
@@ -107,6 +110,89 @@ const UnstableInsertIndexKey = "_gazelle_insert_index"
 // If a rule is marked with a "# keep" comment, the whole rule will not
 // be modified.
 func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo, aliasedKinds map[string]string) {
+	mergeFile(oldFile, emptyRules, genRules, phase, kinds, aliasedKinds, nil, false)
+}
+
+// Conflict describes an attribute that was changed both by hand and by
+// Gazelle. See MergeFileWithBase and MergeFileReportingOverwrites.
+type Conflict struct {
+	// Rule is the existing rule.
+	Rule *rule.Rule
+
+	// Attr is the name of the attribute.
+	Attr string
+
+	// Line is the line where the attribute's value started in the existing
+	// file before merging, or 0 if it's not known.
+	Line int
+
+	// Overwritten is true if the value in the existing rule was replaced
+	// with the generated value. Otherwise, the attribute was not changed.
+	Overwritten bool
+}
+
+// MergeFileWithBase is like MergeFile, but it does a three-way merge of
+// rules that have a base: the rule generated for them in an earlier run,
+// recorded in a Snapshot. base maps names of generated and empty rules to
+// their base rules. Changes made to existing rules by hand are kept, and
+// only changes between the base and the newly generated rule are applied.
+// See rule.MergeRulesWithBaseAndStrategies for details. Rules without a
+// base are merged as MergeFile merges them.
+//
+// MergeFileWithBase returns attributes that couldn't be merged because both
+// the generated and the existing rule changed them.
+func MergeFileWithBase(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo, aliasedKinds map[string]string, base map[string]*rule.Rule) []Conflict {
+	return mergeFile(oldFile, emptyRules, genRules, phase, kinds, aliasedKinds, base, false)
+}
+
+// MergeFileReportingOverwrites is like MergeFileWithBase, but it also
+// returns attributes of rules without a base whose values were overwritten,
+// possibly discarding changes made by hand. These have Overwritten set.
+// See rule.MergeRulesReportingOverwrites for details. base may be nil.
+func MergeFileReportingOverwrites(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo, aliasedKinds map[string]string, base map[string]*rule.Rule) []Conflict {
+	return mergeFile(oldFile, emptyRules, genRules, phase, kinds, aliasedKinds, base, true)
+}
+
+func mergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo, aliasedKinds map[string]string, base map[string]*rule.Rule, reportOverwrites bool) []Conflict {
+	var conflicts []Conflict
+	mergeRules := func(src, dst *rule.Rule, mergeable map[string]bool) {
+		strategies := kinds[src.Kind()].MergeStrategies
+		b := base[src.Name()]
+		if b != nil && b.Kind() != src.Kind() {
+			b = nil
+		}
+		if b == nil && reportOverwrites {
+			// Strategies decide which values in dst are kept, so attributes
+			// with strategies are merged first and aren't reported.
+			if len(strategies) > 0 {
+				withStrategy := make(map[string]bool)
+				rest := make(map[string]bool)
+				for key := range mergeable {
+					if _, ok := strategies[key]; ok {
+						withStrategy[key] = true
+					} else {
+						rest[key] = true
+					}
+				}
+				rule.MergeRulesWithStrategies(src, dst, withStrategy, strategies, oldFile.Path)
+				mergeable = rest
+			}
+			// Values are replaced, so find their lines before merging.
+			lines := make(map[string]int)
+			for _, key := range dst.AttrKeys() {
+				if mergeable[key] {
+					lines[key] = attrLine(dst, key)
+				}
+			}
+			for _, attr := range rule.MergeRulesReportingOverwrites(src, dst, mergeable, oldFile.Path) {
+				conflicts = append(conflicts, Conflict{Rule: dst, Attr: attr, Line: lines[attr], Overwritten: true})
+			}
+			return
+		}
+		for _, attr := range rule.MergeRulesWithBaseAndStrategies(b, src, dst, mergeable, strategies, oldFile.Path) {
+			conflicts = append(conflicts, Conflict{Rule: dst, Attr: attr, Line: attrLine(dst, attr)})
+		}
+	}
 	getMergeAttrs := func(r *rule.Rule) map[string]bool {
 		if phase == PreResolve {
 			return kinds[r.Kind()].MergeableAttrs
@@ -121,7 +207,7 @@ func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phas
 			if oldRule.ShouldKeep() {
 				continue
 			}
-			rule.MergeRules(emptyRule, oldRule, getMergeAttrs(emptyRule), oldFile.Path)
+			mergeRules(emptyRule, oldRule, getMergeAttrs(emptyRule))
 			if oldRule.IsEmpty(kinds[oldRule.Kind()]) {
 				oldRule.Delete()
 			}
@@ -143,10 +229,12 @@ func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phas
 			continue
 		}
 		matchRules[i] = oldRule
-		if oldRule != nil {
-			if oldRule.Name() != genRule.Name() {
-				substitutions[genRule.Name()] = oldRule.Name()
-			}
+	}
+
+	matchBySrcsOverlap(oldFile.Rules, genRules, matchRules, matchErrors, kinds, aliasedKinds)
+	for i, genRule := range genRules {
+		if oldRule := matchRules[i]; oldRule != nil && oldRule.Name() != genRule.Name() {
+			substitutions[genRule.Name()] = oldRule.Name()
 		}
 	}
 
@@ -169,9 +257,28 @@ func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phas
 				genRule.Insert(oldFile)
 			}
 		} else {
-			rule.MergeRules(genRule, matchRules[i], getMergeAttrs(genRule), oldFile.Path)
+			mergeRules(genRule, matchRules[i], getMergeAttrs(genRule))
 		}
 	}
+	return conflicts
+}
+
+// attrLine returns the line where the value of the attribute key of r
+// starts, or 0 if r doesn't have the attribute or it has no position.
+// Fixes may rebuild a list around existing strings, so if the value has no
+// position, the line of the first part of it that has one is returned.
+func attrLine(r *rule.Rule, key string) int {
+	e := r.Attr(key)
+	if e == nil {
+		return 0
+	}
+	line := 0
+	bzl.Walk(e, func(x bzl.Expr, _ []bzl.Expr) {
+		if start, _ := x.Span(); line == 0 && start.Line > 0 {
+			line = start.Line
+		}
+	})
+	return line
 }
 
 // substituteRule replaces local labels (those beginning with ":", referring to
@@ -278,6 +385,85 @@ func match(rules []*rule.Rule, x *rule.Rule, info rule.KindInfo, wantError bool,
 	return nil, nil
 }
 
+// matchBySrcsOverlap matches generated rules that didn't match an existing
+// rule with existing rules of the same kind whose sources overlap, for kinds
+// with rule.KindInfo.MatchSrcsOverlap set. Existing rules that matched
+// another generated rule or that have the name of one are not considered.
+// Each generated rule is matched with the existing rule with the greatest
+// overlap, if it's at least the threshold and no other rule has the same
+// overlap. matchRules is updated in place.
+func matchBySrcsOverlap(rules, genRules, matchRules []*rule.Rule, matchErrors []error, kinds map[string]rule.KindInfo, aliasedKinds map[string]string) {
+	taken := make(map[*rule.Rule]bool)
+	genNames := make(map[string]bool)
+	for i, genRule := range genRules {
+		genNames[genRule.Name()] = true
+		if matchRules[i] != nil {
+			taken[matchRules[i]] = true
+		}
+	}
+	for i, genRule := range genRules {
+		threshold := kinds[genRule.Kind()].MatchSrcsOverlap
+		if matchRules[i] != nil || matchErrors[i] != nil || threshold <= 0 {
+			continue
+		}
+		genSrcs := srcsSet(genRule)
+		if len(genSrcs) == 0 {
+			continue
+		}
+		var best *rule.Rule
+		bestOverlap, ambiguous := 0.0, false
+		for _, r := range rules {
+			if taken[r] || genNames[r.Name()] || (r.Kind() != genRule.Kind() && aliasedKinds[r.Kind()] != genRule.Kind()) {
+				continue
+			}
+			o := overlap(genSrcs, srcsSet(r))
+			if o < threshold || o < bestOverlap {
+				continue
+			}
+			ambiguous = o == bestOverlap
+			best, bestOverlap = r, o
+		}
+		if best != nil && !ambiguous {
+			matchRules[i] = best
+			taken[best] = true
+		}
+	}
+}
+
+// srcsSet returns the set of strings in the "srcs" attribute of r,
+// including strings in select expressions.
+func srcsSet(r *rule.Rule) map[string]bool {
+	srcs := make(map[string]bool)
+	e := r.Attr("srcs")
+	if e == nil {
+		return srcs
+	}
+	if l, ok := rule.FlattenExpr(e).(*bzl.ListExpr); ok {
+		for _, v := range l.List {
+			if s, ok := v.(*bzl.StringExpr); ok {
+				srcs[s.Value] = true
+			}
+		}
+	}
+	return srcs
+}
+
+// overlap returns the number of strings in both x and y divided by the
+// number of strings in either.
+func overlap(x, y map[string]bool) float64 {
+	common := 0
+	for s := range x {
+		if y[s] {
+			common++
+		}
+	}
+	union := len(x) + len(y) - common
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
 func attrMatch(x, y *rule.Rule, key string) bool {
 	xValue := x.AttrString(key)
 	if xValue != "" && xValue == y.AttrString(key) {
@@ -1010,33 +1010,337 @@ package(default_visibility = ["//visibility:public"])
 func TestMergeFile(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			genFile, err := rule.LoadData(filepath.Join("current", "BUILD.bazel"), "", []byte(tc.current))
-			if err != nil {
-				t.Fatalf("%s: %v", tc.desc, err)
-			}
-			f, err := rule.LoadData(filepath.Join("previous", "BUILD.bazel"), "", []byte(tc.previous))
-			if err != nil {
-				t.Fatalf("%s: %v", tc.desc, err)
-			}
-			emptyFile, err := rule.LoadData(filepath.Join("empty", "BUILD.bazel"), "", []byte(tc.empty))
-			if err != nil {
-				t.Fatalf("%s: %v", tc.desc, err)
-			}
-			merger.MergeFile(f, emptyFile.Rules, genFile.Rules, merger.PreResolve, testKinds, tc.aliasedKinds)
-			merger.FixLoads(f, testLoads)
+			testMergeFile(t, tc, testKinds)
+		})
+	}
+}
 
-			want := tc.expected
-			if len(want) > 0 && want[0] == '\n' {
-				want = want[1:]
-			}
+// srcsOverlapTestCases are merged with kinds that match rules by srcs
+// overlap, which no language enables by default.
+var srcsOverlapTestCases = []testCase{
+	{
+		desc: "renamed package matched by srcs",
+		previous: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
 
-			if got := string(f.Format()); got != want {
-				t.Fatalf("%s: got %s; want %s", tc.desc, got, want)
-			}
+go_library(
+    name = "old",
+    srcs = [
+        "a.go",
+        "b.go",
+    ],
+    importpath = "example.com/old",
+    tags = ["manual"],
+)
+
+go_test(
+    name = "old_test",
+    srcs = ["a_test.go"],
+    embed = [":old"],
+    size = "small",
+)
+`,
+		current: `
+go_library(
+    name = "new",
+    srcs = [
+        "a.go",
+        "b.go",
+        "c.go",
+    ],
+    importpath = "example.com/new",
+)
+
+go_test(
+    name = "new_test",
+    srcs = ["a_test.go"],
+    embed = [":new"],
+)
+`,
+		expected: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "old",
+    srcs = [
+        "a.go",
+        "b.go",
+        "c.go",
+    ],
+    importpath = "example.com/new",
+    tags = ["manual"],
+)
+
+go_test(
+    name = "old_test",
+    size = "small",
+    srcs = ["a_test.go"],
+    embed = [":old"],
+)
+`,
+	},
+	{
+		desc: "srcs overlap below threshold",
+		previous: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "old",
+    srcs = [
+        "a.go",
+        "b.go",
+    ],
+    importpath = "example.com/old",
+)
+`,
+		current: `
+go_library(
+    name = "new",
+    srcs = [
+        "a.go",
+        "c.go",
+        "d.go",
+    ],
+    importpath = "example.com/new",
+)
+`,
+		expected: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "old",
+    srcs = [
+        "a.go",
+        "b.go",
+    ],
+    importpath = "example.com/old",
+)
+
+go_library(
+    name = "new",
+    srcs = [
+        "a.go",
+        "c.go",
+        "d.go",
+    ],
+    importpath = "example.com/new",
+)
+`,
+	},
+	{
+		desc: "srcs overlap ignores rules matched by name",
+		previous: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/a",
+)
+`,
+		current: `
+go_library(
+    name = "a",
+    srcs = ["b.go"],
+    importpath = "example.com/a",
+)
+
+go_library(
+    name = "c",
+    srcs = ["a.go"],
+    importpath = "example.com/c",
+)
+`,
+		expected: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["b.go"],
+    importpath = "example.com/a",
+)
+
+go_library(
+    name = "c",
+    srcs = ["a.go"],
+    importpath = "example.com/c",
+)
+`,
+	},
+}
+
+func TestMergeFileSrcsOverlap(t *testing.T) {
+	kinds := make(map[string]rule.KindInfo, len(testKinds))
+	for kind, info := range testKinds {
+		kinds[kind] = info
+	}
+	goLibrary := kinds["go_library"]
+	goLibrary.MatchSrcsOverlap = 0.5
+	kinds["go_library"] = goLibrary
+	goTest := kinds["go_test"]
+	goTest.MatchSrcsOverlap = 0.5
+	goTest.SubstituteAttrs = map[string]bool{"embed": true}
+	kinds["go_test"] = goTest
+
+	for _, tc := range srcsOverlapTestCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			testMergeFile(t, tc, kinds)
 		})
 	}
 }
 
+func testMergeFile(t *testing.T, tc testCase, kinds map[string]rule.KindInfo) {
+	genFile, err := rule.LoadData(filepath.Join("current", "BUILD.bazel"), "", []byte(tc.current))
+	if err != nil {
+		t.Fatalf("%s: %v", tc.desc, err)
+	}
+	f, err := rule.LoadData(filepath.Join("previous", "BUILD.bazel"), "", []byte(tc.previous))
+	if err != nil {
+		t.Fatalf("%s: %v", tc.desc, err)
+	}
+	emptyFile, err := rule.LoadData(filepath.Join("empty", "BUILD.bazel"), "", []byte(tc.empty))
+	if err != nil {
+		t.Fatalf("%s: %v", tc.desc, err)
+	}
+	merger.MergeFile(f, emptyFile.Rules, genFile.Rules, merger.PreResolve, kinds, tc.aliasedKinds)
+	merger.FixLoads(f, testLoads)
+
+	want := tc.expected
+	if len(want) > 0 && want[0] == '\n' {
+		want = want[1:]
+	}
+
+	if got := string(f.Format()); got != want {
+		t.Fatalf("%s: got %s; want %s", tc.desc, got, want)
+	}
+}
+
+func TestMergeFileStrategies(t *testing.T) {
+	kinds := map[string]rule.KindInfo{
+		"go_library": {
+			MatchAttrs:     []string{"importpath"},
+			MergeableAttrs: map[string]bool{"srcs": true, "importpath": true, "x_defs": true, "tags": true},
+			MergeStrategies: map[string]rule.MergeStrategy{
+				"importpath": {Mode: rule.MergeKeepExisting},
+				"x_defs":     {Func: rule.MergeStringDict},
+				"tags":       {Mode: rule.MergeUnion},
+			},
+		},
+	}
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(`
+go_library(
+    name = "lib",
+    srcs = ["old.go"],
+    importpath = "example.com/custom",
+    tags = ["manual"],
+    x_defs = {"Extra": "x"},
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := rule.LoadData("gen/BUILD.bazel", "", []byte(`
+go_library(
+    name = "lib",
+    srcs = ["new.go"],
+    importpath = "example.com/lib",
+    tags = ["generated"],
+    x_defs = {"Version": "1"},
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merger.MergeFile(f, nil, gen.Rules, merger.PreResolve, kinds, nil)
+	want := `go_library(
+    name = "lib",
+    srcs = ["new.go"],
+    importpath = "example.com/custom",
+    tags = [
+        "generated",
+        "manual",
+    ],
+    x_defs = {
+        "Extra": "x",
+        "Version": "1",
+    },
+)
+`
+	if got := string(f.Format()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeFileWithBaseStrategies(t *testing.T) {
+	kinds := map[string]rule.KindInfo{
+		"go_library": {
+			MatchAttrs:     []string{"importpath"},
+			MergeableAttrs: map[string]bool{"srcs": true, "deps": true},
+			MergeStrategies: map[string]rule.MergeStrategy{
+				"deps": {Mode: rule.MergeUnion},
+			},
+		},
+	}
+	load := func(path, content string) *rule.File {
+		t.Helper()
+		f, err := rule.LoadData(path, "", []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	base := load("base/BUILD.bazel", `
+go_library(
+    name = "lib",
+    srcs = ["a.go"],
+    visibility = ["//visibility:public"],
+    deps = ["//a"],
+)
+`)
+	// visibility was removed by hand, and //manual was added to deps.
+	f := load("BUILD.bazel", `
+go_library(
+    name = "lib",
+    srcs = ["a.go"],
+    deps = [
+        "//a",
+        "//manual",
+    ],
+)
+`)
+	gen := load("gen/BUILD.bazel", `
+go_library(
+    name = "lib",
+    srcs = [
+        "a.go",
+        "b.go",
+    ],
+    visibility = ["//visibility:public"],
+    deps = ["//b"],
+)
+`)
+	conflicts := merger.MergeFileWithBase(f, nil, gen.Rules, merger.PreResolve, kinds, nil, map[string]*rule.Rule{"lib": base.Rules[0]})
+	if len(conflicts) > 0 {
+		t.Errorf("got conflicts %v; want none", conflicts)
+	}
+	want := `go_library(
+    name = "lib",
+    srcs = [
+        "a.go",
+        "b.go",
+    ],
+    deps = [
+        "//a",
+        "//b",
+        "//manual",
+    ],
+)
+`
+	if got := string(f.Format()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
 var (
 	testKinds map[string]rule.KindInfo
 	testLoads []rule.LoadInfo
@@ -0,0 +1,181 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// snapshotVersion should be incremented whenever the format of the snapshot
+// file changes. Snapshots with a different version are ignored.
+const snapshotVersion = 1
+
+// Snapshot records the values of mergeable attributes of the rules Gazelle
+// generated in each package, so that the next run can use them as the base
+// of a three-way merge with MergeFileWithBase.
+//
+// Packages are identified by slash-separated paths relative to the
+// repository root, and rules by their generated names. Values are stored as
+// formatted Starlark expressions.
+//
+// Methods may be called on a nil Snapshot, which records nothing. A Snapshot
+// may be used concurrently.
+type Snapshot struct {
+	mu   sync.Mutex
+	pkgs map[string]map[string]snapshotRule
+}
+
+type snapshotFile struct {
+	Version  int                                `json:"version"`
+	Packages map[string]map[string]snapshotRule `json:"packages"`
+}
+
+type snapshotRule struct {
+	Kind  string            `json:"kind"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// LoadSnapshot reads a snapshot from path. If the file doesn't exist or was
+// written by a different version of Gazelle, an empty snapshot is returned,
+// and rules are merged without a base until they're recorded.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	s := &Snapshot{pkgs: make(map[string]map[string]snapshotRule)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f snapshotFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Version == snapshotVersion && f.Packages != nil {
+		s.pkgs = f.Packages
+	}
+	return s, nil
+}
+
+// Save writes the snapshot to path.
+func (s *Snapshot) Save(path string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	data, err := json.MarshalIndent(snapshotFile{
+		Version:  snapshotVersion,
+		Packages: s.pkgs,
+	}, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return err
+	}
+
+	// Write to a temporary file in the same directory and rename it, so the
+	// snapshot is never left partially written. The snapshot is usually
+	// checked in, so it gets ordinary file permissions, not CreateTemp's.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Base returns the rules recorded for the package rel, indexed by name, for
+// use with MergeFileWithBase. It returns nil if nothing was recorded for
+// the package. Rules with values that can't be parsed are left out.
+func (s *Snapshot) Base(rel string) map[string]*rule.Rule {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	recs := s.pkgs[rel]
+	s.mu.Unlock()
+	if len(recs) == 0 {
+		return nil
+	}
+	base := make(map[string]*rule.Rule, len(recs))
+recs:
+	for name, rec := range recs {
+		r := rule.NewRule(rec.Kind, name)
+		for key, value := range rec.Attrs {
+			f, err := bzl.ParseDefault("", []byte(value))
+			if err != nil || len(f.Stmt) != 1 {
+				continue recs
+			}
+			r.SetAttr(key, f.Stmt[0])
+		}
+		base[name] = r
+	}
+	return base
+}
+
+// Record replaces the rules recorded for the package rel with rules, the
+// rules generated for it after dependencies were resolved. The values of
+// attributes that are mergeable before or after resolution, according to
+// kinds, are recorded.
+func (s *Snapshot) Record(rel string, rules []*rule.Rule, kinds map[string]rule.KindInfo) {
+	if s == nil {
+		return
+	}
+	recs := make(map[string]snapshotRule, len(rules))
+	for _, r := range rules {
+		info := kinds[r.Kind()]
+		rec := snapshotRule{Kind: r.Kind(), Attrs: make(map[string]string)}
+		for _, key := range r.AttrKeys() {
+			if !info.MergeableAttrs[key] && !info.ResolveAttrs[key] {
+				continue
+			}
+			rec.Attrs[key] = bzl.FormatString(r.Attr(key))
+		}
+		recs[r.Name()] = rec
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(recs) == 0 {
+		delete(s.pkgs, rel)
+	} else {
+		s.pkgs[rel] = recs
+	}
+}
@@ -17,8 +17,9 @@ package resolve
 
 import (
 	"flag"
-	"log"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
@@ -32,16 +33,31 @@ import (
 // returned first. If no override is found, label.NoLabel is returned.
 func FindRuleWithOverride(c *config.Config, imp ImportSpec, lang string) (label.Label, bool) {
 	rc := getResolveConfig(c)
-	if dep, ok := rc.findOverride(imp, lang); ok {
-		return dep, true
+	if o, ok := rc.findOverride(imp, lang); ok {
+		Explain(c, ExplainStep{
+			Imp:     imp,
+			Source:  "override",
+			Message: fmt.Sprintf("# gazelle:resolve matched; resolved to %s", o.dep),
+			File:    o.file,
+			Line:    o.line,
+		})
+		return o.dep, true
 	}
 	for i := len(rc.regexpOverrides) - 1; i >= 0; i-- {
 		o := rc.regexpOverrides[i]
 		if o.matches(imp, lang) {
 			dep := o.resolveRegexpDep(imp)
+			Explain(c, ExplainStep{
+				Imp:     imp,
+				Source:  "override",
+				Message: fmt.Sprintf("# gazelle:resolve_regexp %s matched; resolved to %s", o.ImpRegex, dep),
+				File:    o.file,
+				Line:    o.line,
+			})
 			return dep, true
 		}
 	}
+	Explainf(c, imp, "override", "no # gazelle:resolve or # gazelle:resolve_regexp directive matched")
 	return label.NoLabel, false
 }
 
@@ -50,11 +66,21 @@ type overrideKey struct {
 	lang string
 }
 
+// overrideSpec is the label a # gazelle:resolve directive resolves to and
+// where the directive was written.
+type overrideSpec struct {
+	dep  label.Label
+	file string
+	line int
+}
+
 type regexpOverrideSpec struct {
 	ImpLang  string
 	ImpRegex *regexp.Regexp
 	lang     string
 	dep      label.Label
+	file     string
+	line     int
 }
 
 func (o regexpOverrideSpec) matches(imp ImportSpec, lang string) bool {
@@ -80,7 +106,7 @@ func (o regexpOverrideSpec) resolveRegexpDep(imp ImportSpec) label.Label {
 }
 
 type resolveConfig struct {
-	overrides       map[overrideKey]label.Label
+	overrides       map[overrideKey]overrideSpec
 	regexpOverrides []regexpOverrideSpec
 	parent          *resolveConfig
 }
@@ -88,7 +114,7 @@ type resolveConfig struct {
 // newResolveConfig creates a new resolveConfig with the given overrides and
 // regexpOverrides. If the new overrides are the same as the parent's, the
 // parent is returned instead.
-func newResolveConfig(parent *resolveConfig, newOverrides map[overrideKey]label.Label, regexpOverrides []regexpOverrideSpec) *resolveConfig {
+func newResolveConfig(parent *resolveConfig, newOverrides map[overrideKey]overrideSpec, regexpOverrides []regexpOverrideSpec) *resolveConfig {
 	if len(newOverrides) == 0 && len(regexpOverrides) == len(parent.regexpOverrides) {
 		return parent
 	}
@@ -102,15 +128,15 @@ func newResolveConfig(parent *resolveConfig, newOverrides map[overrideKey]label.
 // findOverride searches the current configuration for an override matching
 // the given import and language. If no override is found, the parent
 // configuration is searched recursively.
-func (rc *resolveConfig) findOverride(imp ImportSpec, lang string) (label.Label, bool) {
+func (rc *resolveConfig) findOverride(imp ImportSpec, lang string) (overrideSpec, bool) {
 	key := overrideKey{imp: imp, lang: lang}
-	if dep, ok := rc.overrides[key]; ok {
-		return dep, ok
+	if o, ok := rc.overrides[key]; ok {
+		return o, ok
 	}
 	if rc.parent != nil {
 		return rc.parent.findOverride(imp, lang)
 	}
-	return label.NoLabel, false
+	return overrideSpec{}, false
 }
 
 const resolveName = "_resolve"
@@ -133,13 +159,102 @@ func (*Configurer) KnownDirectives() []string {
 	return []string{"resolve", "resolve_regexp"}
 }
 
+var _ config.DirectiveSchemaProvider = (*Configurer)(nil)
+
+func (*Configurer) DirectiveSchemas() []config.DirectiveSchema {
+	return []config.DirectiveSchema{
+		{
+			Key:     "resolve",
+			Usage:   "source-lang [import-lang] import-string label",
+			Doc:     "Resolves import-string to label, overriding dependency resolution.",
+			Type:    config.ArgFields,
+			MinArgs: 3,
+			MaxArgs: 4,
+			Check:   checkOverrideLabel,
+		},
+		{
+			Key:     "resolve_regexp",
+			Usage:   "source-lang [import-lang] import-string-regexp label",
+			Doc:     "Resolves imports matching import-string-regexp to label, which may refer to subexpressions like $1.",
+			Type:    config.ArgFields,
+			MinArgs: 3,
+			MaxArgs: 4,
+			Check: func(value string) error {
+				fields := strings.Fields(value)
+				if _, err := regexp.Compile(fields[len(fields)-2]); err != nil {
+					return err
+				}
+				return checkOverrideLabel(value)
+			},
+		},
+	}
+}
+
+// checkOverrideLabel checks that the last field of a resolve directive is a
+// label.
+func checkOverrideLabel(value string) error {
+	fields := strings.Fields(value)
+	_, err := label.Parse(fields[len(fields)-1])
+	return err
+}
+
+var _ config.Describer = (*Configurer)(nil)
+
+func (*Configurer) DescribeConfig(c *config.Config) []config.Setting {
+	rc := getResolveConfig(c)
+
+	// Collect overrides from the root down, so overrides in deeper
+	// directories replace those in their parents.
+	var chain []*resolveConfig
+	for r := rc; r != nil; r = r.parent {
+		chain = append(chain, r)
+	}
+	overrides := make(map[overrideKey]overrideSpec)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, o := range chain[i].overrides {
+			overrides[k] = o
+		}
+	}
+	keys := make([]overrideKey, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].imp.Lang != keys[j].imp.Lang {
+			return keys[i].imp.Lang < keys[j].imp.Lang
+		}
+		if keys[i].lang != keys[j].lang {
+			return keys[i].lang < keys[j].lang
+		}
+		return keys[i].imp.Imp < keys[j].imp.Imp
+	})
+
+	var settings []config.Setting
+	for _, k := range keys {
+		o := overrides[k]
+		value := fmt.Sprintf("%s %s %s", k.imp.Lang, k.imp.Imp, o.dep)
+		if k.lang != k.imp.Lang {
+			value = fmt.Sprintf("%s %s %s %s", k.imp.Lang, k.lang, k.imp.Imp, o.dep)
+		}
+		settings = append(settings, config.Setting{Directive: "resolve", Value: value, File: o.file, Line: o.line})
+	}
+	for _, o := range rc.regexpOverrides {
+		value := fmt.Sprintf("%s %s %s", o.ImpLang, o.ImpRegex, o.dep)
+		if o.lang != "" {
+			value = fmt.Sprintf("%s %s %s %s", o.ImpLang, o.lang, o.ImpRegex, o.dep)
+		}
+		settings = append(settings, config.Setting{Directive: "resolve_regexp", Value: value, File: o.file, Line: o.line})
+	}
+	return settings
+}
+
 func (*Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 	if f == nil || len(f.Directives) == 0 {
 		return
 	}
 
 	rc := getResolveConfig(c)
-	var newOverrides map[overrideKey]label.Label
+	var newOverrides map[overrideKey]overrideSpec
 	regexpOverrides := rc.regexpOverrides[:len(rc.regexpOverrides):len(rc.regexpOverrides)]
 
 	for _, d := range f.Directives {
@@ -158,29 +273,31 @@ func (*Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 				key.imp.Imp = parts[2]
 				lbl = parts[3]
 			} else {
-				log.Printf("could not parse directive: %s\n\texpected gazelle:resolve source-language [import-language] import-string label", d.Value)
+				c.ReportDirectivef(config.SeverityWarning, "resolve-args", f, d, "could not parse directive: %s\n\texpected gazelle:resolve source-language [import-language] import-string label", d.Value)
 				continue
 			}
 			dep, err := label.Parse(lbl)
 			if err != nil {
-				log.Printf("gazelle:resolve %s: %v", d.Value, err)
+				c.ReportDirectivef(config.SeverityWarning, "resolve-label", f, d, "gazelle:resolve %s: %v", d.Value, err)
 				continue
 			}
 			dep = dep.Abs("", rel)
 			if newOverrides == nil {
-				newOverrides = make(map[overrideKey]label.Label, len(f.Directives))
+				newOverrides = make(map[overrideKey]overrideSpec, len(f.Directives))
 			}
-			newOverrides[key] = dep
+			file, line := c.DirectivePosition(f, d)
+			newOverrides[key] = overrideSpec{dep: dep, file: file, line: line}
 		} else if d.Key == "resolve_regexp" {
 			parts := strings.Fields(d.Value)
-			o := regexpOverrideSpec{}
+			file, line := c.DirectivePosition(f, d)
+			o := regexpOverrideSpec{file: file, line: line}
 			var lbl string
 			if len(parts) == 3 {
 				o.ImpLang = parts[0]
 				var err error
 				o.ImpRegex, err = regexp.Compile(parts[1])
 				if err != nil {
-					log.Printf("gazelle:resolve_regexp %s: %v", d.Value, err)
+					c.ReportDirectivef(config.SeverityWarning, "resolve-regexp", f, d, "gazelle:resolve_regexp %s: %v", d.Value, err)
 					continue
 				}
 				lbl = parts[2]
@@ -190,19 +307,19 @@ func (*Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 				var err error
 				o.ImpRegex, err = regexp.Compile(parts[2])
 				if err != nil {
-					log.Printf("gazelle:resolve_regexp %s: %v", d.Value, err)
+					c.ReportDirectivef(config.SeverityWarning, "resolve-regexp", f, d, "gazelle:resolve_regexp %s: %v", d.Value, err)
 					continue
 				}
 
 				lbl = parts[3]
 			} else {
-				log.Printf("could not parse directive: %s\n\texpected gazelle:resolve_regexp source-language [import-language] import-string-regex label", d.Value)
+				c.ReportDirectivef(config.SeverityWarning, "resolve-regexp-args", f, d, "could not parse directive: %s\n\texpected gazelle:resolve_regexp source-language [import-language] import-string-regex label", d.Value)
 				continue
 			}
 			var err error
 			o.dep, err = label.Parse(lbl)
 			if err != nil {
-				log.Printf("gazelle:resolve_regexp %s: %v", d.Value, err)
+				c.ReportDirectivef(config.SeverityWarning, "resolve-label", f, d, "gazelle:resolve_regexp %s: %v", d.Value, err)
 				continue
 			}
 			o.dep = o.dep.Abs("", rel)
@@ -0,0 +1,108 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolve
+
+import (
+	"fmt"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+)
+
+// ExplainStep describes one decision made while resolving an import.
+type ExplainStep struct {
+	// Imp is the import being resolved.
+	Imp ImportSpec
+
+	// Source names the component that made the decision, for example,
+	// "override", "index", "cross-resolver", or the name of a language.
+	Source string
+
+	// Message describes the decision.
+	Message string
+
+	// File and Line identify the directive that contributed to the decision.
+	// File is a slash-separated path relative to the repository root. File is
+	// empty and Line is 0 if no directive was involved.
+	File string
+	Line int
+}
+
+func (s ExplainStep) String() string {
+	switch {
+	case s.File != "" && s.Line > 0:
+		return fmt.Sprintf("%s: %s (%s:%d)", s.Source, s.Message, s.File, s.Line)
+	case s.File != "":
+		return fmt.Sprintf("%s: %s (%s)", s.Source, s.Message, s.File)
+	default:
+		return fmt.Sprintf("%s: %s", s.Source, s.Message)
+	}
+}
+
+// Explainer records the steps taken to resolve imports, so users can see
+// why a dependency was chosen. Gazelle installs an Explainer with
+// SetExplainer when run with -explain.
+//
+// Resolvers report steps with Explainf or Explain. Steps for the same rule
+// are reported in order, but Explain may be called concurrently for rules in
+// different packages.
+type Explainer interface {
+	// Explaining returns whether steps for the import string imp should be
+	// recorded.
+	Explaining(imp string) bool
+
+	// Explain records a step.
+	Explain(step ExplainStep)
+}
+
+const explainName = "_resolve_explain"
+
+// SetExplainer installs e in c, so that resolution steps are reported to e.
+// Since configuration is copied into subdirectories, this should be called
+// on the root configuration before directories are visited, or on the
+// configuration for a single directory to explain resolution there.
+func SetExplainer(c *config.Config, e Explainer) {
+	c.Exts[explainName] = e
+}
+
+func getExplainer(c *config.Config, imp ImportSpec) Explainer {
+	e, ok := c.Exts[explainName].(Explainer)
+	if !ok || e == nil || !e.Explaining(imp.Imp) {
+		return nil
+	}
+	return e
+}
+
+// Explaining returns whether an Explainer installed in c wants steps for imp.
+// Resolvers may use this to avoid work that's only needed for explanations.
+func Explaining(c *config.Config, imp ImportSpec) bool {
+	return getExplainer(c, imp) != nil
+}
+
+// Explain reports step to the Explainer installed in c, if it wants steps
+// for step.Imp.
+func Explain(c *config.Config, step ExplainStep) {
+	if e := getExplainer(c, step.Imp); e != nil {
+		e.Explain(step)
+	}
+}
+
+// Explainf reports a step with a message formatted by fmt.Sprintf to the
+// Explainer installed in c, if it wants steps for imp.
+func Explainf(c *config.Config, imp ImportSpec, source, format string, args ...interface{}) {
+	if e := getExplainer(c, imp); e != nil {
+		e.Explain(ExplainStep{Imp: imp, Source: source, Message: fmt.Sprintf(format, args...)})
+	}
+}
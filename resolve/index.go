@@ -16,7 +16,11 @@ limitations under the License.
 package resolve
 
 import (
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/label"
@@ -61,8 +65,27 @@ type Resolver interface {
 	Resolve(c *config.Config, ix *RuleIndex, rc *repo.RemoteCache, r *rule.Rule, imports interface{}, from label.Label)
 }
 
+// ConcurrentResolver may be implemented by a Resolver whose Resolve method is
+// safe to call concurrently for rules in different packages. It may also be
+// implemented by a CrossResolver whose CrossResolve method is safe to call
+// concurrently. When parallel
+// resolution is enabled with -resolve_jobs, Gazelle resolves packages on a pool
+// of worker goroutines if every rule in the package has a Resolver that
+// implements this interface. Other packages are resolved serially.
+//
+// RuleIndex is read-only after Finish, and repo.RemoteCache may be used
+// concurrently, so most Resolvers only need to avoid mutating their own state.
+type ConcurrentResolver interface {
+	// ConcurrentResolve returns true if Resolve may be called concurrently.
+	ConcurrentResolve() bool
+}
+
 // CrossResolver is an interface that language extensions can implement to provide
 // custom dependency resolution logic for other languages.
+//
+// When packages are resolved concurrently, RuleIndex calls CrossResolve
+// serially unless the implementation also implements ConcurrentResolver
+// and its ConcurrentResolve method returns true.
 type CrossResolver interface {
 	// CrossResolve attempts to resolve an import string to a rule for languages
 	// other than the implementing extension. lang is the langauge of the rule
@@ -72,10 +95,21 @@ type CrossResolver interface {
 
 // RuleIndex is a table of rules in a workspace, indexed by label and by
 // import path. Used by Resolver to map import paths to labels.
+//
+// After Finish is called, the index is not modified, and its methods may be
+// called concurrently.
 type RuleIndex struct {
 	mrslv          func(r *rule.Rule, pkgRel string) Resolver
 	crossResolvers []CrossResolver
 
+	// crossResolverMus holds a lock for each CrossResolver in crossResolvers
+	// that isn't safe for concurrent use, or nil for those that are.
+	crossResolverMus []*sync.Mutex
+
+	// cache holds records for build files indexed in previous runs.
+	// May be nil.
+	cache *IndexCache
+
 	// The underlying state of rules. All indexing should be reproducible from this.
 	rules []*ruleRecord
 
@@ -106,12 +140,15 @@ type RuleIndex struct {
 	// the Embeds method). This may include imports of other languages.
 	// Computed from `rules` when indexing.
 	imports map[label.Label][]ImportSpec
+
+	// Indexes of other repositories, like nested modules, keyed by their
+	// apparent names as seen from this repository. They're searched for
+	// imports not provided by rules in this index.
+	repoIndexes map[string]*RuleIndex
 }
 
 // ruleRecord contains information about a rule relevant to import indexing.
 type ruleRecord struct {
-	rule  *rule.Rule
-
 	Kind  string      `json:"kind"`
 	Label label.Label `json:"label"`
 
@@ -136,14 +173,21 @@ type ruleRecord struct {
 // Resolvers that support those kinds.
 func NewRuleIndex(mrslv func(r *rule.Rule, pkgRel string) Resolver, exts ...interface{}) *RuleIndex {
 	var crossResolvers []CrossResolver
+	var crossResolverMus []*sync.Mutex
 	for _, e := range exts {
 		if cr, ok := e.(CrossResolver); ok {
 			crossResolvers = append(crossResolvers, cr)
+			if ccr, ok := e.(ConcurrentResolver); ok && ccr.ConcurrentResolve() {
+				crossResolverMus = append(crossResolverMus, nil)
+			} else {
+				crossResolverMus = append(crossResolverMus, &sync.Mutex{})
+			}
 		}
 	}
 	return &RuleIndex{
-		mrslv:          mrslv,
-		crossResolvers: crossResolvers,
+		mrslv:            mrslv,
+		crossResolvers:   crossResolvers,
+		crossResolverMus: crossResolverMus,
 	}
 }
 
@@ -151,7 +195,7 @@ func NewRuleIndex(mrslv func(r *rule.Rule, pkgRel string) Resolver, exts ...inte
 // is a known resolver for the rule's kind and Resolver.Imports returns a
 // non-nil slice.
 //
-// AddRule may only be called before Finish.
+// AddRule may only be called before Finish, or after RemovePackage.
 func (ix *RuleIndex) AddRule(c *config.Config, r *rule.Rule, f *rule.File) {
 	if ix.indexed {
 		log.Fatal("AddRule called after Finish")
@@ -180,7 +224,6 @@ func (ix *RuleIndex) AddRule(c *config.Config, r *rule.Rule, f *rule.File) {
 	}
 
 	record := &ruleRecord{
-		rule:       r,
 		Kind:       r.Kind(),
 		Pkg:        f.Pkg,
 		Label:      l,
@@ -191,6 +234,47 @@ func (ix *RuleIndex) AddRule(c *config.Config, r *rule.Rule, f *rule.File) {
 	ix.rules = append(ix.rules, record)
 }
 
+// SetRepoIndex makes FindRulesByImport search other, the index of another
+// repository updated in the same run, for imports that aren't provided by
+// rules in this index. This lets rules depend on rules in nested modules and
+// the modules that contain them.
+//
+// repo is the apparent name of the other repository as seen from this one,
+// or "@" for the main repository. Labels of rules found in other are
+// rewritten to use it. Rules in other's own linked indexes aren't found.
+// Calling SetRepoIndex again with the same repo replaces the index, and
+// a nil index removes it. Both indexes must be finished before rules are
+// found.
+func (ix *RuleIndex) SetRepoIndex(repo string, other *RuleIndex) {
+	if other == nil {
+		delete(ix.repoIndexes, repo)
+		return
+	}
+	if ix.repoIndexes == nil {
+		ix.repoIndexes = make(map[string]*RuleIndex)
+	}
+	ix.repoIndexes[repo] = other
+}
+
+// RemovePackage removes rules in the package pkg from the index and reopens
+// the index, so rules may be added again with AddRule. This lets a
+// long-running process update the index when a package changes without
+// indexing the whole repository again. Finish must be called again before
+// the index is used to find rules.
+func (ix *RuleIndex) RemovePackage(pkg string) {
+	rules := ix.rules[:0]
+	for _, r := range ix.rules {
+		if r.Pkg != pkg {
+			rules = append(rules, r)
+		}
+	}
+	for i := len(rules); i < len(ix.rules); i++ {
+		ix.rules[i] = nil
+	}
+	ix.rules = rules
+	ix.indexed = false
+}
+
 // Finish constructs the import index and performs any other necessary indexing
 // actions after all rules have been added. This step is necessary because
 // a rule may be indexed differently based on what rules are added later.
@@ -231,7 +315,6 @@ func (ix *RuleIndex) collectRecordEmbeds(r *ruleRecord, didCollectEmbeds map[lab
 	if _, ok := didCollectEmbeds[r.Label]; ok {
 		return
 	}
-	resolver := ix.mrslv(r.rule, r.Pkg)
 	didCollectEmbeds[r.Label] = true
 	ix.embeds[r.Label] = r.Embeds
 	for _, e := range r.Embeds {
@@ -240,8 +323,7 @@ func (ix *RuleIndex) collectRecordEmbeds(r *ruleRecord, didCollectEmbeds map[lab
 			continue
 		}
 		ix.collectRecordEmbeds(er, didCollectEmbeds)
-		erResolver := ix.mrslv(er.rule, er.Pkg)
-		if resolver.Name() == erResolver.Name() {
+		if r.Lang == er.Lang {
 			ix.embedded[er.Label] = struct{}{}
 			ix.embeds[r.Label] = append(ix.embeds[r.Label], ix.embeds[er.Label]...)
 		}
@@ -291,6 +373,26 @@ type FindResult struct {
 //
 // DEPRECATED: use FindRulesByImportWithConfig instead
 func (ix *RuleIndex) FindRulesByImport(imp ImportSpec, lang string) []FindResult {
+	results := ix.findLocalRulesByImport(imp, lang)
+	if len(results) > 0 || len(ix.repoIndexes) == 0 {
+		return results
+	}
+	repos := make([]string, 0, len(ix.repoIndexes))
+	for repo := range ix.repoIndexes {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		for _, r := range ix.repoIndexes[repo].findLocalRulesByImport(imp, lang) {
+			results = append(results, r.inRepo(repo))
+		}
+	}
+	return results
+}
+
+// findLocalRulesByImport returns rules in this index that provide imp,
+// without searching other repositories' indexes.
+func (ix *RuleIndex) findLocalRulesByImport(imp ImportSpec, lang string) []FindResult {
 	matches := ix.importMap[imp]
 	results := make([]FindResult, 0, len(matches))
 	for _, m := range matches {
@@ -311,14 +413,67 @@ func (ix *RuleIndex) FindRulesByImport(imp ImportSpec, lang string) []FindResult
 func (ix *RuleIndex) FindRulesByImportWithConfig(c *config.Config, imp ImportSpec, lang string) []FindResult {
 	results := ix.FindRulesByImport(imp, lang)
 	if len(results) > 0 {
+		Explainf(c, imp, "index", "found %s", findResultLabels(results))
 		return results
 	}
-	for _, cr := range ix.crossResolvers {
-		results = append(results, cr.CrossResolve(c, ix, imp, lang)...)
+	Explainf(c, imp, "index", "no %s rules found", lang)
+	for i, cr := range ix.crossResolvers {
+		crResults := ix.crossResolve(i, c, imp, lang)
+		if Explaining(c, imp) {
+			name := fmt.Sprintf("%T", cr)
+			if n, ok := cr.(interface{ Name() string }); ok {
+				name = n.Name()
+			}
+			if len(crResults) > 0 {
+				Explainf(c, imp, "cross-resolver", "%s returned %s", name, findResultLabels(crResults))
+			} else {
+				Explainf(c, imp, "cross-resolver", "%s returned nothing", name)
+			}
+		}
+		results = append(results, crResults...)
 	}
 	return results
 }
 
+// crossResolve calls the i'th CrossResolver, holding its lock if it isn't
+// safe for concurrent use.
+func (ix *RuleIndex) crossResolve(i int, c *config.Config, imp ImportSpec, lang string) []FindResult {
+	if mu := ix.crossResolverMus[i]; mu != nil {
+		mu.Lock()
+		defer mu.Unlock()
+	}
+	return ix.crossResolvers[i].CrossResolve(c, ix, imp, lang)
+}
+
+// findResultLabels formats the labels of results for an explanation.
+func findResultLabels(results []FindResult) string {
+	labels := make([]string, len(results))
+	for i, r := range results {
+		labels[i] = r.Label.String()
+	}
+	return strings.Join(labels, ", ")
+}
+
+// inRepo returns a copy of r with labels in r's repository changed to refer
+// to it by the name repo.
+func (r FindResult) inRepo(repo string) FindResult {
+	rename := func(l label.Label) label.Label {
+		if l.Repo == r.Label.Repo {
+			l.Repo = repo
+			l.Canonical = false
+		}
+		return l
+	}
+	res := FindResult{Label: rename(r.Label)}
+	if r.Embeds != nil {
+		res.Embeds = make([]label.Label, len(r.Embeds))
+		for i, e := range r.Embeds {
+			res.Embeds[i] = rename(e)
+		}
+	}
+	return res
+}
+
 // IsSelfImport returns true if the result's label matches the given label
 // or the result's rule transitively embeds the rule with the given label.
 // Self imports cause cyclic dependencies, so the caller may want to omit
@@ -0,0 +1,194 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolve
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// indexCacheVersion should be incremented whenever the format of the cache
+// file or the meaning of its records changes.
+const indexCacheVersion = 1
+
+// IndexCache persists rule index records across runs, so that build files
+// that haven't changed don't need to be indexed again.
+//
+// Records for a build file are stored with a key chosen by the caller. The
+// key must change whenever anything that could affect Resolver.Imports or
+// Resolver.Embeds for rules in the file changes: the file's content, the
+// directives that apply to it, command line flags, and so on. A cache also
+// has a key for the whole run. If that doesn't match the key used when
+// the cache was saved, all records are discarded.
+//
+// The key for the whole run should also change when the Gazelle binary or
+// a language extension is upgraded, since a new version may index the same
+// rules differently. Extensions that aren't built into the Gazelle binary
+// can implement IndexCacheVersioner to contribute their own version.
+//
+// An IndexCache may be used concurrently.
+type IndexCache struct {
+	key string
+
+	mu       sync.Mutex
+	old, new map[string]indexCacheEntry
+}
+
+// IndexCacheVersioner may be implemented by a language extension whose
+// Resolver.Imports or Resolver.Embeds results may change independently of
+// the Gazelle binary, for example because the extension forwards calls to
+// a separate process. Gazelle includes the version in the index cache key,
+// so records saved by an older version of the extension are discarded.
+type IndexCacheVersioner interface {
+	// IndexCacheVersion returns a string that changes whenever the
+	// extension's Imports or Embeds results may change.
+	IndexCacheVersion() string
+}
+
+type indexCacheFile struct {
+	Version  int                        `json:"version"`
+	Key      string                     `json:"key"`
+	Packages map[string]indexCacheEntry `json:"packages"`
+}
+
+type indexCacheEntry struct {
+	Key   string        `json:"key"`
+	Rules []*ruleRecord `json:"rules"`
+}
+
+// LoadIndexCache reads an index cache from path. If the file doesn't exist,
+// was written by a different version of Gazelle, or was saved with a
+// different key, an empty cache is returned. An error is returned only if
+// the file exists but can't be read or parsed.
+func LoadIndexCache(path, key string) (*IndexCache, error) {
+	ic := &IndexCache{
+		key: key,
+		old: make(map[string]indexCacheEntry),
+		new: make(map[string]indexCacheEntry),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ic, nil
+	} else if err != nil {
+		return ic, err
+	}
+	var f indexCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return ic, err
+	}
+	if f.Version == indexCacheVersion && f.Key == key && f.Packages != nil {
+		ic.old = f.Packages
+	}
+	return ic, nil
+}
+
+// Save writes the cache to path. Records for packages indexed during this
+// run replace records loaded from the cache. Records for packages that
+// weren't indexed are kept, since they may be needed by a later run that
+// visits those packages.
+func (ic *IndexCache) Save(path string) error {
+	ic.mu.Lock()
+	pkgs := make(map[string]indexCacheEntry, len(ic.old)+len(ic.new))
+	for pkg, e := range ic.old {
+		pkgs[pkg] = e
+	}
+	for pkg, e := range ic.new {
+		pkgs[pkg] = e
+	}
+	ic.mu.Unlock()
+	data, err := json.Marshal(indexCacheFile{
+		Version:  indexCacheVersion,
+		Key:      ic.key,
+		Packages: pkgs,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a concurrent run never sees a
+	// partially written cache.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// SetCache sets the cache used by AddFileRules.
+func (ix *RuleIndex) SetCache(cache *IndexCache) {
+	ix.cache = cache
+}
+
+// AddFileRules adds all the rules in f to the index, as if AddRule were
+// called for each of them.
+//
+// If the index has a cache, and the cache has records for f's package that
+// were stored with the same key, those records are added instead, and
+// Resolver.Imports and Resolver.Embeds are not called. Otherwise, the
+// records added are stored in the cache with key.
+//
+// f should be a build file as read from disk. Files that were modified
+// during this run should be indexed with AddRule, since their content
+// won't match the key on the next run.
+//
+// AddFileRules may only be called before Finish.
+func (ix *RuleIndex) AddFileRules(c *config.Config, f *rule.File, key string) {
+	if ix.cache == nil {
+		for _, r := range f.Rules {
+			ix.AddRule(c, r, f)
+		}
+		return
+	}
+
+	ix.cache.mu.Lock()
+	e, ok := ix.cache.old[f.Pkg]
+	ix.cache.mu.Unlock()
+	if ok && e.Key == key {
+		ix.rules = append(ix.rules, e.Rules...)
+	} else {
+		start := len(ix.rules)
+		for _, r := range f.Rules {
+			ix.AddRule(c, r, f)
+		}
+		e = indexCacheEntry{Key: key, Rules: ix.rules[start:len(ix.rules):len(ix.rules)]}
+	}
+
+	ix.cache.mu.Lock()
+	ix.cache.new[f.Pkg] = e
+	ix.cache.mu.Unlock()
+}
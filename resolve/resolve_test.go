@@ -1,7 +1,10 @@
 package resolve
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/label"
@@ -137,6 +140,37 @@ func TestFindRuleWithOverride_ParentTraversal(t *testing.T) {
 	}
 }
 
+// serialCrossResolver fails the test if CrossResolve is called concurrently.
+type serialCrossResolver struct {
+	t      *testing.T
+	active int32
+}
+
+func (cr *serialCrossResolver) CrossResolve(c *config.Config, ix *RuleIndex, imp ImportSpec, lang string) []FindResult {
+	if atomic.AddInt32(&cr.active, 1) > 1 {
+		cr.t.Error("CrossResolve called concurrently")
+	}
+	time.Sleep(time.Millisecond)
+	atomic.AddInt32(&cr.active, -1)
+	return nil
+}
+
+func TestCrossResolveSerial(t *testing.T) {
+	cr := &serialCrossResolver{t: t}
+	ix := NewRuleIndex(nil, cr)
+	ix.Finish()
+	c := config.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ix.FindRulesByImportWithConfig(c, ImportSpec{Lang: "go", Imp: "example.com/a"}, "go")
+		}()
+	}
+	wg.Wait()
+}
+
 func getConfig(t *testing.T, path string, directives []rule.Directive, parent *config.Config) *config.Config {
 	cfg := &config.Config{
 		Exts: map[string]interface{}{},
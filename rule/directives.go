@@ -28,10 +28,46 @@ import (
 //
 // Keys may not contain spaces. Values may be empty and may contain spaces,
 // but surrounding space is trimmed.
+//
+// The position a directive was read from is recorded in the File whose
+// Directives include it; see File.DirectivePosition.
 type Directive struct {
 	Key, Value string
 }
 
+// DirectivePosition describes where a directive was read.
+type DirectivePosition struct {
+	// File is the slash-separated path, relative to the repository root, of
+	// the file the directive was read from if it's not the build file whose
+	// Directives include it, for example, gazelle.json. Line is a line in
+	// that file.
+	File string
+
+	// Line is the 1-based line number of the comment the directive was read
+	// from. 0 if it's not known.
+	Line int
+}
+
+// DirectivePosition returns the position the directive d in f.Directives
+// was read from. If f has several directives equal to d, the position of
+// the first is returned. The position is zero if it's not known.
+func (f *File) DirectivePosition(d Directive) DirectivePosition {
+	if f == nil {
+		return DirectivePosition{}
+	}
+	return f.directivePositions[d]
+}
+
+// SetDirectivePosition records that the directive d in f.Directives was
+// read from pos. It's used when directives from another file are added to
+// f.Directives.
+func (f *File) SetDirectivePosition(d Directive, pos DirectivePosition) {
+	if f.directivePositions == nil {
+		f.directivePositions = make(map[Directive]DirectivePosition)
+	}
+	f.directivePositions[d] = pos
+}
+
 // TODO(jayconrod): annotation directives will apply to an individual rule.
 // They must appear in the block of comments above that rule.
 
@@ -39,25 +75,38 @@ type Directive struct {
 // is returned. Errors are reported for unrecognized directives and directives
 // out of place (after the first statement).
 func ParseDirectives(f *bzl.File) []Directive {
-	return parseDirectives(f.Stmt)
+	directives, _ := parseDirectives(f.Stmt)
+	return directives
 }
 
 // ParseDirectivesFromMacro scans a macro body for Gazelle directives. The
 // full list of directives is returned. Errors are reported for unrecognized
 // directives and directives out of place (after the first statement).
 func ParseDirectivesFromMacro(f *bzl.DefStmt) []Directive {
-	return parseDirectives(f.Body)
+	directives, _ := parseDirectives(f.Body)
+	return directives
 }
 
-func parseDirectives(stmt []bzl.Expr) []Directive {
+// parseDirectives returns the directives in stmt and the line each was read
+// from. Lines of directives that appear more than once are those of the
+// first.
+func parseDirectives(stmt []bzl.Expr) ([]Directive, map[Directive]DirectivePosition) {
 	var directives []Directive
+	var positions map[Directive]DirectivePosition
 	parseComment := func(com bzl.Comment) {
 		match := directiveRe.FindStringSubmatch(com.Token)
 		if match == nil {
 			return
 		}
 		key, value := match[1], match[2]
-		directives = append(directives, Directive{key, value})
+		d := Directive{key, value}
+		directives = append(directives, d)
+		if positions == nil {
+			positions = make(map[Directive]DirectivePosition)
+		}
+		if _, ok := positions[d]; !ok {
+			positions[d] = DirectivePosition{Line: com.Start.Line}
+		}
 	}
 
 	for _, s := range stmt {
@@ -69,7 +118,7 @@ func parseDirectives(stmt []bzl.Expr) []Directive {
 			parseComment(com)
 		}
 	}
-	return directives
+	return directives, positions
 }
 
 var directiveRe = regexp.MustCompile(`^#\s*gazelle:(\w+)\s*(.*?)\s*$`)
@@ -26,6 +26,7 @@ func TestParseDirectives(t *testing.T) {
 	for _, tc := range []struct {
 		desc, content string
 		want          []Directive
+		wantLines     []int
 	}{
 		{
 			desc: "empty file",
@@ -46,6 +47,7 @@ foo(
 				{"ignore", "after"},
 				{"ignore", "bottom"},
 			},
+			wantLines: []int{1, 3, 7, 9},
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -54,10 +56,17 @@ foo(
 				t.Fatal(err)
 			}
 
-			got := parseDirectives(f.Stmt)
+			got, positions := parseDirectives(f.Stmt)
 			if !reflect.DeepEqual(got, tc.want) {
 				t.Errorf("got %#v ; want %#v", got, tc.want)
 			}
+			var gotLines []int
+			for _, d := range got {
+				gotLines = append(gotLines, positions[d].Line)
+			}
+			if !reflect.DeepEqual(gotLines, tc.wantLines) {
+				t.Errorf("got lines %v; want %v", gotLines, tc.wantLines)
+			}
 		})
 	}
 }
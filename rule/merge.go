@@ -42,6 +42,15 @@ import (
 // a "# keep" comment will be dropped. If the attribute is empty afterward,
 // it will be deleted.
 func MergeRules(src, dst *Rule, mergeable map[string]bool, filename string) {
+	MergeRulesWithStrategies(src, dst, mergeable, nil, filename)
+}
+
+// MergeRulesWithStrategies is like MergeRules, but mergeable attributes
+// listed in strategies are merged using their strategies instead of
+// MergeReplace, usually from KindInfo.MergeStrategies. Values marked with
+// "# keep" are kept with any strategy. Generated values that implement
+// Merger are merged with their Merge method unless the strategy has a Func.
+func MergeRulesWithStrategies(src, dst *Rule, mergeable map[string]bool, strategies map[string]MergeStrategy, filename string) {
 	if dst.ShouldKeep() {
 		return
 	}
@@ -51,7 +60,7 @@ func MergeRules(src, dst *Rule, mergeable map[string]bool, filename string) {
 		if _, ok := src.attrs[key]; ok || !mergeable[key] || ShouldKeep(dstAttr.expr) {
 			continue
 		}
-		if mergedValue, err := mergeAttrValues(nil, &dstAttr); err != nil {
+		if mergedValue, err := mergeAttrValues(nil, &dstAttr, strategies[key]); err != nil {
 			start, end := dstAttr.expr.RHS.Span()
 			log.Printf("%s:%d.%d-%d.%d: could not merge expression", filename, start.Line, start.LineRune, end.Line, end.LineRune)
 		} else if mergedValue == nil {
@@ -66,7 +75,7 @@ func MergeRules(src, dst *Rule, mergeable map[string]bool, filename string) {
 		if dstAttr, ok := dst.attrs[key]; !ok {
 			dst.SetAttr(key, srcAttr.expr.RHS)
 		} else if mergeable[key] { // Defer the ShouldKeep check to mergeAttrValues
-			if mergedValue, err := mergeAttrValues(&srcAttr, &dstAttr); err != nil {
+			if mergedValue, err := mergeAttrValues(&srcAttr, &dstAttr, strategies[key]); err != nil {
 				start, end := dstAttr.expr.RHS.Span()
 				log.Printf("%s:%d.%d-%d.%d: could not merge expression", filename, start.Line, start.LineRune, end.Line, end.LineRune)
 			} else if mergedValue == nil {
@@ -120,9 +129,12 @@ func areScalarsAndEqual(x, y bzl.Expr) bool {
 //     be the left operand.
 //   - an attr value that implements the Merger interface.
 //
+// Strings are merged according to strategy. If strategy has a Func, it's
+// used instead, for expressions in any format.
+//
 // An error is returned if the expressions can't be merged, for example
 // because they are not in one of the above formats.
-func mergeAttrValues(srcAttr, dstAttr *attrValue) (bzl.Expr, error) {
+func mergeAttrValues(srcAttr, dstAttr *attrValue, strategy MergeStrategy) (bzl.Expr, error) {
 	// Maintain a "noop" behavior when expression should be kept.
 	var mergedScalarDst bzl.Expr
 	if ShouldKeep(dstAttr.expr) {
@@ -132,6 +144,21 @@ func mergeAttrValues(srcAttr, dstAttr *attrValue) (bzl.Expr, error) {
 		return mergedScalarDst, nil
 	}
 	dst := dstAttr.expr.RHS
+	if strategy.Func != nil || strategy.Mode != MergeReplace {
+		if ShouldKeep(dstAttr.expr) {
+			return dst, nil
+		}
+		if strategy.Func != nil {
+			var src bzl.Expr
+			if srcAttr != nil {
+				src = srcAttr.expr.RHS
+			}
+			return strategy.Func(src, dst)
+		}
+		if srcAttr == nil || strategy.Mode == MergeKeepExisting || (strategy.Mode == MergeAppendOnly && isScalar(dst)) {
+			return dst, nil
+		}
+	}
 	if srcAttr == nil && (dst == nil || isScalar(dst)) {
 		return mergedScalarDst, nil
 	}
@@ -173,24 +200,24 @@ func mergeAttrValues(srcAttr, dstAttr *attrValue) (bzl.Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	mergedExprs, err := mergePlatformStringsExprs(srcExprs, dstExprs)
+	mergedExprs, err := mergePlatformStringsExprs(srcExprs, dstExprs, strategy.Mode)
 	if err != nil {
 		return nil, err
 	}
 	return makePlatformStringsExpr(mergedExprs), nil
 }
 
-func mergePlatformStringsExprs(src, dst platformStringsExprs) (platformStringsExprs, error) {
+func mergePlatformStringsExprs(src, dst platformStringsExprs, mode MergeMode) (platformStringsExprs, error) {
 	var ps platformStringsExprs
 	var err error
-	ps.generic = MergeList(src.generic, dst.generic)
-	if ps.os, err = MergeDict(src.os, dst.os); err != nil {
+	ps.generic = mergeList(src.generic, dst.generic, mode)
+	if ps.os, err = mergeDict(src.os, dst.os, mode); err != nil {
 		return platformStringsExprs{}, err
 	}
-	if ps.arch, err = MergeDict(src.arch, dst.arch); err != nil {
+	if ps.arch, err = mergeDict(src.arch, dst.arch, mode); err != nil {
 		return platformStringsExprs{}, err
 	}
-	if ps.platform, err = MergeDict(src.platform, dst.platform); err != nil {
+	if ps.platform, err = mergeDict(src.platform, dst.platform, mode); err != nil {
 		return platformStringsExprs{}, err
 	}
 	return ps, nil
@@ -212,7 +239,17 @@ var RemoveNoopKeepComments bool = false
 // If the result is non-nil, it will have ForceMultiLine set if either of the
 // input lists has ForceMultiLine set or if any of the strings in the result
 // have a "# keep" comment.
+//
+// MergeList always merges like MergeReplace; KindInfo.MergeStrategies
+// don't apply.
 func MergeList(srcExpr, dstExpr bzl.Expr) *bzl.ListExpr {
+	return mergeList(srcExpr, dstExpr, MergeReplace)
+}
+
+// mergeList is like MergeList, but strings only in the dst list are kept
+// if mode is MergeUnion or MergeAppendOnly. mode must not be
+// MergeKeepExisting.
+func mergeList(srcExpr, dstExpr bzl.Expr, mode MergeMode) *bzl.ListExpr {
 	src, isSrcLis := srcExpr.(*bzl.ListExpr)
 	dst, isDstLis := dstExpr.(*bzl.ListExpr)
 	if !isSrcLis && !isDstLis {
@@ -240,7 +277,7 @@ func MergeList(srcExpr, dstExpr bzl.Expr) *bzl.ListExpr {
 	keepComment := false
 	for _, v := range dst.List {
 		s := stringValue(v)
-		if keep := ShouldKeep(v); keep || srcSet[s] {
+		if keep := ShouldKeep(v); keep || srcSet[s] || mode != MergeReplace {
 			if srcSet[s] && RemoveNoopKeepComments {
 				*v.Comment() = removeKeep(v)
 			} else {
@@ -276,8 +313,15 @@ func MergeList(srcExpr, dstExpr bzl.Expr) *bzl.ListExpr {
 // If both src and dst are non-nil, the keys in src are merged into dst. If both
 // src and dst have the same key, the values are merged using MergeList.
 // If the same key is present in both src and dst, and the values are not compatible,
-// an error is returned.
+// an error is returned. Like MergeList, MergeDict always merges like
+// MergeReplace.
 func MergeDict(srcExpr, dstExpr bzl.Expr) (*bzl.DictExpr, error) {
+	return mergeDict(srcExpr, dstExpr, MergeReplace)
+}
+
+// mergeDict is like MergeDict, but values are merged with mergeList
+// using mode.
+func mergeDict(srcExpr, dstExpr bzl.Expr, mode MergeMode) (*bzl.DictExpr, error) {
 	src, isSrcDict := srcExpr.(*bzl.DictExpr)
 	dst, isDstDict := dstExpr.(*bzl.DictExpr)
 	if !isSrcDict && !isDstDict {
@@ -323,7 +367,7 @@ func MergeDict(srcExpr, dstExpr bzl.Expr) (*bzl.DictExpr, error) {
 	keys := make([]string, 0, len(entries))
 	haveDefault := false
 	for _, e := range entries {
-		e.mergedValue = MergeList(e.srcValue, e.dstValue)
+		e.mergedValue = mergeList(e.srcValue, e.dstValue, mode)
 		if e.key == "//conditions:default" {
 			// Keep the default case, even if it's empty.
 			haveDefault = true
@@ -360,6 +404,515 @@ type dictEntry struct {
 	dstValue, srcValue, mergedValue *bzl.ListExpr
 }
 
+// MergeStringDict merges two dicts with string keys, like "env" or
+// "x_defs", and may be used as a MergeStrategy.Func. Entries in dst are kept,
+// and entries in src are added. For keys in both, the value from src is used
+// unless the entry in dst is marked with "# keep". Comments on entries in dst
+// are kept. The result is nil if both dicts are nil or empty. An error is
+// returned if either expression isn't nil or a dict with string keys.
+func MergeStringDict(srcExpr, dstExpr bzl.Expr) (bzl.Expr, error) {
+	var src, dst *bzl.DictExpr
+	for _, x := range []struct {
+		expr bzl.Expr
+		dict **bzl.DictExpr
+	}{{srcExpr, &src}, {dstExpr, &dst}} {
+		if x.expr == nil {
+			continue
+		}
+		d, ok := x.expr.(*bzl.DictExpr)
+		if !ok {
+			return nil, fmt.Errorf("expected dict, got %s", bzl.FormatString(x.expr))
+		}
+		for _, kv := range d.List {
+			if _, ok := kv.Key.(*bzl.StringExpr); !ok {
+				return nil, fmt.Errorf("dict keys are not all strings: %s", bzl.FormatString(x.expr))
+			}
+		}
+		*x.dict = d
+	}
+	if dst == nil {
+		if src == nil || len(src.List) == 0 {
+			return nil, nil
+		}
+		return src, nil
+	}
+	if src == nil {
+		src = &bzl.DictExpr{}
+	}
+
+	srcValues := make(map[string]bzl.Expr)
+	for _, kv := range src.List {
+		srcValues[kv.Key.(*bzl.StringExpr).Value] = kv.Value
+	}
+	var merged []*bzl.KeyValueExpr
+	have := make(map[string]bool)
+	for _, kv := range dst.List {
+		k := kv.Key.(*bzl.StringExpr).Value
+		have[k] = true
+		if v, ok := srcValues[k]; ok && !ShouldKeep(kv) && !ShouldKeep(kv.Value) {
+			kv = &bzl.KeyValueExpr{Comments: kv.Comments, Key: kv.Key, Value: v}
+		}
+		merged = append(merged, kv)
+	}
+	for _, kv := range src.List {
+		if !have[kv.Key.(*bzl.StringExpr).Value] {
+			merged = append(merged, kv)
+		}
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return &bzl.DictExpr{List: merged, ForceMultiLine: src.ForceMultiLine || dst.ForceMultiLine}, nil
+}
+
+// MergeRulesWithBase is like MergeRules, but it does a three-way merge of
+// mergeable attributes using base, the rule Gazelle generated for dst in an
+// earlier run. Changes from base to src, made by Gazelle, are applied to dst,
+// and changes from base to dst, made by hand, are kept:
+//
+//   - If an attribute is the same in base and src, dst is not changed.
+//   - If an attribute is the same in base and dst, it's merged as MergeRules
+//     would merge it.
+//   - Otherwise, strings that src removed from lists and select expressions
+//     in base are removed from dst, and strings that src added are added
+//     to dst. Other strings in dst are kept.
+//
+// Lists and select expressions are compared as sets, ignoring order and
+// comments. Attributes that aren't mergeable are added to dst if they're
+// in src and weren't in base; they're not added again if they were removed
+// from dst by hand. If base is nil, MergeRulesWithBase is the same as
+// MergeRules.
+//
+// MergeRulesWithBase returns the sorted names of mergeable attributes that
+// were changed in both src and dst in ways that can't be combined, like a
+// string set to different values. Those attributes are not changed in dst.
+func MergeRulesWithBase(base, src, dst *Rule, mergeable map[string]bool, filename string) (conflicts []string) {
+	return MergeRulesWithBaseAndStrategies(base, src, dst, mergeable, nil, filename)
+}
+
+// MergeRulesWithBaseAndStrategies is like MergeRulesWithBase, but mergeable
+// attributes listed in strategies are merged using their strategies, as
+// MergeRulesWithStrategies merges them. If such an attribute is the same in
+// base and src, dst is not changed. Otherwise, the strategy decides which
+// values in dst are kept, so these attributes never conflict. If base is
+// nil, MergeRulesWithBaseAndStrategies is the same as
+// MergeRulesWithStrategies.
+func MergeRulesWithBaseAndStrategies(base, src, dst *Rule, mergeable map[string]bool, strategies map[string]MergeStrategy, filename string) (conflicts []string) {
+	if base == nil {
+		MergeRulesWithStrategies(src, dst, mergeable, strategies, filename)
+		return nil
+	}
+	if dst.ShouldKeep() {
+		return nil
+	}
+
+	keys := make([]string, 0, len(src.attrs)+len(dst.attrs))
+	for key := range src.attrs {
+		keys = append(keys, key)
+	}
+	for key := range dst.attrs {
+		if _, ok := src.attrs[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		srcAttr, inSrc := src.attrs[key]
+		dstAttr, inDst := dst.attrs[key]
+		if !mergeable[key] {
+			// Attributes in base but not dst were removed by hand.
+			if inSrc && !inDst && base.Attr(key) == nil {
+				dst.SetAttr(key, srcAttr.expr.RHS)
+			}
+			continue
+		}
+		var srcExpr, dstExpr bzl.Expr
+		if inSrc {
+			srcExpr = srcAttr.expr.RHS
+		}
+		if inDst {
+			dstExpr = dstAttr.expr.RHS
+		}
+		baseExpr := base.Attr(key)
+		var srcAttrPtr *attrValue
+		if inSrc {
+			srcAttrPtr = &srcAttr
+		}
+		strategy, hasStrategy := strategies[key]
+
+		switch {
+		case equivalentExprs(baseExpr, srcExpr):
+			// Gazelle didn't change the attribute. Keep any manual edits.
+			continue
+
+		case hasStrategy || equivalentExprs(baseExpr, dstExpr) || (inDst && (ShouldKeep(dstAttr.expr) || ShouldKeep(dstExpr))):
+			// The attribute has a strategy, it wasn't edited by hand, or
+			// it's marked with a "# keep" comment, which is handled like a
+			// two-way merge.
+			mergeAttr(srcAttrPtr, dst, key, strategy, filename)
+
+		default:
+			if merged, ok := mergeAttrValuesWithBase(baseExpr, srcExpr, dstExpr); ok {
+				if merged == nil {
+					dst.DelAttr(key)
+				} else {
+					dst.SetAttr(key, merged)
+				}
+			} else if !equivalentExprs(srcExpr, dstExpr) {
+				conflicts = append(conflicts, key)
+			}
+		}
+	}
+
+	dst.private = src.private
+	return conflicts
+}
+
+// mergeAttr merges the attribute key from src into dst the way
+// MergeRulesWithStrategies does with strategy. srcAttr is nil if src
+// doesn't have the attribute.
+func mergeAttr(srcAttr *attrValue, dst *Rule, key string, strategy MergeStrategy, filename string) {
+	dstAttr, ok := dst.attrs[key]
+	if !ok {
+		if srcAttr != nil {
+			dst.SetAttr(key, srcAttr.expr.RHS)
+		}
+		return
+	}
+	if srcAttr == nil && ShouldKeep(dstAttr.expr) {
+		return
+	}
+	if mergedValue, err := mergeAttrValues(srcAttr, &dstAttr, strategy); err != nil {
+		start, end := dstAttr.expr.RHS.Span()
+		log.Printf("%s:%d.%d-%d.%d: could not merge expression", filename, start.Line, start.LineRune, end.Line, end.LineRune)
+	} else if mergedValue == nil {
+		dst.DelAttr(key)
+	} else {
+		dst.SetAttr(key, mergedValue)
+	}
+}
+
+// equivalentExprs returns whether x and y have the same value. Scalars are
+// compared directly. Lists and select expressions are compared as sets of
+// strings for each case, ignoring order and comments. Other expressions are
+// compared by their formatted text. nil is equivalent only to nil.
+func equivalentExprs(x, y bzl.Expr) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	if isScalar(x) || isScalar(y) {
+		return areScalarsAndEqual(x, y)
+	}
+	xps, xerr := extractPlatformStringsExprs(x)
+	yps, yerr := extractPlatformStringsExprs(y)
+	if xerr != nil || yerr != nil {
+		return bzl.FormatString(x) == bzl.FormatString(y)
+	}
+	return equalStringSets(listStrings(xps.generic), listStrings(yps.generic)) &&
+		equivalentDicts(xps.os, yps.os) &&
+		equivalentDicts(xps.arch, yps.arch) &&
+		equivalentDicts(xps.platform, yps.platform)
+}
+
+func equivalentDicts(x, y *bzl.DictExpr) bool {
+	xm, ym := dictStrings(x), dictStrings(y)
+	if xm == nil || ym == nil {
+		return false
+	}
+	for k, v := range xm {
+		if !equalStringSets(v, ym[k]) {
+			return false
+		}
+	}
+	for k, v := range ym {
+		if _, ok := xm[k]; !ok && len(v) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeAttrValuesWithBase does a three-way merge of lists and select
+// expressions. It returns false if any of the expressions is a scalar or
+// doesn't have the structure described by platformStringsExprs.
+func mergeAttrValuesWithBase(base, src, dst bzl.Expr) (bzl.Expr, bool) {
+	for _, e := range []bzl.Expr{base, src, dst} {
+		if e != nil && isScalar(e) {
+			return nil, false
+		}
+	}
+	bps, err := extractPlatformStringsExprs(base)
+	if err != nil {
+		return nil, false
+	}
+	sps, err := extractPlatformStringsExprs(src)
+	if err != nil {
+		return nil, false
+	}
+	dps, err := extractPlatformStringsExprs(dst)
+	if err != nil {
+		return nil, false
+	}
+	var merged platformStringsExprs
+	merged.generic = mergeListWithBase(bps.generic, sps.generic, dps.generic)
+	var ok bool
+	if merged.os, ok = mergeDictWithBase(bps.os, sps.os, dps.os); !ok {
+		return nil, false
+	}
+	if merged.arch, ok = mergeDictWithBase(bps.arch, sps.arch, dps.arch); !ok {
+		return nil, false
+	}
+	if merged.platform, ok = mergeDictWithBase(bps.platform, sps.platform, dps.platform); !ok {
+		return nil, false
+	}
+	return makePlatformStringsExpr(merged), true
+}
+
+// mergeListWithBase does a three-way merge of lists of strings. Strings in
+// dst are kept, with their comments, unless they're in base but not in src.
+// Strings in src but not in base are added. Strings marked with "# keep" are
+// always kept. The result is nil if it's empty.
+func mergeListWithBase(base, src, dst *bzl.ListExpr) *bzl.ListExpr {
+	baseSet := make(map[string]bool)
+	for _, s := range listStrings(base) {
+		baseSet[s] = true
+	}
+	srcSet := make(map[string]bool)
+	for _, s := range listStrings(src) {
+		srcSet[s] = true
+	}
+
+	var merged []bzl.Expr
+	have := make(map[string]bool)
+	forceMultiLine := false
+	if dst != nil {
+		forceMultiLine = dst.ForceMultiLine
+		for _, v := range dst.List {
+			s := stringValue(v)
+			if baseSet[s] && !srcSet[s] && !ShouldKeep(v) {
+				continue
+			}
+			merged = append(merged, v)
+			have[s] = true
+		}
+	}
+	if src != nil {
+		forceMultiLine = forceMultiLine || src.ForceMultiLine
+		for _, v := range src.List {
+			if s := stringValue(v); s != "" && !baseSet[s] && !have[s] {
+				merged = append(merged, v)
+				have[s] = true
+			}
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return &bzl.ListExpr{List: merged, ForceMultiLine: forceMultiLine}
+}
+
+// mergeDictWithBase does a three-way merge of dicts of lists of strings, as
+// used in select expressions, merging each case with mergeListWithBase.
+// It returns false if a dict doesn't have the expected structure.
+func mergeDictWithBase(base, src, dst *bzl.DictExpr) (*bzl.DictExpr, bool) {
+	if base == nil && src == nil {
+		return dst, true
+	}
+	entries := make(map[string][3]*bzl.ListExpr)
+	var keys []string
+	for i, d := range []*bzl.DictExpr{base, src, dst} {
+		if d == nil {
+			continue
+		}
+		for _, kv := range d.List {
+			k, v, err := dictEntryKeyValue(kv)
+			if err != nil {
+				return nil, false
+			}
+			e, ok := entries[k]
+			if !ok && k != "//conditions:default" {
+				keys = append(keys, k)
+			}
+			e[i] = v
+			entries[k] = e
+		}
+	}
+	sort.Strings(keys)
+	var list []*bzl.KeyValueExpr
+	for _, k := range keys {
+		e := entries[k]
+		if v := mergeListWithBase(e[0], e[1], e[2]); v != nil {
+			list = append(list, &bzl.KeyValueExpr{Key: &bzl.StringExpr{Value: k}, Value: v})
+		}
+	}
+	if e, ok := entries["//conditions:default"]; ok {
+		v := mergeListWithBase(e[0], e[1], e[2])
+		if v == nil {
+			if len(list) == 0 {
+				return nil, true
+			}
+			v = &bzl.ListExpr{}
+		}
+		list = append(list, &bzl.KeyValueExpr{Key: &bzl.StringExpr{Value: "//conditions:default"}, Value: v})
+	}
+	if len(list) == 0 {
+		return nil, true
+	}
+	return &bzl.DictExpr{List: list, ForceMultiLine: true}, true
+}
+
+// listStrings returns the strings in a list. Other values are ignored.
+func listStrings(l *bzl.ListExpr) []string {
+	if l == nil {
+		return nil
+	}
+	var strs []string
+	for _, v := range l.List {
+		if s := stringValue(v); s != "" {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// dictStrings returns the strings in each case of a dict used in a select
+// expression. It returns nil if the dict doesn't have the expected
+// structure, and an empty map if d is nil.
+func dictStrings(d *bzl.DictExpr) map[string][]string {
+	m := make(map[string][]string)
+	if d == nil {
+		return m
+	}
+	for _, kv := range d.List {
+		k, v, err := dictEntryKeyValue(kv)
+		if err != nil {
+			return nil
+		}
+		m[k] = listStrings(v)
+	}
+	return m
+}
+
+func equalStringSets(x, y []string) bool {
+	xs := make(map[string]bool, len(x))
+	for _, s := range x {
+		xs[s] = true
+	}
+	ys := make(map[string]bool, len(y))
+	for _, s := range y {
+		if !xs[s] {
+			return false
+		}
+		ys[s] = true
+	}
+	return len(xs) == len(ys)
+}
+
+// MergeRulesReportingOverwrites is like MergeRules, but it also returns the
+// sorted names of mergeable attributes where the merge overwrote a value in
+// dst that was edited by hand. A value was edited by hand when it differs
+// from both the value in src and the union of the two values, and it was
+// overwritten when the merge replaced a scalar or dropped strings from a
+// list or select expression. Values that only lack strings added to src or
+// only have strings removed from src, as when a source file is added or
+// deleted, aren't reported. Neither are attributes that src doesn't set,
+// values marked with "# keep", or expressions that can't be merged.
+func MergeRulesReportingOverwrites(src, dst *Rule, mergeable map[string]bool, filename string) (overwrites []string) {
+	if dst.ShouldKeep() {
+		return nil
+	}
+
+	// Record old values before merging, since lists may be reused.
+	type oldValue struct {
+		scalar           bzl.Expr
+		strings, srcStrs map[string][]string
+	}
+	old := make(map[string]oldValue)
+	for key, dstAttr := range dst.attrs {
+		srcValue := src.Attr(key)
+		if !mergeable[key] || srcValue == nil {
+			continue
+		}
+		if e := dstAttr.expr.RHS; isScalar(e) {
+			old[key] = oldValue{scalar: e}
+		} else if strs, ok := platformStrings(e); ok {
+			if srcStrs, ok := platformStrings(srcValue); ok {
+				old[key] = oldValue{strings: strs, srcStrs: srcStrs}
+			}
+		}
+	}
+
+	MergeRules(src, dst, mergeable, filename)
+
+	for key, o := range old {
+		merged := dst.Attr(key)
+		if o.scalar != nil {
+			if merged == nil || !areScalarsAndEqual(o.scalar, merged) {
+				overwrites = append(overwrites, key)
+			}
+			continue
+		}
+		// If src has no strings missing from the old value, the old value is
+		// the union, and any strings dropped were removed from src.
+		if !droppedStrings(o.srcStrs, o.strings) {
+			continue
+		}
+		strs, ok := map[string][]string{}, true
+		if merged != nil {
+			strs, ok = platformStrings(merged)
+		}
+		if ok && droppedStrings(o.strings, strs) {
+			overwrites = append(overwrites, key)
+		}
+	}
+	sort.Strings(overwrites)
+	return overwrites
+}
+
+// platformStrings returns the strings in a list or select expression,
+// indexed by case. Strings in the list are indexed by "", and strings in
+// select expressions by the kind of select and the condition, for example,
+// "os:@io_bazel_rules_go//go/platform:linux". It returns false if e doesn't
+// have the structure described by platformStringsExprs.
+func platformStrings(e bzl.Expr) (map[string][]string, bool) {
+	ps, err := extractPlatformStringsExprs(e)
+	if err != nil {
+		return nil, false
+	}
+	m := map[string][]string{"": listStrings(ps.generic)}
+	for _, d := range []struct {
+		prefix string
+		dict   *bzl.DictExpr
+	}{{"os:", ps.os}, {"arch:", ps.arch}, {"platform:", ps.platform}} {
+		cases := dictStrings(d.dict)
+		if cases == nil {
+			return nil, false
+		}
+		for k, v := range cases {
+			m[d.prefix+k] = v
+		}
+	}
+	return m, true
+}
+
+// droppedStrings returns whether any string in old is missing from the same
+// case in merged.
+func droppedStrings(old, merged map[string][]string) bool {
+	for k, strs := range old {
+		have := make(map[string]bool, len(merged[k]))
+		for _, s := range merged[k] {
+			have[s] = true
+		}
+		for _, s := range strs {
+			if !have[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // SquashRules copies information from src into dst without discarding
 // information in dst. SquashRules detects duplicate elements in lists and
 // dictionaries, but it doesn't sort elements after squashing. If squashing
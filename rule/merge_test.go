@@ -20,6 +20,7 @@ import (
 
 	"github.com/bazelbuild/bazel-gazelle/rule"
 	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestMergeRules(t *testing.T) {
@@ -147,3 +148,340 @@ func TestMergeRules_WithUnsortedStringAttr(t *testing.T) {
 		}
 	})
 }
+
+func TestMergeRulesWithBase(t *testing.T) {
+	mergeable := map[string]bool{"srcs": true, "deps": true, "importpath": true}
+	for _, tc := range []struct {
+		desc, base, src, dst, want string
+		strategies                 map[string]rule.MergeStrategy
+		wantConflicts              []string
+	}{
+		{
+			desc: "no base",
+			src:  `srcs = ["a.go"]`,
+			dst:  `srcs = ["a.go", "b.go"]`,
+			want: `srcs = ["a.go"]`,
+		},
+		{
+			desc: "manual changes kept",
+			base: `srcs = ["a.go", "b.go"]`,
+			src:  `srcs = ["a.go", "b.go"]`,
+			dst:  `srcs = ["a.go", "c.go"]`,
+			want: `srcs = ["a.go", "c.go"]`,
+		},
+		{
+			desc: "generated changes applied",
+			base: `srcs = ["a.go", "b.go"], importpath = "example.com/a"`,
+			src:  `srcs = ["a.go", "d.go"], importpath = "example.com/b"`,
+			dst:  `srcs = ["a.go", "b.go"], importpath = "example.com/a"`,
+			want: `srcs = ["a.go", "d.go"], importpath = "example.com/b"`,
+		},
+		{
+			desc: "both changed",
+			base: `srcs = ["a.go", "b.go", "c.go"]`,
+			src:  `srcs = ["a.go", "c.go", "d.go"]`,
+			dst:  `srcs = ["b.go", "c.go", "e.go"]`,
+			want: `srcs = ["c.go", "e.go", "d.go"]`,
+		},
+		{
+			desc: "select",
+			base: `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//x"], "//conditions:default": []})`,
+			src:  `deps = ["//y"] + select({"@io_bazel_rules_go//go/platform:darwin": ["//z"], "@io_bazel_rules_go//go/platform:linux": ["//x"], "//conditions:default": []})`,
+			dst:  `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//x", "//extra"], "//conditions:default": []})`,
+			want: `deps = [
+				"//y",
+			] + select({"@io_bazel_rules_go//go/platform:darwin": ["//z"], "@io_bazel_rules_go//go/platform:linux": ["//x", "//extra"], "//conditions:default": []})`,
+		},
+		{
+			desc: "attribute added by hand",
+			base: `srcs = ["a.go"]`,
+			src:  `srcs = ["a.go"], deps = ["//x"]`,
+			dst:  `srcs = ["a.go"], deps = ["//y"]`,
+			want: `srcs = ["a.go"], deps = ["//y", "//x"]`,
+		},
+		{
+			desc:          "conflict",
+			base:          `importpath = "example.com/a"`,
+			src:           `importpath = "example.com/b"`,
+			dst:           `importpath = "example.com/c"`,
+			want:          `importpath = "example.com/c"`,
+			wantConflicts: []string{"importpath"},
+		},
+		{
+			desc: "same change",
+			base: `importpath = "example.com/a"`,
+			src:  `importpath = "example.com/b"`,
+			dst:  `importpath = "example.com/b"`,
+			want: `importpath = "example.com/b"`,
+		},
+		{
+			desc: "unmergeable attribute removed by hand",
+			base: `srcs = ["a_test.go"], embed = [":a"]`,
+			src:  `srcs = ["a_test.go"], embed = [":a"]`,
+			dst:  `srcs = ["a_test.go"]`,
+			want: `srcs = ["a_test.go"]`,
+		},
+		{
+			desc: "unmergeable attribute added",
+			base: `srcs = ["a_test.go"]`,
+			src:  `srcs = ["a_test.go"], embed = [":a"]`,
+			dst:  `srcs = ["a_test.go"]`,
+			want: `srcs = ["a_test.go"], embed = [":a"]`,
+		},
+		{
+			desc:       "keep existing strategy",
+			base:       `importpath = "example.com/a"`,
+			src:        `importpath = "example.com/b"`,
+			dst:        `importpath = "example.com/c"`,
+			want:       `importpath = "example.com/c"`,
+			strategies: map[string]rule.MergeStrategy{"importpath": {Mode: rule.MergeKeepExisting}},
+		},
+		{
+			desc:       "union strategy",
+			base:       `deps = ["//x"]`,
+			src:        `deps = ["//z"]`,
+			dst:        `deps = ["//x", "//y"]`,
+			want:       `deps = ["//x", "//y", "//z"]`,
+			strategies: map[string]rule.MergeStrategy{"deps": {Mode: rule.MergeUnion}},
+		},
+		{
+			desc:       "strategy with unchanged base",
+			base:       `deps = ["//x"]`,
+			src:        `deps = ["//x"]`,
+			dst:        `deps = ["//y"]`,
+			want:       `deps = ["//y"]`,
+			strategies: map[string]rule.MergeStrategy{"deps": {Mode: rule.MergeUnion}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			load := func(attrs string) *rule.Rule {
+				f, err := rule.LoadData("BUILD.bazel", "", []byte(`go_library(name = "a", `+attrs+`)`))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return f.Rules[0]
+			}
+			var base *rule.Rule
+			if tc.base != "" {
+				base = load(tc.base)
+			}
+			src := load(tc.src)
+			f, err := rule.LoadData("BUILD.bazel", "", []byte(`go_library(name = "a", `+tc.dst+`)`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			conflicts := rule.MergeRulesWithBaseAndStrategies(base, src, f.Rules[0], mergeable, tc.strategies, "BUILD.bazel")
+			if diff := cmp.Diff(tc.wantConflicts, conflicts); diff != "" {
+				t.Errorf("conflicts (-want,+got):\n%s", diff)
+			}
+			format := func(r *rule.Rule) string {
+				return bzl.FormatString(r.Attr("srcs")) + bzl.FormatString(r.Attr("deps")) + bzl.FormatString(r.Attr("importpath")) + bzl.FormatString(r.Attr("embed"))
+			}
+			if got, want := format(f.Rules[0]), format(load(tc.want)); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMergeRulesReportingOverwrites(t *testing.T) {
+	mergeable := map[string]bool{"srcs": true, "deps": true, "importpath": true}
+	for _, tc := range []struct {
+		desc, src, dst string
+		want           []string
+	}{
+		{
+			desc: "same",
+			src:  `srcs = ["a.go"], importpath = "example.com/a"`,
+			dst:  `srcs = ["a.go"], importpath = "example.com/a"`,
+		},
+		{
+			desc: "added",
+			src:  `srcs = ["a.go", "b.go"], deps = ["//x"]`,
+			dst:  `srcs = ["a.go"]`,
+		},
+		{
+			desc: "deleted",
+			src:  `srcs = ["a.go"]`,
+			dst:  `srcs = ["a.go", "b.go"], deps = ["//x"], importpath = "example.com/a"`,
+		},
+		{
+			desc: "dropped",
+			src:  `srcs = ["a.go", "c.go"]`,
+			dst:  `srcs = ["a.go", "b.go"], deps = ["//x"]`,
+			want: []string{"srcs"},
+		},
+		{
+			desc: "kept",
+			src:  `srcs = ["a.go"]`,
+			dst: `srcs = ["a.go", "b.go"],  # keep
+				deps = ["//x"]  # keep
+			`,
+		},
+		{
+			desc: "string replaced",
+			src:  `importpath = "example.com/a"`,
+			dst:  `importpath = "example.com/b"`,
+			want: []string{"importpath"},
+		},
+		{
+			desc: "select",
+			src:  `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//x", "//z"], "//conditions:default": []})`,
+			dst:  `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//x", "//y"], "//conditions:default": []})`,
+			want: []string{"deps"},
+		},
+		{
+			desc: "select added",
+			src:  `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//x", "//y"], "//conditions:default": []})`,
+			dst:  `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//x"], "//conditions:default": []})`,
+		},
+		{
+			desc: "not mergeable",
+			src:  `srcs = ["a.go"]`,
+			dst:  `srcs = ["a.go"], visibility = ["//visibility:public"]`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			load := func(attrs string) *rule.Rule {
+				f, err := rule.LoadData("BUILD.bazel", "", []byte("go_library(name = \"a\", "+attrs+")"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return f.Rules[0]
+			}
+			got := rule.MergeRulesReportingOverwrites(load(tc.src), load(tc.dst), mergeable, "BUILD.bazel")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("overwrites (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeRulesWithStrategies(t *testing.T) {
+	mergeable := map[string]bool{"srcs": true, "deps": true, "importpath": true, "x_defs": true}
+	for _, tc := range []struct {
+		desc     string
+		strategy rule.MergeStrategy
+		src, dst string
+		want     string
+	}{
+		{
+			desc:     "replace",
+			strategy: rule.MergeStrategy{Mode: rule.MergeReplace},
+			src:      `deps = ["//a"]`,
+			dst: `deps = [
+        "//b",
+        "//c",  # keep
+    ]`,
+			want: `deps = [
+        "//a",
+        "//c",  # keep
+    ]`,
+		},
+		{
+			desc:     "union list",
+			strategy: rule.MergeStrategy{Mode: rule.MergeUnion},
+			src:      `deps = ["//a"], importpath = "example.com/new"`,
+			dst:      `deps = ["//b"], importpath = "example.com/old"`,
+			want: `deps = [
+        "//a",
+        "//b",
+    ], importpath = "example.com/new"`,
+		},
+		{
+			desc:     "union not generated",
+			strategy: rule.MergeStrategy{Mode: rule.MergeUnion},
+			dst:      `deps = ["//b"]`,
+			want:     `deps = ["//b"]`,
+		},
+		{
+			desc:     "union select",
+			strategy: rule.MergeStrategy{Mode: rule.MergeUnion},
+			src:      `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//a"], "//conditions:default": []})`,
+			dst:      `deps = select({"@io_bazel_rules_go//go/platform:darwin": ["//d"], "@io_bazel_rules_go//go/platform:linux": ["//b"], "//conditions:default": []})`,
+			want: `deps = select({
+        "@io_bazel_rules_go//go/platform:darwin": ["//d"],
+        "@io_bazel_rules_go//go/platform:linux": [
+            "//a",
+            "//b",
+        ],
+        "//conditions:default": [],
+    })`,
+		},
+		{
+			desc:     "union platform strings",
+			strategy: rule.MergeStrategy{Mode: rule.MergeUnion},
+			src:      `srcs = ["a.go"] + select({"@io_bazel_rules_go//go/platform:linux": ["a_linux.go"], "//conditions:default": []})`,
+			dst:      `srcs = ["b.go"] + select({"@io_bazel_rules_go//go/platform:windows": ["b_windows.go"], "//conditions:default": []})`,
+			want: `srcs = [
+        "a.go",
+        "b.go",
+    ] + select({
+        "@io_bazel_rules_go//go/platform:linux": ["a_linux.go"],
+        "@io_bazel_rules_go//go/platform:windows": ["b_windows.go"],
+        "//conditions:default": [],
+    })`,
+		},
+		{
+			desc:     "append only",
+			strategy: rule.MergeStrategy{Mode: rule.MergeAppendOnly},
+			src:      `deps = select({"@io_bazel_rules_go//go/platform:linux": ["//a"], "//conditions:default": []}), importpath = "example.com/new"`,
+			dst:      `deps = ["//b"], importpath = "example.com/old"`,
+			want: `deps = [
+        "//b",
+    ] + select({
+        "@io_bazel_rules_go//go/platform:linux": ["//a"],
+        "//conditions:default": [],
+    }), importpath = "example.com/old"`,
+		},
+		{
+			desc:     "keep existing",
+			strategy: rule.MergeStrategy{Mode: rule.MergeKeepExisting},
+			src:      `srcs = ["a.go"], deps = ["//a"]`,
+			dst:      `deps = ["//b"]`,
+			want:     `srcs = ["a.go"], deps = ["//b"]`,
+		},
+		{
+			desc:     "func",
+			strategy: rule.MergeStrategy{Func: rule.MergeStringDict},
+			src:      `x_defs = {"Version": "2", "Commit": "abc"}`,
+			dst: `x_defs = {
+        "Version": "1",
+        "Extra": "x",
+        "Commit": "def",  # keep
+    }`,
+			want: `x_defs = {
+        "Version": "2",
+        "Extra": "x",
+        "Commit": "def",  # keep
+    }`,
+		},
+		{
+			desc:     "func not generated",
+			strategy: rule.MergeStrategy{Func: rule.MergeStringDict},
+			dst:      `x_defs = {"Extra": "x"}`,
+			want:     `x_defs = {"Extra": "x"}`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			load := func(attrs string) *rule.File {
+				f, err := rule.LoadData("BUILD.bazel", "", []byte("go_library(name = \"a\", "+attrs+")"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return f
+			}
+			strategies := make(map[string]rule.MergeStrategy)
+			for key := range mergeable {
+				strategies[key] = tc.strategy
+			}
+			src := load(tc.src).Rules[0]
+			dst := load(tc.dst)
+			rule.MergeRulesWithStrategies(src, dst.Rules[0], mergeable, strategies, "BUILD.bazel")
+			want := load(tc.want)
+			if diff := cmp.Diff(string(want.Format()), string(dst.Format())); diff != "" {
+				t.Errorf("(-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
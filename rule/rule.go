@@ -64,6 +64,9 @@ type File struct {
 	// comments in the file. This should not be modified after the file is read.
 	Directives []Directive
 
+	// directivePositions records where each directive in Directives was read.
+	directivePositions map[Directive]DirectivePosition
+
 	// Loads is a list of load statements within the file. This should not
 	// be modified directly; use Load methods instead.
 	Loads []*Load
@@ -227,9 +230,9 @@ func ScanASTBody(pkg, defName string, bzlFile *bzl.File) *File {
 		}
 	}
 	if f.function != nil {
-		f.Directives = ParseDirectivesFromMacro(f.function.stmt)
+		f.Directives, f.directivePositions = parseDirectives(f.function.stmt.Body)
 	} else {
-		f.Directives = ParseDirectives(bzlFile)
+		f.Directives, f.directivePositions = parseDirectives(bzlFile.Stmt)
 	}
 	return f
 }
@@ -952,6 +955,26 @@ func (r *Rule) DelAttr(key string) {
 	r.updated = true
 }
 
+// RenameAttr renames the attribute from to to, keeping its value and
+// comments. If the rule already has an attribute named to, it's replaced.
+// RenameAttr does nothing if the rule has no attribute named from.
+//
+// The attribute is renamed in place, so renaming doesn't mark the rule as
+// updated unless an attribute was replaced. Renaming an attribute and back
+// again leaves the rule unchanged.
+func (r *Rule) RenameAttr(from, to string) {
+	attr, ok := r.attrs[from]
+	if !ok || from == to {
+		return
+	}
+	if _, ok := r.attrs[to]; ok {
+		r.updated = true
+	}
+	delete(r.attrs, from)
+	attr.expr.LHS = &bzl.Ident{Name: to}
+	r.attrs[to] = attr
+}
+
 // SetAttr adds or replaces the named attribute with value. If the attribute is
 // mergeable, then the value must implement the Merger interface, or an error will
 // be returned.
@@ -647,6 +647,44 @@ a_rule(
 	}
 }
 
+func TestRenameAttr(t *testing.T) {
+	f, err := LoadData(filepath.Join("old", "BUILD.bazel"), "", []byte(`
+go_test(
+    name = "a_test",
+    # comment
+    embed = [":a"],
+    race = "on",
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := f.Rules[0]
+	r.RenameAttr("embed", "library")
+	r.RenameAttr("missing", "other")
+	r.RenameAttr("library", "race")
+	f.Sync()
+
+	got := strings.TrimSpace(string(bzl.FormatWithoutRewriting(f.File)))
+	want := strings.TrimSpace(`
+go_test(
+    name = "a_test",
+    # comment
+    race = [":a"],
+)
+`)
+	if got != want {
+		t.Errorf("got:%s\nwant:%s", got, want)
+	}
+
+	// Renaming an attribute and back doesn't change the rule.
+	r.RenameAttr("race", "library")
+	r.RenameAttr("library", "race")
+	if r.updated {
+		t.Error("rule was marked as updated after renaming an attribute back")
+	}
+}
+
 func TestSimpleArgument(t *testing.T) {
 	f := EmptyFile("foo", "bar")
 
@@ -15,6 +15,8 @@ limitations under the License.
 
 package rule
 
+import bzl "github.com/bazelbuild/buildtools/build"
+
 // LoadInfo describes a file that Gazelle knows about and the symbols
 // it defines.
 type LoadInfo struct {
@@ -35,6 +37,16 @@ type KindInfo struct {
 	// in order.
 	MatchAttrs []string
 
+	// MatchSrcsOverlap, if greater than zero, lets a generated rule of this
+	// kind that doesn't match an existing rule by name, MatchAttrs, or
+	// MatchAny be matched with the existing rule of the same kind whose
+	// "srcs" overlap its own the most. The overlap is the number of sources
+	// the rules have in common divided by the number of sources in either,
+	// and it must be at least MatchSrcsOverlap, which is at most 1. This lets
+	// Gazelle follow a rule whose name and attributes in MatchAttrs changed
+	// together, for example, when a package was renamed.
+	MatchSrcsOverlap float64
+
 	// NonEmptyAttrs is a set of attributes that, if present, disqualify a rule
 	// from being deleted after merge.
 	NonEmptyAttrs map[string]bool
@@ -53,4 +65,61 @@ type KindInfo struct {
 	// ResolveAttrs is a set of attributes that should be merged after
 	// dependency resolution. See rule.Merge.
 	ResolveAttrs map[string]bool
+
+	// MergeStrategies maps names of attributes in MergeableAttrs or
+	// ResolveAttrs to strategies that change how they're merged. Attributes
+	// not in this map are merged with MergeReplace. See
+	// MergeRulesWithStrategies.
+	//
+	// Strategies are applied by MergeRulesWithStrategies,
+	// MergeRulesWithBaseAndStrategies, and the merger package. MergeList and
+	// MergeDict, which extensions may call directly, don't know about
+	// strategies and always merge with MergeReplace.
+	MergeStrategies map[string]MergeStrategy
+}
+
+// MergeStrategy describes how a generated attribute value is merged with
+// the value of the attribute in an existing rule.
+type MergeStrategy struct {
+	// Mode is used when Func is nil.
+	Mode MergeMode
+
+	// Func, if not nil, merges the values, for example, dicts like "env"
+	// or "x_defs" that aren't lists or select expressions. It's called with
+	// the generated and existing values, either of which may be nil, and
+	// returns the merged value, or nil to delete the attribute. Values
+	// marked with "# keep" are kept without calling Func.
+	Func MergeFunc
 }
+
+// MergeFunc merges src, a generated attribute value, with dst, the value in
+// an existing rule. See MergeStrategy.Func.
+type MergeFunc func(src, dst bzl.Expr) (bzl.Expr, error)
+
+// MergeMode is a built-in way to merge attribute values. In each mode,
+// values marked with "# keep" are kept.
+type MergeMode int
+
+const (
+	// MergeReplace replaces the existing value with the generated value,
+	// keeping strings in lists and select expressions marked with "# keep".
+	// If the attribute isn't generated, strings not marked with "# keep"
+	// are removed. This is the default.
+	MergeReplace MergeMode = iota
+
+	// MergeUnion combines the existing and generated values. Strings in
+	// lists and select expressions are kept, and generated strings are
+	// added. Scalars, like strings, can't be combined, so they're replaced
+	// by the generated value. If the attribute isn't generated, the existing
+	// value is kept.
+	MergeUnion
+
+	// MergeKeepExisting keeps the existing value if there is one. The
+	// generated value is only used when the attribute isn't set.
+	MergeKeepExisting
+
+	// MergeAppendOnly is like MergeUnion, but existing values are only
+	// added to: generated strings are added to lists and select
+	// expressions, and existing scalars are kept.
+	MergeAppendOnly
+)
@@ -0,0 +1,252 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testtools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/language/bridge"
+)
+
+// ServeFakeBridgePlugin serves a fake language named "fake" over the
+// protocol in the bridge package, reading requests from r and writing
+// responses to w. It returns when r is closed or a shutdown request is
+// handled. Tests may run it in a subprocess to exercise bridge.Language.
+//
+// The fake language generates a fake_library rule in each directory with
+// .fake files. Lines of the form "import x" in those files are imports.
+// A rule may be imported by its importpath attribute, which is the
+// directory's path prefixed by the value of the "fake_prefix" directive.
+func ServeFakeBridgePlugin(r io.Reader, w io.Writer) error {
+	p := &fakePlugin{prefixes: map[string]string{}}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+
+		var req bridge.Request
+		resp := bridge.Response{JSONRPC: "2.0"}
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = &bridge.Error{Code: bridge.CodeParseError, Message: err.Error()}
+		} else {
+			resp.ID = req.ID
+			result, rerr := p.handle(req.Method, req.Params)
+			if rerr != nil {
+				resp.Error = rerr
+			} else if resp.Result, err = json.Marshal(result); err != nil {
+				resp.Error = &bridge.Error{Code: bridge.CodeInternalError, Message: err.Error()}
+			}
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		if req.Method == bridge.MethodShutdown {
+			return nil
+		}
+	}
+}
+
+type fakePlugin struct {
+	// prefixes maps directories to the prefix set there or inherited.
+	prefixes map[string]string
+}
+
+func (p *fakePlugin) handle(method string, params json.RawMessage) (interface{}, *bridge.Error) {
+	decode := func(v interface{}) *bridge.Error {
+		if err := json.Unmarshal(params, v); err != nil {
+			return &bridge.Error{Code: bridge.CodeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch method {
+	case bridge.MethodInitialize:
+		var ip bridge.InitializeParams
+		if err := decode(&ip); err != nil {
+			return nil, err
+		}
+		if ip.ProtocolVersion != bridge.ProtocolVersion {
+			return nil, &bridge.Error{Code: bridge.CodeInvalidRequest, Message: fmt.Sprintf("unsupported protocol version %d", ip.ProtocolVersion)}
+		}
+		return bridge.InitializeResult{ProtocolVersion: bridge.ProtocolVersion, Name: "fake", Version: "1"}, nil
+
+	case bridge.MethodKnownDirectives:
+		return []string{"fake_prefix"}, nil
+
+	case bridge.MethodKinds:
+		return map[string]bridge.KindInfo{
+			"fake_library": {
+				MatchAny:        true,
+				NonEmptyAttrs:   []string{"deps", "srcs"},
+				MergeableAttrs:  []string{"srcs"},
+				ResolveAttrs:    []string{"deps"},
+				MergeStrategies: map[string]string{"srcs": "union"},
+			},
+		}, nil
+
+	case bridge.MethodLoads:
+		return []bridge.LoadInfo{{Name: "@fake//:def.bzl", Symbols: []string{"fake_library"}}}, nil
+
+	case bridge.MethodConfigure:
+		var cp bridge.ConfigureParams
+		if err := decode(&cp); err != nil {
+			return nil, err
+		}
+		prefix := ""
+		if cp.Rel != "" {
+			parent := path.Dir(cp.Rel)
+			if parent == "." {
+				parent = ""
+			}
+			prefix = p.prefixes[parent]
+		}
+		for _, d := range cp.Directives {
+			if d.Key == "fake_prefix" {
+				prefix = d.Value
+			}
+		}
+		p.prefixes[cp.Rel] = prefix
+		return nil, nil
+
+	case bridge.MethodGenerateRules:
+		var gp bridge.GenerateParams
+		if err := decode(&gp); err != nil {
+			return nil, err
+		}
+		return p.generate(gp)
+
+	case bridge.MethodImports:
+		var ip bridge.ImportsParams
+		if err := decode(&ip); err != nil {
+			return nil, err
+		}
+		if ip.Rule.Kind != "fake_library" {
+			return nil, nil
+		}
+		imp, _ := ip.Rule.Attrs["importpath"].(string)
+		if imp == "" {
+			return []bridge.ImportSpec{}, nil
+		}
+		return []bridge.ImportSpec{{Lang: "fake", Imp: imp}}, nil
+
+	case bridge.MethodEmbeds:
+		var ep bridge.EmbedsParams
+		if err := decode(&ep); err != nil {
+			return nil, err
+		}
+		embeds := []string{}
+		if list, ok := ep.Rule.Attrs["embed"].([]interface{}); ok {
+			for _, e := range list {
+				if s, ok := e.(string); ok {
+					embeds = append(embeds, s)
+				}
+			}
+		}
+		return embeds, nil
+
+	case bridge.MethodResolve:
+		var rp bridge.ResolveParams
+		if err := decode(&rp); err != nil {
+			return nil, err
+		}
+		var deps []string
+		for _, ri := range rp.Imports {
+			switch len(ri.Labels) {
+			case 0:
+				continue
+			case 1:
+				deps = append(deps, ri.Labels[0])
+			default:
+				return nil, &bridge.Error{Code: bridge.CodeInternalError, Message: fmt.Sprintf("import %q is provided by multiple rules: %s", ri.Imp, strings.Join(ri.Labels, ", "))}
+			}
+		}
+		if len(deps) == 0 {
+			return bridge.ResolveResult{Attrs: map[string]interface{}{"deps": nil}}, nil
+		}
+		sort.Strings(deps)
+		return bridge.ResolveResult{Attrs: map[string]interface{}{"deps": deps}}, nil
+
+	case bridge.MethodShutdown:
+		return nil, nil
+
+	default:
+		return nil, &bridge.Error{Code: bridge.CodeMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+	}
+}
+
+func (p *fakePlugin) generate(gp bridge.GenerateParams) (bridge.GenerateResult, *bridge.Error) {
+	name := path.Base(gp.Rel)
+	if gp.Rel == "" {
+		name = "root"
+	}
+
+	var srcs, imports []string
+	for _, f := range gp.RegularFiles {
+		if !strings.HasSuffix(f, ".fake") {
+			continue
+		}
+		srcs = append(srcs, f)
+		data, err := os.ReadFile(filepath.Join(gp.Dir, f))
+		if err != nil {
+			return bridge.GenerateResult{}, &bridge.Error{Code: bridge.CodeInternalError, Message: err.Error()}
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if imp, ok := strings.CutPrefix(strings.TrimSpace(line), "import "); ok {
+				imports = append(imports, strings.TrimSpace(imp))
+			}
+		}
+	}
+
+	var res bridge.GenerateResult
+	if len(srcs) == 0 {
+		for _, r := range gp.Rules {
+			if r.Kind == "fake_library" {
+				res.Empty = append(res.Empty, bridge.Rule{Kind: "fake_library", Name: r.Name})
+			}
+		}
+		return res, nil
+	}
+	sort.Strings(srcs)
+	res.Gen = []bridge.Rule{{
+		Kind: "fake_library",
+		Name: name,
+		Attrs: map[string]interface{}{
+			"srcs":       srcs,
+			"importpath": path.Join(p.prefixes[gp.Rel], gp.Rel),
+		},
+	}}
+	res.Imports = [][]string{imports}
+	if res.Imports[0] == nil {
+		res.Imports[0] = []string{}
+	}
+	return res, nil
+}
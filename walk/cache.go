@@ -5,6 +5,7 @@ import (
 	"path"
 	"sync"
 
+	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/pathtools"
 )
 
@@ -75,6 +76,56 @@ func (c *cache) getLoaded(rel string) (DirInfo, error) {
 	return ce.info, ce.err
 }
 
+// invalidate removes the entry for rel. If tree is true, entries for
+// descendants of rel are removed, too.
+func (c *cache) invalidate(rel string, tree bool) {
+	c.entryMap.Delete(rel)
+	if !tree {
+		return
+	}
+	c.entryMap.Range(func(key, _ any) bool {
+		if k := key.(string); pathtools.HasPrefix(k, rel) {
+			c.entryMap.Delete(k)
+		}
+		return true
+	})
+}
+
+// Cache holds directory information read by Walk2 so that later calls can
+// reuse it instead of reading the file system again. This is useful in
+// long-running processes that update build files repeatedly. Callers must
+// invalidate entries for directories that change between calls.
+//
+// A Cache must only be used with one root configuration at a time, and it
+// must not be used by more than one call to Walk2 at the same time.
+type Cache struct {
+	c *cache
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{c: new(cache)}
+}
+
+// Invalidate removes information about the directory rel, a slash-separated
+// path relative to the repository root. It should be called when a file in
+// rel is created, removed, or modified.
+//
+// If tree is true, information about subdirectories of rel is removed as
+// well. This is needed when rel's build file changes, since its directives
+// may affect how subdirectories are walked.
+func (c *Cache) Invalidate(rel string, tree bool) {
+	c.c.invalidate(rel, tree)
+}
+
+const walkCacheName = "_walkCache"
+
+// SetCache makes Walk2 use cache when walking with the root configuration c,
+// instead of a new, empty cache.
+func SetCache(c *config.Config, cache *Cache) {
+	c.Exts[walkCacheName] = cache
+}
+
 var globalWalker *walker
 
 func setGlobalWalker(w *walker) func() {
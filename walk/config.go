@@ -17,17 +17,21 @@ package walk
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
-	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 	bzl "github.com/bazelbuild/buildtools/build"
 	"github.com/bmatcuk/doublestar/v4"
@@ -47,6 +51,36 @@ const (
 	generationModeCreate generationModeType = "create_and_update"
 )
 
+// nestedReposMode controls how the walk treats repositories nested in the
+// repository being walked.
+type nestedReposMode string
+
+const (
+	// nestedReposPackage treats nested repositories as ordinary packages.
+	nestedReposPackage nestedReposMode = "package"
+
+	// nestedReposSkip stops the walk at the root of each nested repository.
+	nestedReposSkip nestedReposMode = "skip"
+
+	// nestedReposUpdate stops the walk at the root of each nested repository,
+	// and the caller updates each one as a separate repository.
+	nestedReposUpdate nestedReposMode = "update"
+)
+
+// checkGenerationMode checks the value of a generation_mode directive, which
+// is a mode, optionally preceded by a language name.
+func checkGenerationMode(value string) error {
+	fields := strings.Fields(value)
+	mode := fields[len(fields)-1]
+	modes := []string{string(generationModeCreate), string(generationModeUpdate)}
+	for _, m := range modes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %s", mode, strings.Join(modes, ", "))
+}
+
 // TODO(#472): store location information to validate each exclude. They
 // may be set in one directory and used in another. Excludes work on
 // declared generated files, so we can't just stat.
@@ -54,10 +88,30 @@ const (
 type walkConfig struct {
 	updateOnly          bool
 	ignoreFilter        *ignoreFilter
-	excludes            []string
 	ignore              bool
 	follow              []string
 	validBuildFileNames []string // to be copied to config.Config
+
+	// excludes holds patterns from exclude directives and the -exclude flag,
+	// joined with the directory they apply to. Patterns starting with "!"
+	// re-include paths matched by earlier patterns.
+	excludes []string
+
+	// gitignore is true if .gitignore files should be read. gitignorePatterns
+	// holds patterns from .gitignore files in this directory and its parents.
+	gitignore         bool
+	gitignorePatterns []gitignorePattern
+
+	// langUpdateOnly holds generation modes set for individual languages,
+	// overriding updateOnly. It's replaced, not modified, when written.
+	langUpdateOnly map[string]bool
+
+	// nestedRepos is set with -nested_repos and applies to the whole walk.
+	nestedRepos nestedReposMode
+
+	// repoConfig holds directives from gazelle.json. It applies to the whole
+	// walk and may be nil.
+	repoConfig *repoConfig
 }
 
 const (
@@ -76,15 +130,67 @@ func (wc *walkConfig) clone() *walkConfig {
 	// Other slices are either immutable or replaced when written.
 	wcCopy.excludes = wcCopy.excludes[:len(wcCopy.excludes):len(wcCopy.excludes)]
 	wcCopy.follow = wcCopy.follow[:len(wcCopy.follow):len(wcCopy.follow)]
+	wcCopy.gitignorePatterns = wcCopy.gitignorePatterns[:len(wcCopy.gitignorePatterns):len(wcCopy.gitignorePatterns)]
 	return &wcCopy
 }
 
+// containsDirsWithoutBuildFile returns whether files in a directory without a
+// build file should be treated as part of the nearest parent directory with
+// one. That's the case in update_only mode, unless some language creates
+// build files.
+func (wc *walkConfig) containsDirsWithoutBuildFile() bool {
+	if !wc.updateOnly {
+		return false
+	}
+	for _, updateOnly := range wc.langUpdateOnly {
+		if !updateOnly {
+			return false
+		}
+	}
+	return true
+}
+
 func (wc *walkConfig) isExcludedDir(p string) bool {
-	return path.Base(p) == ".git" || wc.ignoreFilter.isDirectoryIgnored(p) || matchAnyGlob(wc.excludes, p)
+	return path.Base(p) == ".git" || wc.ignoreFilter.isDirectoryIgnored(p) || matchExcludesDir(wc.excludes, p) || matchGitignore(wc.gitignorePatterns, p, true)
 }
 
 func (wc *walkConfig) isExcludedFile(p string) bool {
-	return wc.ignoreFilter.isFileIgnored(p) || matchAnyGlob(wc.excludes, p)
+	return wc.ignoreFilter.isFileIgnored(p) || matchExcludes(wc.excludes, p) || matchGitignore(wc.gitignorePatterns, p, false)
+}
+
+// isNestedRepo returns whether the directory rel, containing the given
+// entries, is the root of a nested repository where the walk should stop.
+func (wc *walkConfig) isNestedRepo(rel string, ents []fs.DirEntry) bool {
+	if rel == "" || wc.nestedRepos == nestedReposPackage {
+		return false
+	}
+	for _, e := range ents {
+		if !e.IsDir() && wspace.IsNestedRepoBoundaryFile(e.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateNestedRepos returns whether repositories nested in the repository
+// being walked should be updated as separate repositories. When it's true,
+// their roots are reported in Walk2FuncArgs.NestedRepos, and the caller should
+// walk each one with a configuration rooted there.
+func UpdateNestedRepos(c *config.Config) bool {
+	return getWalkConfig(c).nestedRepos == nestedReposUpdate
+}
+
+// IsExcluded returns whether the file or directory rel is excluded from the
+// walk by .bazelignore, .gazelleignore, .gitignore, or an exclude directive.
+// rel is a slash-separated path relative to the repository root. c must be
+// the configuration for rel's parent directory (or any ancestor), for
+// example, Walk2FuncArgs.Config.
+func IsExcluded(c *config.Config, rel string, isDir bool) bool {
+	wc := getWalkConfig(c)
+	if isDir {
+		return wc.isExcludedDir(rel)
+	}
+	return wc.isExcludedFile(rel)
 }
 
 func (wc *walkConfig) shouldFollow(p string) bool {
@@ -98,13 +204,21 @@ type Configurer struct {
 	// May be extending with BUILD directives.
 	cliExcludes       []string
 	cliBuildFileNames string
+	gitignore         bool
+	nestedRepos       string
 
 	// Alternate BUILD read/write directories
 	readBuildFilesDir, writeBuildFilesDir string
+
+	// repoRoot is the repository root when flags were first checked.
+	// cliExcludes are relative to it.
+	repoRoot string
 }
 
 func (cr *Configurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
-	fs.Var(&gzflag.MultiFlag{Values: &cr.cliExcludes}, "exclude", "pattern that should be ignored (may be repeated)")
+	fs.Var(&gzflag.MultiFlag{Values: &cr.cliExcludes}, "exclude", "pattern that should be ignored (may be repeated). Patterns starting with '!' re-include paths excluded by earlier patterns.")
+	fs.BoolVar(&cr.gitignore, "gitignore", false, "when true, files and directories ignored by .gitignore files are excluded. May be changed with the gitignore directive.")
+	fs.StringVar(&cr.nestedRepos, "nested_repos", string(nestedReposPackage), "how to treat directories containing MODULE.bazel or REPO.bazel files: 'package' treats them as ordinary packages, 'skip' doesn't visit them, and 'update' updates each one as a separate repository.")
 	fs.StringVar(&cr.cliBuildFileNames, "build_file_name", strings.Join(config.DefaultValidBuildFileNames, ","), "comma-separated list of valid build file names.\nThe first element of the list is the name of output build files to generate.")
 	fs.StringVar(&cr.readBuildFilesDir, "experimental_read_build_files_dir", "", "path to a directory where build files should be read from (instead of -repo_root)")
 	fs.StringVar(&cr.writeBuildFilesDir, "experimental_write_build_files_dir", "", "path to a directory where build files should be written to (instead of -repo_root)")
@@ -127,19 +241,88 @@ func (cr *Configurer) CheckFlags(_ *flag.FlagSet, c *config.Config) error {
 		}
 	}
 
-	ignoreFilter := newIgnoreFilter(c.RepoRoot)
+	nestedRepos := nestedReposMode(cr.nestedRepos)
+	switch nestedRepos {
+	case "":
+		nestedRepos = nestedReposPackage
+	case nestedReposPackage, nestedReposSkip, nestedReposUpdate:
+	default:
+		return fmt.Errorf("-nested_repos must be one of %s, %s, or %s; got %q", nestedReposPackage, nestedReposSkip, nestedReposUpdate, cr.nestedRepos)
+	}
+
+	ignoreFilter := newIgnoreFilter(c)
+
+	repoConfig, err := loadRepoConfig(c)
+	if err != nil {
+		log.Printf("error loading %s: %v", repoConfigFileName, err)
+	}
+
+	// Flags are checked again for each nested repository updated with
+	// -nested_repos=update. Exclude patterns are re-rooted there.
+	excludes := cr.cliExcludes
+	if cr.repoRoot == "" {
+		cr.repoRoot = c.RepoRoot
+	} else if rel, err := filepath.Rel(cr.repoRoot, c.RepoRoot); err == nil && rel != "." && filepath.IsLocal(rel) {
+		excludes = rerootExcludes(cr.cliExcludes, filepath.ToSlash(rel))
+	}
 
 	wc := &walkConfig{
 		ignoreFilter:        ignoreFilter,
-		excludes:            cr.cliExcludes,
+		excludes:            excludes,
 		validBuildFileNames: c.ValidBuildFileNames,
+		gitignore:           cr.gitignore,
+		nestedRepos:         nestedRepos,
+		repoConfig:          repoConfig,
 	}
 	c.Exts[walkName] = wc
 	return nil
 }
 
 func (*Configurer) KnownDirectives() []string {
-	return []string{"build_file_name", "generation_mode", "exclude", "follow", "ignore"}
+	return []string{"build_file_name", "generation_mode", "exclude", "follow", "gitignore", "ignore"}
+}
+
+var _ config.DirectiveSchemaProvider = (*Configurer)(nil)
+
+func (*Configurer) DirectiveSchemas() []config.DirectiveSchema {
+	return []config.DirectiveSchema{
+		{
+			Key:   "build_file_name",
+			Usage: "name1,name2,...",
+			Doc:   "Names of files Gazelle recognizes as build files. New files use the first name.",
+			Type:  config.ArgList,
+		},
+		{
+			Key:   "exclude",
+			Usage: "[!]pattern",
+			Doc:   "Prevents Gazelle from processing files and directories matching the pattern, relative to this directory. A pattern starting with \"!\" re-includes paths excluded by earlier patterns; escape a literal \"!\" as \"\\!\". May be repeated.",
+		},
+		{
+			Key:   "follow",
+			Usage: "pattern",
+			Doc:   "Follows symbolic links to directories matching the pattern, relative to this directory. May be repeated.",
+		},
+		{
+			Key:     "generation_mode",
+			Usage:   "[lang] create_and_update|update_only",
+			Doc:     "Whether Gazelle creates new build files or only updates existing ones. If a language is named, the mode applies only to that language.",
+			Type:    config.ArgFields,
+			MinArgs: 1,
+			MaxArgs: 2,
+			Check:   checkGenerationMode,
+		},
+		{
+			Key:   "gitignore",
+			Usage: "true|false",
+			Doc:   "Whether files and directories ignored by .gitignore files in this directory and its parents are excluded. False by default.",
+			Type:  config.ArgBool,
+		},
+		{
+			Key:  "ignore",
+			Doc:  "Prevents Gazelle from modifying this build file. Use exclude to skip other files.",
+			Type: config.ArgNone,
+		},
+	}
 }
 
 func (cr *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
@@ -155,14 +338,54 @@ func (cr *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 	} else {
 		// In some unit tests, c.Exts[walkNameCached] is not set.
 		// Process directives normally using the same code.
-		c.Exts[walkName] = configureForWalk(getWalkConfig(c), rel, f)
+		c.Exts[walkName] = configureForWalk(c, getWalkConfig(c), rel, f)
 	}
 	c.ValidBuildFileNames = getWalkConfig(c).validBuildFileNames
 }
 
-func configureForWalk(parent *walkConfig, rel string, f *rule.File) *walkConfig {
+var _ config.Describer = (*Configurer)(nil)
+
+func (*Configurer) DescribeConfig(c *config.Config) []config.Setting {
+	wc := getWalkConfig(c)
+	mode := generationModeCreate
+	if wc.updateOnly {
+		mode = generationModeUpdate
+	}
+	settings := []config.Setting{
+		{Directive: "build_file_name", Value: strings.Join(wc.validBuildFileNames, ","), Flag: "build_file_name"},
+		{Directive: "generation_mode", Value: string(mode)},
+	}
+	langs := make([]string, 0, len(wc.langUpdateOnly))
+	for lang := range wc.langUpdateOnly {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		mode := generationModeCreate
+		if wc.langUpdateOnly[lang] {
+			mode = generationModeUpdate
+		}
+		settings = append(settings, config.Setting{Directive: "generation_mode", Value: lang + " " + string(mode)})
+	}
+	settings = append(settings, config.Setting{Directive: "gitignore", Value: strconv.FormatBool(wc.gitignore), Flag: "gitignore"})
+	for _, e := range wc.excludes {
+		settings = append(settings, config.Setting{Directive: "exclude", Value: e, Flag: "exclude"})
+	}
+	for _, f := range wc.follow {
+		settings = append(settings, config.Setting{Directive: "follow", Value: f})
+	}
+	if wc.ignore {
+		settings = append(settings, config.Setting{Directive: "ignore"})
+	}
+	return settings
+}
+
+// configureForWalk applies directives in f to a copy of parent. c is only
+// used to report diagnostics.
+func configureForWalk(c *config.Config, parent *walkConfig, rel string, f *rule.File) *walkConfig {
 	wc := parent.clone()
 	wc.ignore = false
+	gitignore := wc.gitignore
 
 	if f != nil {
 		for _, d := range f.Directives {
@@ -170,58 +393,107 @@ func configureForWalk(parent *walkConfig, rel string, f *rule.File) *walkConfig
 			case "build_file_name":
 				wc.validBuildFileNames = strings.Split(d.Value, ",")
 			case "generation_mode":
-				switch generationModeType(strings.TrimSpace(d.Value)) {
+				fields := strings.Fields(d.Value)
+				if len(fields) == 0 || len(fields) > 2 {
+					c.ReportDirectivef(config.SeverityError, "invalid-directive", f, d, "invalid directive gazelle:generation_mode: expected a mode, optionally preceded by a language name; got %q", d.Value)
+					continue
+				}
+				var updateOnly bool
+				switch generationModeType(fields[len(fields)-1]) {
 				case generationModeUpdate:
-					wc.updateOnly = true
+					updateOnly = true
 				case generationModeCreate:
-					wc.updateOnly = false
+					updateOnly = false
 				default:
 					log.Fatalf("unknown generation_mode %q in //%s", d.Value, f.Pkg)
 					continue
 				}
+				if len(fields) == 1 {
+					wc.updateOnly = updateOnly
+					continue
+				}
+				langUpdateOnly := make(map[string]bool, len(wc.langUpdateOnly)+1)
+				for lang, u := range wc.langUpdateOnly {
+					langUpdateOnly[lang] = u
+				}
+				langUpdateOnly[fields[0]] = updateOnly
+				wc.langUpdateOnly = langUpdateOnly
 			case "exclude":
-				if err := checkPathMatchPattern(path.Join(rel, d.Value)); err != nil {
-					log.Printf("the exclusion pattern is not valid %q: %s", path.Join(rel, d.Value), err)
+				pattern, negate := strings.CutPrefix(d.Value, "!")
+				pattern = path.Join(rel, pattern)
+				if err := checkPathMatchPattern(pattern); err != nil {
+					c.ReportDirectivef(config.SeverityWarning, "exclude-pattern", f, d, "the exclusion pattern is not valid %q: %s", pattern, err)
 					continue
 				}
-				wc.excludes = append(wc.excludes, path.Join(rel, d.Value))
+				if negate {
+					pattern = "!" + pattern
+				}
+				wc.excludes = append(wc.excludes, pattern)
 			case "follow":
 				if err := checkPathMatchPattern(path.Join(rel, d.Value)); err != nil {
-					log.Printf("the follow pattern is not valid %q: %s", path.Join(rel, d.Value), err)
+					c.ReportDirectivef(config.SeverityWarning, "follow-pattern", f, d, "the follow pattern is not valid %q: %s", path.Join(rel, d.Value), err)
 					continue
 				}
 				wc.follow = append(wc.follow, path.Join(rel, d.Value))
 			case "ignore":
 				if d.Value != "" {
-					log.Printf("the ignore directive does not take any arguments. Did you mean to use gazelle:exclude instead? in //%s '# gazelle:ignore %s'", f.Pkg, d.Value)
+					c.ReportDirectivef(config.SeverityWarning, "ignore-args", f, d, "the ignore directive does not take any arguments. Did you mean to use gazelle:exclude instead? '# gazelle:ignore %s'", d.Value)
 				}
 				wc.ignore = true
+			case "gitignore":
+				wc.gitignore, _ = strconv.ParseBool(strings.TrimSpace(d.Value))
 			}
 		}
 	}
 
+	if wc.gitignore {
+		if !gitignore {
+			// Enabled in this directory. Patterns from parent directories
+			// apply, too.
+			pathtools.Prefixes(rel)(func(prefix string) bool {
+				if prefix != rel {
+					wc.gitignorePatterns = append(wc.gitignorePatterns, loadGitignore(c, prefix)...)
+				}
+				return true
+			})
+		}
+		wc.gitignorePatterns = append(wc.gitignorePatterns, loadGitignore(c, rel)...)
+	} else {
+		wc.gitignorePatterns = nil
+	}
+
 	return wc
 }
 
 type ignoreFilter struct {
 	ignoreDirectoryGlobs []string
 	ignorePaths          map[string]struct{}
+
+	// ignorePatterns holds patterns from .gazelleignore, in the same form
+	// as walkConfig.excludes.
+	ignorePatterns []string
 }
 
-func newIgnoreFilter(repoRoot string) *ignoreFilter {
-	bazelignorePaths, err := loadBazelIgnore(repoRoot)
+func newIgnoreFilter(c *config.Config) *ignoreFilter {
+	bazelignorePaths, err := loadBazelIgnore(c)
 	if err != nil {
 		log.Printf("error loading .bazelignore: %v", err)
 	}
 
-	repoDirectoryIgnores, err := loadRepoDirectoryIgnore(repoRoot)
+	repoDirectoryIgnores, err := loadRepoDirectoryIgnore(c)
 	if err != nil {
 		log.Printf("error loading REPO.bazel ignore_directories(): %v", err)
 	}
 
+	gazelleignorePatterns, err := loadGazelleIgnore(c)
+	if err != nil {
+		log.Printf("error loading .gazelleignore: %v", err)
+	}
+
 	return &ignoreFilter{
 		ignorePaths:          bazelignorePaths,
 		ignoreDirectoryGlobs: repoDirectoryIgnores,
+		ignorePatterns:       gazelleignorePatterns,
 	}
 }
 
@@ -229,28 +501,29 @@ func (f *ignoreFilter) isDirectoryIgnored(p string) bool {
 	if _, ok := f.ignorePaths[p]; ok {
 		return true
 	}
-	return matchAnyGlob(f.ignoreDirectoryGlobs, p)
+	return matchAnyGlob(f.ignoreDirectoryGlobs, p) || matchExcludesDir(f.ignorePatterns, p)
 }
 
 func (f *ignoreFilter) isFileIgnored(p string) bool {
-	_, ok := f.ignorePaths[p]
-	return ok
+	if _, ok := f.ignorePaths[p]; ok {
+		return true
+	}
+	return matchExcludes(f.ignorePatterns, p)
 }
 
-func loadBazelIgnore(repoRoot string) (map[string]struct{}, error) {
-	ignorePath := path.Join(repoRoot, ".bazelignore")
-	file, err := os.Open(ignorePath)
+func loadBazelIgnore(c *config.Config) (map[string]struct{}, error) {
+	ignorePath := filepath.Join(c.RepoRoot, ".bazelignore")
+	content, err := c.ReadFile(ignorePath)
 	if errors.Is(err, fs.ErrNotExist) {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf(".bazelignore exists but couldn't be read: %v", err)
 	}
-	defer file.Close()
 
 	excludes := make(map[string]struct{})
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
 	for scanner.Scan() {
 		ignore := strings.TrimSpace(scanner.Text())
 		if ignore == "" || string(ignore[0]) == "#" {
@@ -273,9 +546,62 @@ func loadBazelIgnore(repoRoot string) (map[string]struct{}, error) {
 	return excludes, nil
 }
 
-func loadRepoDirectoryIgnore(repoRoot string) ([]string, error) {
-	repoFilePath := path.Join(repoRoot, "REPO.bazel")
-	repoFileContent, err := os.ReadFile(repoFilePath)
+// loadGazelleIgnore reads patterns from .gazelleignore in the repository
+// root. Unlike .bazelignore, each line is a doublestar pattern relative to
+// the repository root that may match files or directories. Lines starting
+// with "#" are comments, patterns starting with "!" re-include paths
+// matched by earlier patterns, and patterns ending with "/" only match
+// directories. Invalid patterns are reported and skipped.
+func loadGazelleIgnore(c *config.Config) ([]string, error) {
+	ignorePath := filepath.Join(c.RepoRoot, ".gazelleignore")
+	content, err := c.ReadFile(ignorePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(".gazelleignore exists but couldn't be read: %v", err)
+	}
+
+	var patterns []string
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		pattern, negate := strings.CutPrefix(line, "!")
+		dirOnly := strings.HasSuffix(pattern, "/")
+		// Clean the pattern, so "./a" matches "a".
+		pattern = path.Clean(pattern)
+		var err error
+		if pattern == "." || pattern == ".." || strings.HasPrefix(pattern, "../") || path.IsAbs(pattern) {
+			err = errors.New("pattern must be relative to the repository root")
+		} else {
+			err = checkPathMatchPattern(pattern)
+		}
+		if err != nil {
+			c.Report(config.Diagnostic{
+				Severity: config.SeverityWarning,
+				Code:     "gazelleignore-pattern",
+				File:     ".gazelleignore",
+				Line:     i + 1,
+				Message:  fmt.Sprintf("the pattern %q is not valid: %v", line, err),
+			})
+			continue
+		}
+		if dirOnly {
+			pattern += "/"
+		}
+		if negate {
+			pattern = "!" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func loadRepoDirectoryIgnore(c *config.Config) ([]string, error) {
+	repoFilePath := filepath.Join(c.RepoRoot, "REPO.bazel")
+	repoFileContent, err := c.ReadFile(repoFilePath)
 	if errors.Is(err, fs.ErrNotExist) {
 		return nil, nil
 	}
@@ -283,7 +609,7 @@ func loadRepoDirectoryIgnore(repoRoot string) ([]string, error) {
 		return nil, fmt.Errorf("REPO.bazel exists but couldn't be read: %v", err)
 	}
 
-	ast, err := bzl.Parse(repoRoot, repoFileContent)
+	ast, err := bzl.Parse(repoFilePath, repoFileContent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse REPO.bazel: %v", err)
 	}
@@ -328,6 +654,134 @@ func checkPathMatchPattern(pattern string) error {
 	return err
 }
 
+// matchExcludes returns whether the file p, a slash-separated path relative
+// to the repository root, is excluded by patterns. Patterns starting with "!"
+// re-include paths matched by earlier patterns; a literal "!" at the start of
+// a pattern must be escaped as "\!". Patterns are matched against p and each
+// of its parent directories, and as in .gitignore files, the last matching
+// pattern decides. So excluding a directory excludes everything inside it,
+// unless a later pattern re-includes some of it.
+func matchExcludes(patterns []string, p string) bool {
+	_, excluded := lastExclude(patterns, p, false)
+	return excluded
+}
+
+// matchExcludesDir is like matchExcludes for the directory p. A directory
+// that's excluded is still walked if a later pattern starting with "!" may
+// re-include something inside it; only the paths that remain included are
+// processed.
+func matchExcludesDir(patterns []string, p string) bool {
+	i, excluded := lastExclude(patterns, p, true)
+	if !excluded {
+		return false
+	}
+	for _, x := range patterns[i+1:] {
+		if pattern, negate := strings.CutPrefix(x, "!"); negate && mayMatchInside(strings.TrimSuffix(pattern, "/"), p) {
+			return false
+		}
+	}
+	return true
+}
+
+// lastExclude returns whether p is excluded by patterns and, if so, the
+// index of the last pattern that matches p or one of its parent
+// directories. isDir is whether p is a directory. Patterns ending with "/"
+// only match directories.
+func lastExclude(patterns []string, p string, isDir bool) (int, bool) {
+	last := -1
+	for i, x := range patterns {
+		pattern, dirOnly := strings.CutSuffix(strings.TrimPrefix(x, "!"), "/")
+		for q := p; ; q = path.Dir(q) {
+			if (q != p || isDir || !dirOnly) && doublestar.MatchUnvalidated(pattern, q) {
+				last = i
+				break
+			}
+			if !strings.Contains(q, "/") {
+				break
+			}
+		}
+	}
+	if last < 0 || strings.HasPrefix(patterns[last], "!") {
+		return -1, false
+	}
+	return last, true
+}
+
+// mayMatchInside returns whether pattern may match a path inside the
+// directory dir. It compares pattern with dir one component at a time, so
+// it may return true for patterns that don't match anything.
+func mayMatchInside(pattern, dir string) bool {
+	if dir == "" {
+		return true
+	}
+	patternParts := strings.Split(pattern, "/")
+	dirParts := strings.Split(dir, "/")
+	for i, part := range dirParts {
+		if i >= len(patternParts) {
+			return false
+		}
+		if patternParts[i] == "**" {
+			return true
+		}
+		if !doublestar.MatchUnvalidated(patternParts[i], part) {
+			return false
+		}
+	}
+	return len(patternParts) > len(dirParts)
+}
+
+// rerootExcludes returns exclude patterns for the directory rel, given
+// patterns relative to its parent repository root. Patterns are compared
+// with rel one component at a time, like mayMatchInside. Patterns that
+// can't match anything inside rel are dropped, and patterns that match rel
+// itself or one of its parents match everything inside it.
+func rerootExcludes(patterns []string, rel string) []string {
+	relParts := strings.Split(rel, "/")
+	var rerooted []string
+	for _, x := range patterns {
+		pattern, negate := strings.CutPrefix(x, "!")
+		pattern, dirOnly := strings.CutSuffix(pattern, "/")
+		patternParts := strings.Split(pattern, "/")
+		var rest []string
+		matched := true
+		for i, part := range relParts {
+			if i >= len(patternParts) {
+				// The pattern matches a parent of rel.
+				rest = []string{"**"}
+				dirOnly = false
+				break
+			}
+			if patternParts[i] == "**" {
+				rest = patternParts[i:]
+				break
+			}
+			if !doublestar.MatchUnvalidated(patternParts[i], part) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if rest == nil {
+			rest = patternParts[len(relParts):]
+			if len(rest) == 0 {
+				rest = []string{"**"}
+				dirOnly = false
+			}
+		}
+		pattern = strings.Join(rest, "/")
+		if dirOnly {
+			pattern += "/"
+		}
+		if negate {
+			pattern = "!" + pattern
+		}
+		rerooted = append(rerooted, pattern)
+	}
+	return rerooted
+}
+
 func matchAnyGlob(patterns []string, path string) bool {
 	for _, x := range patterns {
 		if doublestar.MatchUnvalidated(x, path) {
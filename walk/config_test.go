@@ -31,6 +31,85 @@ func TestCheckPathMatchPattern(t *testing.T) {
 	}
 }
 
+func TestMatchExcludes(t *testing.T) {
+	patterns := []string{"tp/**", "!tp/fork/**", "tp/fork/testdata", "!tp/*.md"}
+	for _, tc := range []struct {
+		p     string
+		isDir bool
+		want  bool
+	}{
+		{p: "a.go", want: false},
+		{p: "tp/a.go", want: true},
+		{p: "tp/README.md", want: false},
+		{p: "tp/fork/a.go", want: false},
+		{p: "tp/fork/testdata", isDir: true, want: true},
+		{p: "tp/other", isDir: true, want: true},
+		// tp is walked, since paths inside it are re-included.
+		{p: "tp", isDir: true, want: false},
+		{p: "tp/fork", isDir: true, want: false},
+	} {
+		got := matchExcludes(patterns, tc.p)
+		if tc.isDir {
+			got = matchExcludesDir(patterns, tc.p)
+		}
+		if got != tc.want {
+			t.Errorf("%s (dir %v): got %v; want %v", tc.p, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestRerootExcludes(t *testing.T) {
+	patterns := []string{
+		"nested/gen/**",
+		"!nested/gen/keep.go",
+		"other/**",
+		"**/*.pb.go",
+		"*/testdata/",
+		"nes*/docs",
+		"third_party",
+		"nested",
+		"!nested/",
+	}
+	got := rerootExcludes(patterns, "nested")
+	want := []string{
+		"gen/**",
+		"!gen/keep.go",
+		"**/*.pb.go",
+		"testdata/",
+		"docs",
+		"**",
+		"!**",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	got = rerootExcludes([]string{"third_party", "third_party/*/gen", "!third_party/a/**"}, "third_party/a")
+	want = []string{"**", "gen", "!**"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestGenerationModeFieldCount(t *testing.T) {
+	c := config.New()
+	var diags []config.Diagnostic
+	c.Diagnostics = diagnosticRecorder(func(d config.Diagnostic) { diags = append(diags, d) })
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(`# gazelle:generation_mode
+# gazelle:generation_mode go proto update_only
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc := configureForWalk(c, &walkConfig{}, "", f)
+	if wc.updateOnly || len(wc.langUpdateOnly) > 0 {
+		t.Errorf("invalid generation_mode directives were applied")
+	}
+	if len(diags) != 2 {
+		t.Errorf("got %d diagnostics; want 2: %v", len(diags), diags)
+	}
+}
+
 func TestConfigurerFlags(t *testing.T) {
 	dir, err := os.MkdirTemp(os.Getenv("TEST_TEMPDIR"), "config_test")
 	if err != nil {
@@ -0,0 +1,84 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walk
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+)
+
+// dirCacheNamespace is the namespace for directory listings in
+// config.FileCache.
+const dirCacheNamespace = "walk.dir.v1"
+
+// dirCacheEntry is a directory entry stored in config.FileCache.
+type dirCacheEntry struct {
+	Name string      `json:"name"`
+	Type fs.FileMode `json:"type"`
+}
+
+// readDir lists the directory dir, which is rel relative to the repository
+// root. If c has a file cache, the listing from an earlier run is used when
+// the directory's modification time hasn't changed, since adding, removing,
+// or renaming an entry changes it.
+func readDir(c *config.Config, dir, rel string) ([]fs.DirEntry, error) {
+	if c.FileCache == nil {
+		return c.ReadDir(dir)
+	}
+	fi, err := c.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cached []dirCacheEntry
+	if c.FileCache.Get(dirCacheNamespace, rel, fi, nil, &cached) {
+		ents := make([]fs.DirEntry, len(cached))
+		for i, e := range cached {
+			ents[i] = cachedDirEntry{c: c, dir: dir, e: e}
+		}
+		return ents, nil
+	}
+
+	ents, err := c.ReadDir(dir)
+	if err != nil {
+		return ents, err
+	}
+	cached = make([]dirCacheEntry, len(ents))
+	for i, ent := range ents {
+		cached[i] = dirCacheEntry{Name: ent.Name(), Type: ent.Type()}
+	}
+	c.FileCache.Put(dirCacheNamespace, rel, fi, nil, cached)
+	return ents, nil
+}
+
+// cachedDirEntry implements fs.DirEntry for an entry listed in
+// config.FileCache.
+type cachedDirEntry struct {
+	c   *config.Config
+	dir string
+	e   dirCacheEntry
+}
+
+func (d cachedDirEntry) Name() string      { return d.e.Name }
+func (d cachedDirEntry) IsDir() bool       { return d.e.Type.IsDir() }
+func (d cachedDirEntry) Type() fs.FileMode { return d.e.Type }
+
+// Info returns information about the entry. Unlike os.DirEntry, it follows
+// symbolic links, since config.Config doesn't provide Lstat.
+func (d cachedDirEntry) Info() (fs.FileInfo, error) {
+	return d.c.Stat(filepath.Join(d.dir, d.e.Name))
+}
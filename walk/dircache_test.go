@@ -0,0 +1,87 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadDirCache(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "a/x.go"},
+		{Path: "a/sub/"},
+	})
+	defer cleanup()
+	aDir := filepath.Join(dir, "a")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(aDir, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	c, cexts := testConfig(t, dir)
+	fc, err := config.LoadFileCache(filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.FileCache = fc
+	walkA := func() (files, subdirs []string) {
+		err := Walk2(c, cexts, []string{aDir}, UpdateDirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+			if args.Rel == "a" {
+				files, subdirs = args.RegularFiles, args.Subdirs
+			}
+			return Walk2FuncResult{}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files, subdirs
+	}
+	check := func(wantFiles, wantSubdirs []string) {
+		t.Helper()
+		files, subdirs := walkA()
+		if diff := cmp.Diff(wantFiles, files); diff != "" {
+			t.Errorf("files (-want,+got):\n%s", diff)
+		}
+		if diff := cmp.Diff(wantSubdirs, subdirs); diff != "" {
+			t.Errorf("subdirs (-want,+got):\n%s", diff)
+		}
+	}
+	check([]string{"x.go"}, []string{"sub"})
+
+	// While the directory's modification time is unchanged, the cached listing
+	// is used. Restore the time after adding a file to observe this.
+	if err := os.WriteFile(filepath.Join(aDir, "y.go"), nil, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(aDir, old, old); err != nil {
+		t.Fatal(err)
+	}
+	check([]string{"x.go"}, []string{"sub"})
+
+	// Once it changes, the directory is read again.
+	if err := os.Chtimes(aDir, old.Add(time.Second), old.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	check([]string{"x.go", "y.go"}, []string{"sub"})
+}
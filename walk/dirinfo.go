@@ -2,7 +2,6 @@ package walk
 
 import (
 	"errors"
-	"os"
 	"path"
 	"path/filepath"
 	"sync"
@@ -27,6 +26,24 @@ type DirInfo struct {
 	// before Configure is called to parallelize directory traversal without
 	// visiting excluded subdirectories.
 	config *walkConfig
+
+	// nestedRepo is true if the directory is the root of a nested repository
+	// that the walk doesn't enter.
+	nestedRepo bool
+
+	// repoConfigFile holds directives from gazelle.json if the directory has
+	// no build file. It's not a real file.
+	repoConfigFile *rule.File
+}
+
+// configFile returns the file with directives that configure the directory:
+// the build file, or a file with directives from gazelle.json if there's no
+// build file. It may be nil.
+func (info DirInfo) configFile() *rule.File {
+	if info.File != nil {
+		return info.File
+	}
+	return info.repoConfigFile
 }
 
 // loadDirInfo reads directory info for the directory named by the given
@@ -46,7 +63,7 @@ func (w *walker) loadDirInfo(rel string) (DirInfo, error) {
 	var errs []error
 	var err error
 	dir := filepath.Join(w.rootConfig.RepoRoot, rel)
-	entries, err := os.ReadDir(dir)
+	entries, err := readDir(w.rootConfig, dir, rel)
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -63,12 +80,42 @@ func (w *walker) loadDirInfo(rel string) (DirInfo, error) {
 		parentConfig = parentInfo.config
 	}
 
-	info.File, err = loadBuildFile(parentConfig, w.rootConfig.ReadBuildFilesDir, rel, dir, entries)
+	if parentConfig.isNestedRepo(rel, entries) {
+		// The directory is the root of another repository, so its contents
+		// don't belong to this one.
+		info.nestedRepo = true
+		info.config = parentConfig
+		return info, errors.Join(errs...)
+	}
+
+	info.File, err = loadBuildFile(w.rootConfig, parentConfig, rel, dir, entries)
 	if err != nil {
 		errs = append(errs, err)
 	}
 
-	info.config = configureForWalk(parentConfig, rel, info.File)
+	// Directives from gazelle.json apply before the build file's own
+	// directives. Directories without a build file get a file that only
+	// holds directives, used for configuration.
+	if ds, lines := parentConfig.repoConfig.directives(rel); len(ds) > 0 {
+		f := info.File
+		if f != nil {
+			f.Directives = append(ds, f.Directives...)
+		} else {
+			f = rule.EmptyFile(filepath.Join(w.rootConfig.RepoRoot, repoConfigFileName), rel)
+			f.Directives = ds
+			info.repoConfigFile = f
+		}
+		// Record positions in reverse, so repeated directives have the
+		// position of the first.
+		for i := len(ds) - 1; i >= 0; i-- {
+			f.SetDirectivePosition(ds[i], rule.DirectivePosition{File: repoConfigFileName, Line: lines[i]})
+		}
+	}
+	if err := w.checkDirectives(info.configFile()); err != nil {
+		errs = append(errs, err)
+	}
+
+	info.config = configureForWalk(w.rootConfig, parentConfig, rel, info.configFile())
 	if info.config.isExcludedDir(rel) {
 		// Build file excludes the current directory. Ignore contents.
 		entries = nil
@@ -76,7 +123,7 @@ func (w *walker) loadDirInfo(rel string) (DirInfo, error) {
 
 	for _, e := range entries {
 		entryRel := path.Join(rel, e.Name())
-		e = maybeResolveSymlink(info.config, dir, entryRel, e)
+		e = maybeResolveSymlink(w.rootConfig, info.config, dir, entryRel, e)
 		if e.IsDir() && !info.config.isExcludedDir(entryRel) {
 			info.Subdirs = append(info.Subdirs, e.Name())
 		} else if !e.IsDir() && !info.config.isExcludedFile(entryRel) {
@@ -0,0 +1,146 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walk
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// gitignorePattern is a pattern from a .gitignore file, converted to a
+// doublestar glob relative to the repository root.
+type gitignorePattern struct {
+	glob    string
+	negate  bool
+	dirOnly bool
+}
+
+// parseGitignore parses the content of the .gitignore file in the directory
+// rel. It follows the pattern format described in gitignore(5): blank lines
+// and lines starting with "#" are skipped, "!" negates a pattern, a trailing
+// "/" matches only directories, and a pattern with a "/" at the beginning or
+// in the middle is relative to rel. Other patterns match at any depth below
+// rel. Invalid patterns are skipped.
+func parseGitignore(rel string, content []byte) []gitignorePattern {
+	var patterns []gitignorePattern
+	base := escapeGlob(rel)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = trimGitignoreSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var p gitignorePattern
+		if line[0] == '!' {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// gitignore has no brace expansion, but doublestar does.
+		line = strings.NewReplacer("{", `\{`, "}", `\}`).Replace(line)
+		if strings.Contains(line, "/") {
+			p.glob = path.Join(base, strings.TrimPrefix(line, "/"))
+		} else {
+			p.glob = path.Join(base, "**", line)
+		}
+		if strings.HasSuffix(p.glob, "/**") {
+			// In git, a trailing "/**" matches everything inside a directory,
+			// but not the directory itself.
+			p.glob += "/*"
+		}
+		if checkPathMatchPattern(p.glob) != nil {
+			continue
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// trimGitignoreSpace removes trailing spaces from line, unless they're
+// escaped with a backslash.
+func trimGitignoreSpace(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// escapeGlob escapes characters in p that have a special meaning in
+// doublestar patterns.
+func escapeGlob(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// matchGitignore returns whether p, a slash-separated path relative to the
+// repository root, is ignored by patterns. As in git, the last matching
+// pattern decides, so later patterns and patterns from deeper .gitignore
+// files take precedence.
+func matchGitignore(patterns []gitignorePattern, p string, isDir bool) bool {
+	ignored := false
+	for _, gp := range patterns {
+		if gp.negate != ignored || (gp.dirOnly && !isDir) {
+			// This pattern can't change the result.
+			continue
+		}
+		if doublestar.MatchUnvalidated(gp.glob, p) {
+			ignored = !gp.negate
+		}
+	}
+	return ignored
+}
+
+// loadGitignore reads and parses the .gitignore file in the directory rel,
+// if there is one. Errors other than a missing file are reported as
+// diagnostics on c.
+func loadGitignore(c *config.Config, rel string) []gitignorePattern {
+	name := path.Join(rel, ".gitignore")
+	content, err := c.ReadFile(filepath.Join(c.RepoRoot, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		c.Report(config.Diagnostic{
+			Severity: config.SeverityWarning,
+			Code:     "gitignore-read",
+			File:     name,
+			Message:  err.Error(),
+		})
+		return nil
+	}
+	return parseGitignore(rel, content)
+}
@@ -0,0 +1,132 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walk
+
+import (
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchGitignore(t *testing.T) {
+	for _, tc := range []struct {
+		desc, rel, content, path string
+		isDir, want              bool
+	}{
+		{desc: "name", content: "foo", path: "a/b/foo", want: true},
+		{desc: "name_dir", content: "foo", path: "foo", isDir: true, want: true},
+		{desc: "name_other", content: "foo", path: "foobar", want: false},
+		{desc: "comment", content: "# foo", path: "# foo", want: false},
+		{desc: "escaped_comment", content: `\#foo`, path: "#foo", want: true},
+		{desc: "star", content: "*.log", path: "a/x.log", want: true},
+		{desc: "star_no_slash", content: "a*b", path: "ax/yb", want: false},
+		{desc: "anchored", content: "/foo", path: "a/foo", want: false},
+		{desc: "anchored_root", content: "/foo", path: "foo", want: true},
+		{desc: "middle_slash", content: "a/foo", path: "b/a/foo", want: false},
+		{desc: "dir_only_file", content: "build/", path: "build", want: false},
+		{desc: "dir_only_dir", content: "build/", path: "x/build", isDir: true, want: true},
+		{desc: "negate", content: "*.log\n!keep.log", path: "keep.log", want: false},
+		{desc: "negate_order", content: "!keep.log\n*.log", path: "keep.log", want: true},
+		{desc: "escaped_bang", content: `\!x`, path: "!x", want: true},
+		{desc: "leading_double_star", content: "**/foo", path: "a/b/foo", want: true},
+		{desc: "trailing_double_star", content: "foo/**", path: "foo/a/b", want: true},
+		{desc: "trailing_double_star_self", content: "foo/**", path: "foo", isDir: true, want: false},
+		{desc: "middle_double_star", content: "a/**/b", path: "a/b", want: true},
+		{desc: "trailing_space", content: "foo  ", path: "foo", want: true},
+		{desc: "braces", content: "{a,b}", path: "a", want: false},
+		{desc: "nested", rel: "sub", content: "foo", path: "sub/x/foo", want: true},
+		{desc: "nested_outside", rel: "sub", content: "foo", path: "other/foo", want: false},
+		{desc: "nested_anchored", rel: "sub", content: "/foo", path: "sub/foo", want: true},
+		{desc: "nested_escaped", rel: "s[1]", content: "/foo", path: "s[1]/foo", want: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			patterns := parseGitignore(tc.rel, []byte(tc.content))
+			if got := matchGitignore(patterns, tc.path, tc.isDir); got != tc.want {
+				t.Errorf("got %v; want %v (patterns %#v)", got, tc.want, patterns)
+			}
+		})
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: ".gitignore", Content: "node_modules/\n*.log\n/out\n"},
+		{Path: "a.go"},
+		{Path: "a.log"},
+		{Path: "out/x.go"},
+		{Path: "node_modules/m/m.js"},
+		{Path: "sub/.gitignore", Content: "!keep.log\nlocal\n"},
+		{Path: "sub/keep.log"},
+		{Path: "sub/drop.log"},
+		{Path: "sub/local/y.go"},
+		{Path: "sub/out/z.go"},
+		{Path: "off/BUILD.bazel", Content: "# gazelle:gitignore false"},
+		{Path: "off/b.log"},
+		{Path: "on/BUILD.bazel", Content: "# gazelle:gitignore true"},
+		{Path: "on/.gitignore", Content: "skip"},
+		{Path: "on/c.log"},
+		{Path: "on/skip"},
+	})
+	defer cleanup()
+
+	for _, tc := range []struct {
+		desc string
+		args []string
+		want []string
+	}{
+		{
+			// Only enabled in "on", where patterns from the root .gitignore
+			// apply, too.
+			desc: "directive",
+			want: []string{
+				"a.go", "a.log", "node_modules/m/m.js", "off/b.log", "out/x.go",
+				"sub/drop.log", "sub/keep.log", "sub/local/y.go", "sub/out/z.go",
+			},
+		},
+		{
+			desc: "flag",
+			args: []string{"-gitignore"},
+			want: []string{"a.go", "off/b.log", "sub/keep.log", "sub/out/z.go"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			args := append([]string{"-repo_root", dir}, tc.args...)
+			cexts := []config.Configurer{&config.CommonConfigurer{}, &Configurer{}}
+			c := testtools.NewTestConfig(t, cexts, nil, args)
+			var files []string
+			err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+				for _, f := range args.RegularFiles {
+					if f != "WORKSPACE" && f != "BUILD.bazel" && f != ".gitignore" {
+						files = append(files, path.Join(args.Rel, f))
+					}
+				}
+				return Walk2FuncResult{}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(files)
+			if diff := cmp.Diff(tc.want, files); diff != "" {
+				t.Errorf("files (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
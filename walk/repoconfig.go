@@ -0,0 +1,275 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// repoConfigFileName is the name of the repository configuration file, read
+// from the repository root.
+const repoConfigFileName = "gazelle.json"
+
+// repoConfig holds directives from the repository configuration file. The
+// file has a list of sections, each with a path pattern and directives
+// written like "# gazelle:" comments without the prefix:
+//
+//	{
+//	  "sections": [
+//	    {"path": "", "directives": ["prefix example.com/repo"]},
+//	    {"path": "services/*", "directives": ["go_naming_convention import"]}
+//	  ]
+//	}
+//
+// Directives of sections matching a directory apply in that directory, as if
+// they were written at the top of its build file.
+type repoConfig struct {
+	sections []repoConfigSection
+}
+
+type repoConfigSection struct {
+	// pattern is a doublestar pattern matched against slash-separated
+	// directory paths relative to the repository root. "" matches only the
+	// root directory.
+	pattern    string
+	directives []rule.Directive
+
+	// lines holds the line each directive was read from.
+	lines []int
+}
+
+// directives returns directives from sections matching the directory rel,
+// in the order they appear in the file, and the lines they were read from.
+// The returned slice of directives may be appended to without affecting rc.
+func (rc *repoConfig) directives(rel string) ([]rule.Directive, []int) {
+	if rc == nil {
+		return nil, nil
+	}
+	var ds []rule.Directive
+	var lines []int
+	for _, s := range rc.sections {
+		var match bool
+		if s.pattern == "" {
+			match = rel == ""
+		} else {
+			match, _ = doublestar.Match(s.pattern, rel)
+		}
+		if match {
+			ds = append(ds, s.directives...)
+			lines = append(lines, s.lines...)
+		}
+	}
+	return ds[:len(ds):len(ds)], lines
+}
+
+// loadRepoConfig reads the repository configuration file. It returns nil if
+// the file doesn't exist. Sections with invalid patterns and invalid
+// directives are reported and skipped.
+func loadRepoConfig(c *config.Config) (*repoConfig, error) {
+	content, err := c.ReadFile(filepath.Join(c.RepoRoot, repoConfigFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s exists but couldn't be read: %v", repoConfigFileName, err)
+	}
+	p := &repoConfigParser{c: c, content: content, dec: json.NewDecoder(bytes.NewReader(content))}
+	rc, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: %v", repoConfigFileName, p.line(), err)
+	}
+	return rc, nil
+}
+
+// repoConfigParser reads the repository configuration file with a streaming
+// decoder, so it can find the line of each directive.
+type repoConfigParser struct {
+	c       *config.Config
+	content []byte
+	dec     *json.Decoder
+}
+
+var directiveKeyRe = regexp.MustCompile(`^\w+$`)
+
+func (p *repoConfigParser) parse() (*repoConfig, error) {
+	rc := &repoConfig{}
+	err := p.object(func(key string) error {
+		if key != "sections" {
+			return fmt.Errorf("unknown field %q", key)
+		}
+		return p.array(func() error {
+			s, ok, err := p.section()
+			if ok {
+				rc.sections = append(rc.sections, s)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the top-level object")
+	}
+	return rc, nil
+}
+
+// section reads one section. It returns false if the section has an invalid
+// pattern, which is reported.
+func (p *repoConfigParser) section() (repoConfigSection, bool, error) {
+	var s repoConfigSection
+	var hasPath bool
+	var pathLine int
+	err := p.object(func(key string) error {
+		switch key {
+		case "path":
+			pattern, err := p.string()
+			if err != nil {
+				return err
+			}
+			s.pattern, hasPath, pathLine = pattern, true, p.line()
+			return nil
+		case "directives":
+			return p.array(func() error {
+				text, err := p.string()
+				if err != nil {
+					return err
+				}
+				line := p.line()
+				text = strings.TrimSpace(text)
+				key, value := text, ""
+				if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+					key, value = text[:i], text[i:]
+				}
+				if !directiveKeyRe.MatchString(key) {
+					p.report(line, "repo-config-directive", "the directive %q is not valid: expected a key and value, like \"prefix example.com/repo\"", text)
+					return nil
+				}
+				s.directives = append(s.directives, rule.Directive{
+					Key:   key,
+					Value: strings.TrimSpace(value),
+				})
+				s.lines = append(s.lines, line)
+				return nil
+			})
+		default:
+			return fmt.Errorf("unknown field %q", key)
+		}
+	})
+	if err != nil {
+		return s, false, err
+	}
+	if !hasPath {
+		return s, false, errors.New(`section is missing "path"`)
+	}
+	pattern := path.Clean(s.pattern)
+	if pattern == "." {
+		pattern = ""
+	}
+	if pattern == ".." || strings.HasPrefix(pattern, "../") || path.IsAbs(pattern) {
+		err = errors.New("pattern must be relative to the repository root")
+	} else if pattern != "" {
+		err = checkPathMatchPattern(pattern)
+	}
+	if err != nil {
+		p.report(pathLine, "repo-config-path", "the path %q is not valid: %v", s.pattern, err)
+		return s, false, nil
+	}
+	s.pattern = pattern
+	return s, true, nil
+}
+
+// object reads a JSON object, calling field to read the value of each field.
+func (p *repoConfigParser) object(field func(key string) error) error {
+	if err := p.delim('{'); err != nil {
+		return err
+	}
+	for p.dec.More() {
+		tok, err := p.dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(tok.(string)); err != nil {
+			return err
+		}
+	}
+	return p.delim('}')
+}
+
+// array reads a JSON array, calling elem to read each element.
+func (p *repoConfigParser) array(elem func() error) error {
+	if err := p.delim('['); err != nil {
+		return err
+	}
+	for p.dec.More() {
+		if err := elem(); err != nil {
+			return err
+		}
+	}
+	return p.delim(']')
+}
+
+func (p *repoConfigParser) string() (string, error) {
+	tok, err := p.dec.Token()
+	if err != nil {
+		return "", err
+	}
+	s, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string, got %v", tok)
+	}
+	return s, nil
+}
+
+func (p *repoConfigParser) delim(want json.Delim) error {
+	tok, err := p.dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
+
+// line returns the line of the last token read.
+func (p *repoConfigParser) line() int {
+	return 1 + bytes.Count(p.content[:p.dec.InputOffset()], []byte("\n"))
+}
+
+func (p *repoConfigParser) report(line int, code, format string, args ...interface{}) {
+	p.c.Report(config.Diagnostic{
+		Severity: config.SeverityWarning,
+		Code:     code,
+		File:     repoConfigFileName,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
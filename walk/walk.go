@@ -25,6 +25,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
@@ -167,6 +168,33 @@ type Walk2FuncArgs struct {
 	// GenFiles is a list of names of generated files, found by reading
 	// "out" and "outs" attributes of rules in f.
 	GenFiles []string
+
+	// UpdateOnly is true if the update_only generation mode is enabled for
+	// languages without their own mode. When File is nil, such languages
+	// should not generate rules.
+	UpdateOnly bool
+
+	// UpdateOnlyLangs holds the generation modes set for individual languages
+	// with "# gazelle:generation_mode lang mode", overriding UpdateOnly. A value
+	// is true for update_only and false for create_and_update. The map must
+	// not be modified.
+	UpdateOnlyLangs map[string]bool
+
+	// NestedRepos lists the roots of repositories nested in this directory or
+	// in subdirectories included in RegularFiles, as slash-separated paths
+	// relative to the repository root. They're found when -nested_repos is
+	// "skip" or "update". The walk doesn't enter them, and they're not
+	// included in Subdirs.
+	NestedRepos []string
+}
+
+// IsUpdateOnly returns whether the language named lang is in the update_only
+// generation mode in this directory.
+func (args Walk2FuncArgs) IsUpdateOnly(lang string) bool {
+	if updateOnly, ok := args.UpdateOnlyLangs[lang]; ok {
+		return updateOnly
+	}
+	return args.UpdateOnly
 }
 
 type Walk2FuncResult struct {
@@ -283,6 +311,10 @@ type walker struct {
 	// knownDirectives is a list of directives supported by those extensions.
 	knownDirectives map[string]bool
 
+	// schemas describes directives for extensions that implement
+	// config.DirectiveSchemaProvider, keyed by directive name.
+	schemas map[string]config.DirectiveSchema
+
 	// shouldUpdateRel indicates whether we should update a set of directories
 	// named by slash-separated repo-root-relative paths. The set is generated
 	// from the list of directories passed in to Walk2. This map contains true
@@ -321,16 +353,29 @@ type visitInfo struct {
 	// and subdirs.
 	containedByParent bool
 
+	// nestedRepo is true if the directory is the root of a nested repository.
+	// It's not part of the parent's subdirs.
+	nestedRepo bool
+
 	c                     *config.Config
 	regularFiles, subdirs []string
+
+	// nestedRepos lists nested repositories found in subdirs.
+	nestedRepos []string
 }
 
 func newWalker(c *config.Config, cexts []config.Configurer, dirs []string, mode Mode, wf Walk2Func) (*walker, error) {
 	knownDirectives := make(map[string]bool)
+	schemas := make(map[string]config.DirectiveSchema)
 	for _, cext := range cexts {
 		for _, d := range cext.KnownDirectives() {
 			knownDirectives[d] = true
 		}
+		if sp, ok := cext.(config.DirectiveSchemaProvider); ok {
+			for _, s := range sp.DirectiveSchemas() {
+				schemas[s.Key] = s
+			}
+		}
 	}
 
 	rels := make([]string, len(dirs))
@@ -363,12 +408,18 @@ func newWalker(c *config.Config, cexts []config.Configurer, dirs []string, mode
 		}
 	}
 
+	wc := new(cache)
+	if cache, ok := c.Exts[walkCacheName].(*Cache); ok && cache != nil {
+		wc = cache.c
+	}
+
 	w := &walker{
 		repoRoot:        c.RepoRoot,
 		rootConfig:      c,
-		cache:           new(cache),
+		cache:           wc,
 		cexts:           cexts,
 		knownDirectives: knownDirectives,
+		schemas:         schemas,
 		wf:              wf,
 		shouldUpdateRel: shouldUpdateRel,
 		visits:          make(map[string]visitInfo),
@@ -429,13 +480,17 @@ func (w *walker) visit(mode Mode, c *config.Config, rel string, updateParent boo
 	if wc.isExcludedDir(rel) {
 		return
 	}
+	if info.nestedRepo {
+		w.visits[rel] = visitInfo{nestedRepo: true}
+		return
+	}
 
-	containedByParent := info.File == nil && wc.updateOnly
+	containedByParent := info.File == nil && wc.containsDirsWithoutBuildFile()
 
 	// Configure the directory, if we haven't done so already.
 	_, alreadyConfigured := w.visits[rel]
 	if !containedByParent && !alreadyConfigured {
-		configure(w.cexts, w.knownDirectives, c, rel, info.File, info.config)
+		configure(w.cexts, w.knownDirectives, c, rel, info.configFile(), info.config)
 	}
 
 	regularFiles := info.RegularFiles
@@ -459,6 +514,26 @@ func (w *walker) visit(mode Mode, c *config.Config, rel string, updateParent boo
 		}
 	}
 
+	// Subdirectories at the roots of nested repositories aren't part of this
+	// repository.
+	isNestedRepo := func(subdir string) bool {
+		return w.visits[path.Join(rel, subdir)].nestedRepo
+	}
+	var nestedRepos []string
+	for _, subdir := range subdirs {
+		if isNestedRepo(subdir) {
+			nestedRepos = append(nestedRepos, path.Join(rel, subdir))
+		}
+	}
+	if nestedRepos != nil {
+		// Copy subdirs before deleting, since it may be shared with the cache.
+		subdirs = slices.DeleteFunc(slices.Clone(subdirs), isNestedRepo)
+		vi := w.visits[rel]
+		vi.subdirs = subdirs
+		vi.nestedRepos = nestedRepos
+		w.visits[rel] = vi
+	}
+
 	// Recursively collect regular files from subdirectories that won't contain
 	// build files. Files are added in depth-first pre-order.
 	if !containedByParent {
@@ -474,6 +549,7 @@ func (w *walker) visit(mode Mode, c *config.Config, rel string, updateParent boo
 			for _, f := range vi.subdirs {
 				subdirs = append(subdirs, path.Join(prefix, f))
 			}
+			nestedRepos = append(nestedRepos, vi.nestedRepos...)
 			for _, subdir := range vi.subdirs {
 				collect(path.Join(rel, subdir), path.Join(prefix, subdir))
 			}
@@ -485,14 +561,17 @@ func (w *walker) visit(mode Mode, c *config.Config, rel string, updateParent boo
 		// Call the callback to update this directory.
 		update := !wc.ignore && shouldUpdate && !hasBuildFileError
 		result := w.wf(Walk2FuncArgs{
-			Dir:          dir,
-			Rel:          rel,
-			Config:       c,
-			Update:       update,
-			File:         info.File,
-			Subdirs:      subdirs,
-			RegularFiles: regularFiles,
-			GenFiles:     info.GenFiles,
+			Dir:             dir,
+			Rel:             rel,
+			Config:          c,
+			Update:          update,
+			File:            info.File,
+			Subdirs:         subdirs,
+			RegularFiles:    regularFiles,
+			GenFiles:        info.GenFiles,
+			UpdateOnly:      wc.updateOnly,
+			UpdateOnlyLangs: wc.langUpdateOnly,
+			NestedRepos:     nestedRepos,
 		})
 		if result.Err != nil {
 			w.errs = append(w.errs, result.Err)
@@ -513,13 +592,13 @@ func (w *walker) visit(mode Mode, c *config.Config, rel string, updateParent boo
 	}
 }
 
-func loadBuildFile(wc *walkConfig, readBuildFilesDir string, pkg, dir string, ents []fs.DirEntry) (*rule.File, error) {
+func loadBuildFile(c *config.Config, wc *walkConfig, pkg, dir string, ents []fs.DirEntry) (*rule.File, error) {
 	var err error
 	readDir := dir
 	readEnts := ents
-	if readBuildFilesDir != "" {
-		readDir = filepath.Join(readBuildFilesDir, filepath.FromSlash(pkg))
-		readEnts, err = os.ReadDir(readDir)
+	if c.ReadBuildFilesDir != "" {
+		readDir = filepath.Join(c.ReadBuildFilesDir, filepath.FromSlash(pkg))
+		readEnts, err = c.ReadDir(readDir)
 		if err != nil {
 			return nil, err
 		}
@@ -528,14 +607,55 @@ func loadBuildFile(wc *walkConfig, readBuildFilesDir string, pkg, dir string, en
 	if path == "" {
 		return nil, nil
 	}
-	return rule.LoadFile(path, pkg)
+	data, err := c.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return rule.LoadData(path, pkg, data)
+}
+
+// checkDirectives reports directives in f with values that don't match their
+// schemas and removes them from f.Directives, so Configurers don't see them.
+// It returns an error if any directive was invalid and strict mode is on.
+func (w *walker) checkDirectives(f *rule.File) error {
+	if f == nil {
+		return nil
+	}
+	c := w.rootConfig
+	valid := f.Directives[:0:0]
+	invalid := 0
+	for _, d := range f.Directives {
+		s, ok := w.schemas[d.Key]
+		if !ok {
+			valid = append(valid, d)
+			continue
+		}
+		if err := s.Validate(d.Value); err != nil {
+			usage := "gazelle:" + s.Key
+			if s.Usage != "" {
+				usage += " " + s.Usage
+			}
+			c.ReportDirectivef(config.SeverityError, "invalid-directive", f, d, "invalid directive gazelle:%s: %v\n\tusage: %s", d.Key, err, usage)
+			invalid++
+			continue
+		}
+		valid = append(valid, d)
+	}
+	if invalid == 0 {
+		return nil
+	}
+	f.Directives = valid
+	if c.Strict {
+		return fmt.Errorf("%s: %d invalid directives", c.RelFile(f), invalid)
+	}
+	return nil
 }
 
 func configure(cexts []config.Configurer, knownDirectives map[string]bool, c *config.Config, rel string, f *rule.File, wc *walkConfig) {
 	if f != nil {
 		for _, d := range f.Directives {
 			if !knownDirectives[d.Key] {
-				log.Printf("%s: unknown directive: gazelle:%s", f.Path, d.Key)
+				c.ReportDirectivef(config.SeverityError, "unknown-directive", f, d, "unknown directive: gazelle:%s", d.Key)
 				if c.Strict {
 					// TODO(https://github.com/bazelbuild/bazel-gazelle/issues/1029):
 					// Refactor to accumulate and propagate errors to main.
@@ -582,7 +702,7 @@ func findGenFiles(wc *walkConfig, f *rule.File) []string {
 // the target file or directory.
 //
 // Otherwise, maybeResolveSymlink returns ent as-is.
-func maybeResolveSymlink(wc *walkConfig, dir, rel string, ent fs.DirEntry) fs.DirEntry {
+func maybeResolveSymlink(c *config.Config, wc *walkConfig, dir, rel string, ent fs.DirEntry) fs.DirEntry {
 	if ent.Type()&os.ModeSymlink == 0 {
 		// Not a symlink, use the original FileInfo.
 		return ent
@@ -591,7 +711,7 @@ func maybeResolveSymlink(wc *walkConfig, dir, rel string, ent fs.DirEntry) fs.Di
 		// A symlink, but not one we should follow.
 		return ent
 	}
-	fi, err := os.Stat(path.Join(dir, ent.Name()))
+	fi, err := c.Stat(filepath.Join(dir, ent.Name()))
 	if err != nil {
 		// A symlink, but not one we could resolve.
 		return ent
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"testing/fstest"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/rule"
@@ -301,6 +302,51 @@ func TestGenMode(t *testing.T) {
 	})
 }
 
+func TestGenModePerLanguage(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:generation_mode update_only\n# gazelle:generation_mode go create_and_update",
+		},
+		{Path: "a/a.go"},
+		{
+			Path:    "b/BUILD.bazel",
+			Content: "# gazelle:generation_mode go update_only\n# gazelle:generation_mode proto create_and_update",
+		},
+		{Path: "b/c/c.proto"},
+	})
+	defer cleanup()
+
+	type modes struct {
+		Go, Proto, Other bool
+	}
+	c, cexts := testConfig(t, dir)
+	got := make(map[string]modes)
+	err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+		got[args.Rel] = modes{
+			Go:    args.IsUpdateOnly("go"),
+			Proto: args.IsUpdateOnly("proto"),
+			Other: args.IsUpdateOnly("other"),
+		}
+		return Walk2FuncResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories without build files are visited separately, since some
+	// language creates build files.
+	want := map[string]modes{
+		"":    {Go: false, Proto: true, Other: true},
+		"a":   {Go: false, Proto: true, Other: true},
+		"b":   {Go: true, Proto: false, Other: true},
+		"b/c": {Go: true, Proto: false, Other: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("modes (-want,+got):\n%s", diff)
+	}
+}
+
 func TestCustomBuildName(t *testing.T) {
 	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
 		{
@@ -463,6 +509,219 @@ a.file
 	})
 }
 
+func TestExcludeNegation(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:exclude third_party/**
+# gazelle:exclude !third_party/ourfork/**
+# gazelle:exclude third_party/ourfork/testdata
+# gazelle:exclude **/*.gen.go
+`,
+		},
+		{Path: "a.gen.go"},
+		{Path: "third_party/top.go"},
+		{Path: "third_party/other/o.go"},
+		{Path: "third_party/ourfork/f.go"},
+		{Path: "third_party/ourfork/sub/g.go"},
+		{Path: "third_party/ourfork/testdata/t.go"},
+		{
+			Path:    "sub/BUILD.bazel",
+			Content: "# gazelle:exclude !keep.gen.go",
+		},
+		{Path: "sub/keep.gen.go"},
+		{Path: "sub/other.gen.go"},
+		{Path: "vendor/mine/m.go"},
+		{Path: "vendor/other/o.go"},
+	})
+	defer cleanup()
+
+	args := []string{"-repo_root", dir, "-exclude", "vendor/**", "-exclude", "!vendor/mine/**"}
+	cexts := []config.Configurer{&config.CommonConfigurer{}, &Configurer{}}
+	c := testtools.NewTestConfig(t, cexts, nil, args)
+	var files, rels []string
+	err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+		rels = append(rels, args.Rel)
+		for _, f := range args.RegularFiles {
+			if f != "BUILD.bazel" {
+				files = append(files, path.Join(args.Rel, f))
+			}
+		}
+		return Walk2FuncResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := []string{
+		"sub/keep.gen.go",
+		"third_party/ourfork/sub/g.go",
+		"third_party/ourfork/f.go",
+		"vendor/mine/m.go",
+	}
+	if diff := cmp.Diff(wantFiles, files); diff != "" {
+		t.Errorf("files (-want,+got):\n%s", diff)
+	}
+	// Excluded directories are walked only to reach re-included paths.
+	wantRels := []string{
+		"sub",
+		"third_party/ourfork/sub",
+		"third_party/ourfork",
+		"third_party",
+		"vendor/mine",
+		"vendor",
+		"",
+	}
+	if diff := cmp.Diff(wantRels, rels); diff != "" {
+		t.Errorf("visited directories (-want,+got):\n%s", diff)
+	}
+}
+
+func TestExcludeDirNegateSubdir(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:exclude third_party
+# gazelle:exclude !third_party/ourfork/**
+# gazelle:exclude \!bang.go
+`,
+		},
+		{Path: "!bang.go"},
+		{Path: "a.go"},
+		{Path: "third_party/top.go"},
+		{Path: "third_party/other/o.go"},
+		{Path: "third_party/ourfork/f.go"},
+		{Path: "third_party/ourfork/sub/g.go"},
+	})
+	defer cleanup()
+
+	c, cexts := testConfig(t, dir)
+	var files, rels []string
+	err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+		rels = append(rels, args.Rel)
+		for _, f := range args.RegularFiles {
+			if f != "BUILD.bazel" {
+				files = append(files, path.Join(args.Rel, f))
+			}
+		}
+		return Walk2FuncResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := []string{
+		"third_party/ourfork/sub/g.go",
+		"third_party/ourfork/f.go",
+		"a.go",
+	}
+	if diff := cmp.Diff(wantFiles, files); diff != "" {
+		t.Errorf("files (-want,+got):\n%s", diff)
+	}
+	wantRels := []string{
+		"third_party/ourfork/sub",
+		"third_party/ourfork",
+		"third_party",
+		"",
+	}
+	if diff := cmp.Diff(wantRels, rels); diff != "" {
+		t.Errorf("visited directories (-want,+got):\n%s", diff)
+	}
+}
+
+func TestGazelleIgnore(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: ".gazelleignore",
+			Content: `./third_party/**
+!third_party/ourfork/**
+
+# Generated code. The last matching pattern decides.
+**/*.pb.go
+node_modules/
+
+# Directory patterns apply to everything inside.
+docs
+!docs/keep/**
+**/out/
+`,
+		},
+		{Path: "a.go"},
+		{Path: "a.pb.go"},
+		{Path: "sub/b.pb.go"},
+		{Path: "sub/node_modules/m.js"},
+		{Path: "node_modules/m.js"},
+		{Path: "docs/d.md"},
+		{Path: "docs/sub/d.md"},
+		{Path: "docs/keep/k.md"},
+		{Path: "out"},
+		{Path: "sub/out/o.go"},
+		{Path: "third_party/x/x.go"},
+		{Path: "third_party/ourfork/f.go"},
+		{
+			// A build file can't re-include paths ignored by .gazelleignore.
+			Path:    "third_party/ourfork/BUILD.bazel",
+			Content: "# gazelle:exclude !g.pb.go",
+		},
+		{Path: "third_party/ourfork/g.pb.go"},
+	})
+	defer cleanup()
+
+	c, cexts := testConfig(t, dir)
+	var files []string
+	err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+		for _, f := range args.RegularFiles {
+			files = append(files, path.Join(args.Rel, f))
+		}
+		return Walk2FuncResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"docs/keep/k.md",
+		"sub/node_modules/m.js",
+		"third_party/ourfork/BUILD.bazel",
+		"third_party/ourfork/f.go",
+		".gazelleignore",
+		"WORKSPACE",
+		"a.go",
+		"out",
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Errorf("files (-want,+got):\n%s", diff)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var diags []config.Diagnostic
+		c := &config.Config{
+			RepoRoot:    "/repo",
+			FS:          fstest.MapFS{".gazelleignore": {Data: []byte("ok\n[c-\n../up\n/abs\n!also_ok/**\n./dir//\n")}},
+			Diagnostics: diagnosticRecorder(func(d config.Diagnostic) { diags = append(diags, d) }),
+		}
+		patterns, err := loadGazelleIgnore(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"ok", "!also_ok/**", "dir/"}, patterns); diff != "" {
+			t.Errorf("patterns (-want,+got):\n%s", diff)
+		}
+		var lines []int
+		for _, d := range diags {
+			if d.Code != "gazelleignore-pattern" || d.File != ".gazelleignore" {
+				t.Errorf("unexpected diagnostic: %v", d)
+			}
+			lines = append(lines, d.Line)
+		}
+		if diff := cmp.Diff([]int{2, 3, 4}, lines); diff != "" {
+			t.Errorf("diagnostic lines (-want,+got):\n%s", diff)
+		}
+	})
+}
+
 func TestExcludeSelf(t *testing.T) {
 	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
 		{
@@ -887,6 +1146,182 @@ func TestGetDirInfoErrorOnParent(t *testing.T) {
 	}
 }
 
+func TestInvalidDirectives(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+				{
+					Path: "BUILD.bazel",
+					Content: `# gazelle:exclude skip
+# gazelle:generation_mode sometimes
+# gazelle:ignore please
+`,
+				},
+				{Path: "skip/BUILD.bazel"},
+			})
+			defer cleanup()
+
+			c, cexts := testConfig(t, dir)
+			c.Strict = strict
+			var diags []config.Diagnostic
+			c.Diagnostics = diagnosticRecorder(func(d config.Diagnostic) { diags = append(diags, d) })
+			var gotDirectives []rule.Directive
+			cexts = append(cexts, &testConfigurer{configure: func(c *config.Config, rel string, f *rule.File) {
+				if rel == "" && f != nil {
+					gotDirectives = f.Directives
+				}
+			}})
+
+			var ignored bool
+			err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+				if args.Rel == "" {
+					ignored = getWalkConfig(args.Config).ignore
+				}
+				return Walk2FuncResult{}
+			})
+			if strict && err == nil {
+				t.Error("got success; want error in strict mode")
+			} else if !strict && err != nil {
+				t.Error(err)
+			}
+
+			wantDiags := []config.Diagnostic{
+				{
+					Severity: config.SeverityError,
+					Code:     "invalid-directive",
+					File:     "BUILD.bazel",
+					Line:     2,
+					Message:  "invalid directive gazelle:generation_mode: \"sometimes\" is not one of create_and_update, update_only\n\tusage: gazelle:generation_mode [lang] create_and_update|update_only",
+				},
+				{
+					Severity: config.SeverityError,
+					Code:     "invalid-directive",
+					File:     "BUILD.bazel",
+					Line:     3,
+					Message:  "invalid directive gazelle:ignore: takes no arguments\n\tusage: gazelle:ignore",
+				},
+			}
+			if diff := cmp.Diff(wantDiags, diags); diff != "" {
+				t.Errorf("diagnostics (-want,+got):\n%s", diff)
+			}
+			if strict {
+				return
+			}
+			wantDirectives := []rule.Directive{{Key: "exclude", Value: "skip"}}
+			if diff := cmp.Diff(wantDirectives, gotDirectives); diff != "" {
+				t.Errorf("directives passed to Configure (-want,+got):\n%s", diff)
+			}
+			if ignored {
+				t.Error("invalid ignore directive was applied")
+			}
+		})
+	}
+}
+
+func TestWalkFS(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{{Path: "WORKSPACE"}})
+	defer cleanup()
+	c, cexts := testConfig(t, dir)
+	c.FS = fstest.MapFS{
+		"BUILD.bazel":        {Data: []byte("# gazelle:exclude skip\nfilegroup(name = \"root\")\n")},
+		"a.txt":              {},
+		"sub/b.txt":          {},
+		"sub/BUILD.bazel":    {Data: []byte("filegroup(name = \"sub\")\n")},
+		"sub/deep/c.txt":     {},
+		"skip/not_visited.x": {},
+	}
+
+	type visit struct {
+		Rel, Rule string
+		Files     []string
+	}
+	var got []visit
+	err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+		v := visit{Rel: args.Rel, Files: args.RegularFiles}
+		if args.File != nil && len(args.File.Rules) > 0 {
+			v.Rule = args.File.Rules[0].Name()
+		}
+		got = append(got, v)
+		return Walk2FuncResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []visit{
+		{Rel: "sub/deep", Files: []string{"c.txt"}},
+		{Rel: "sub", Rule: "sub", Files: []string{"BUILD.bazel", "b.txt"}},
+		{Rel: "", Rule: "root", Files: []string{"BUILD.bazel", "a.txt"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("visits (-want,+got):\n%s", diff)
+	}
+}
+
+type diagnosticRecorder func(config.Diagnostic)
+
+func (r diagnosticRecorder) Report(d config.Diagnostic) { r(d) }
+
+func TestNestedRepos(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{Path: "BUILD.bazel"},
+		{Path: "a/MODULE.bazel"},
+		{Path: "a/BUILD.bazel"},
+		{Path: "a/x.go"},
+		{
+			Path:    "b/BUILD.bazel",
+			Content: "# gazelle:generation_mode update_only",
+		},
+		{Path: "b/c/REPO.bazel"},
+		{Path: "b/c/y.go"},
+		{Path: "b/d/z.go"},
+	})
+	defer cleanup()
+
+	type visit struct {
+		Subdirs, RegularFiles, NestedRepos []string
+	}
+	for _, tc := range []struct {
+		flag string
+		want map[string]visit
+	}{
+		{
+			flag: "package",
+			want: map[string]visit{
+				"":  {Subdirs: []string{"a", "b"}, RegularFiles: []string{"BUILD.bazel"}},
+				"a": {RegularFiles: []string{"BUILD.bazel", "MODULE.bazel", "x.go"}},
+				"b": {Subdirs: []string{"c", "d"}, RegularFiles: []string{"BUILD.bazel", "c/REPO.bazel", "c/y.go", "d/z.go"}},
+			},
+		},
+		{
+			flag: "skip",
+			want: map[string]visit{
+				"":  {Subdirs: []string{"b"}, RegularFiles: []string{"BUILD.bazel"}, NestedRepos: []string{"a"}},
+				"b": {Subdirs: []string{"d"}, RegularFiles: []string{"BUILD.bazel", "d/z.go"}, NestedRepos: []string{"b/c"}},
+			},
+		},
+	} {
+		t.Run(tc.flag, func(t *testing.T) {
+			cexts := []config.Configurer{&config.CommonConfigurer{}, &Configurer{}}
+			c := testtools.NewTestConfig(t, cexts, nil, []string{"-repo_root", dir, "-nested_repos", tc.flag})
+			got := make(map[string]visit)
+			err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+				got[args.Rel] = visit{
+					Subdirs:      args.Subdirs,
+					RegularFiles: args.RegularFiles,
+					NestedRepos:  args.NestedRepos,
+				}
+				return Walk2FuncResult{}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("visits (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func testConfig(t *testing.T, dir string) (*config.Config, []config.Configurer) {
 	args := []string{"-repo_root", dir}
 	cexts := []config.Configurer{&config.CommonConfigurer{}, &Configurer{}}
@@ -989,3 +1424,121 @@ func BenchmarkWalk(b *testing.B) {
 		Walk(c, nil, fs.Args(), VisitAllUpdateSubdirsMode, wf)
 	}
 }
+
+func TestRepoConfig(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "gazelle.json",
+			Content: `{
+  "sections": [
+    {"path": "", "directives": ["exclude skip"]},
+    {
+      "path": "a/*",
+      "directives": [
+        "exclude tmp",
+        "!!! nonsense"
+      ]
+    },
+    {"path": "../outside", "directives": ["exclude x"]}
+  ]
+}
+`,
+		},
+		{
+			Path:    "BUILD.bazel",
+			Content: "# gazelle:exclude other\n",
+		},
+		{Path: "skip/x.txt"},
+		{Path: "a/BUILD.bazel"},
+		{Path: "a/b/tmp/x.txt"},
+		{Path: "a/c/BUILD.bazel"},
+	})
+	defer cleanup()
+
+	var diags []config.Diagnostic
+	gotDirectives := make(map[string][]string)
+	cexts := []config.Configurer{
+		&config.CommonConfigurer{},
+		&Configurer{},
+		&testConfigurer{configure: func(c *config.Config, rel string, f *rule.File) {
+			if f == nil {
+				return
+			}
+			gotDirectives[rel] = []string{}
+			for _, d := range f.Directives {
+				file, line := c.DirectivePosition(f, d)
+				gotDirectives[rel] = append(gotDirectives[rel], fmt.Sprintf("%s:%d: %s %s", file, line, d.Key, d.Value))
+			}
+		}},
+	}
+	c := config.New()
+	c.Diagnostics = diagnosticRecorder(func(d config.Diagnostic) { diags = append(diags, d) })
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, cext := range cexts {
+		cext.RegisterFlags(fs, "update", c)
+	}
+	if err := fs.Parse([]string{"-repo_root", dir}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cext := range cexts {
+		if err := cext.CheckFlags(fs, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var visited []string
+	var noFile []string
+	err := Walk2(c, cexts, []string{dir}, VisitAllUpdateSubdirsMode, func(args Walk2FuncArgs) Walk2FuncResult {
+		visited = append(visited, args.Rel)
+		if args.File == nil {
+			noFile = append(noFile, args.Rel)
+		}
+		return Walk2FuncResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantVisited := []string{"a/b", "a/c", "a", ""}
+	if diff := cmp.Diff(wantVisited, visited); diff != "" {
+		t.Errorf("visited (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"a/b"}, noFile); diff != "" {
+		t.Errorf("directories without build files (-want,+got):\n%s", diff)
+	}
+	wantDirectives := map[string][]string{
+		"": {
+			"gazelle.json:3: exclude skip",
+			"BUILD.bazel:1: exclude other",
+		},
+		"a": {},
+		"a/b": {
+			"gazelle.json:7: exclude tmp",
+		},
+		"a/c": {
+			"gazelle.json:7: exclude tmp",
+		},
+	}
+	if diff := cmp.Diff(wantDirectives, gotDirectives); diff != "" {
+		t.Errorf("directives passed to Configure (-want,+got):\n%s", diff)
+	}
+	wantDiags := []config.Diagnostic{
+		{
+			Severity: config.SeverityWarning,
+			Code:     "repo-config-directive",
+			File:     "gazelle.json",
+			Line:     8,
+			Message:  `the directive "!!! nonsense" is not valid: expected a key and value, like "prefix example.com/repo"`,
+		},
+		{
+			Severity: config.SeverityWarning,
+			Code:     "repo-config-path",
+			File:     "gazelle.json",
+			Line:     11,
+			Message:  `the path "../outside" is not valid: pattern must be relative to the repository root`,
+		},
+	}
+	if diff := cmp.Diff(wantDiags, diags); diff != "" {
+		t.Errorf("diagnostics (-want,+got):\n%s", diff)
+	}
+}